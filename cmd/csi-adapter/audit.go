@@ -0,0 +1,48 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/audit"
+)
+
+var verifyAuditLogCmd = &cobra.Command{
+	Use:          "verify-audit-log",
+	Short:        "Verify the hash chain of the audit log",
+	Long:         "Verifies that no entry of the audit log at --audit-log-path, including its rotated files, was altered or removed, reporting the first entry which breaks the chain.",
+	SilenceUsage: true,
+	RunE: func(c *cobra.Command, args []string) error {
+		if auditLogPath == "" {
+			return fmt.Errorf("--audit-log-path must be set")
+		}
+		count, err := audit.VerifyLog(auditLogPath)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stdout, "verified %d entries\n", count)
+		return nil
+	},
+}
+
+func init() {
+	driverCmd.AddCommand(verifyAuditLogCmd)
+}
@@ -19,22 +19,39 @@ package main
 import (
 	"flag"
 	"os"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	_ "k8s.io/klog/v2"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/logging"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/node"
 )
 
 var Version string
 
 // flags
 var (
-	identity    string
-	nodeID      string
-	protocol    string
-	listen      string
-	dataRoot    string
-	volumeLimit int64
+	identity     string
+	nodeID       string
+	protocol     string
+	listen       string
+	dataRoot     string
+	volumeLimit  int64
+	httpEndpoint string
+	logFormat    string
+
+	revocationPolicy string
+
+	expiryAnnotation    string
+	expiryWarningWindow time.Duration
+
+	connectivityCheckTimeout time.Duration
+
+	auditLogPath       string
+	auditLogMaxSize    int64
+	auditLogMaxBackups int
 )
 
 var driverCmd = &cobra.Command{
@@ -42,6 +59,9 @@ var driverCmd = &cobra.Command{
 	Short:        "Ephemeral CSI driver for use in the COSI",
 	Long:         "This Container Storage Interface (CSI) driver provides the ability to reference Bucket and BucketAccess objects, extracting connection/credential information and writing it to the Pod's filesystem. This driver does not manage the lifecycle of the bucket or the backing of the objects themselves, it only acts as the middle-man.",
 	SilenceUsage: true,
+	PersistentPreRunE: func(c *cobra.Command, args []string) error {
+		return logging.Setup(logFormat, os.Stderr)
+	},
 	RunE: func(c *cobra.Command, args []string) error {
 		return driver(args)
 	},
@@ -61,7 +81,16 @@ func init() {
 	driverCmd.PersistentFlags().StringVarP(&listen, "listen", "l", listen, "address of the listening socket for the node server")
 	driverCmd.PersistentFlags().StringVarP(&protocol, "protocol", "p", protocol, "must be one of tcp, tcp4, tcp6, unix, unixpacket")
 	driverCmd.PersistentFlags().StringVarP(&dataRoot, "data-path", "d", protocol, "the path to the directory for storing secrets")
-	driverCmd.PersistentFlags().Int64VarP(&volumeLimit, "max-volumes", "m", volumeLimit, "the maximum amount of volumes which can be assigned to a node")
+	driverCmd.PersistentFlags().Int64VarP(&volumeLimit, "max-volumes", "m", volumeLimit, "the maximum amount of volumes which can be assigned to a node, enforced by the node for inline volumes, unlimited when 0")
+	driverCmd.PersistentFlags().StringVar(&revocationPolicy, "revocation-policy", string(node.RevocationPolicyWarn), "action taken on mounted credentials when their bucketAccess is revoked or deleted, one of Warn, Wipe")
+	driverCmd.PersistentFlags().StringVar(&expiryAnnotation, "expiry-annotation", "objectstorage.k8s.io/credentials-expire-at", "annotation on the minted secret holding the RFC3339 expiry of the credentials, disabled when empty")
+	driverCmd.PersistentFlags().DurationVar(&expiryWarningWindow, "expiry-warning-window", time.Hour, "how long before credentials expire pods are warned")
+	driverCmd.PersistentFlags().DurationVar(&connectivityCheckTimeout, "connectivity-check-timeout", 0, "check the credentials of volumes against their bucket before mounting them, failing the mount after this timeout, disabled when 0")
+	driverCmd.PersistentFlags().StringVar(&auditLogPath, "audit-log-path", "", "file the credentials handed out to pods are audited to, disabled when empty")
+	driverCmd.PersistentFlags().Int64Var(&auditLogMaxSize, "audit-log-max-size", 100<<20, "size in bytes beyond which the audit log is rotated")
+	driverCmd.PersistentFlags().IntVar(&auditLogMaxBackups, "audit-log-max-backups", 10, "how many rotated audit logs are kept")
+	driverCmd.PersistentFlags().StringVar(&logFormat, "log-format", logging.FormatText, "format of the logs, one of text, json")
+	driverCmd.PersistentFlags().StringVar(&httpEndpoint, "http-endpoint", httpEndpoint, "address of the HTTP server serving /readyz and /metrics, disabled when empty")
 
 	_ = driverCmd.PersistentFlags().MarkHidden("alsologtostderr")
 	_ = driverCmd.PersistentFlags().MarkHidden("log_backtrace_at")
@@ -0,0 +1,150 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"os"
+	"time"
+
+	csicommon "github.com/kubernetes-csi/drivers/pkg/csi-common"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/klog/v2"
+
+	cs "sigs.k8s.io/container-object-storage-interface-api/clientset/typed/objectstorage.k8s.io/v1alpha1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/collector"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/controller"
+	id "sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/identity"
+)
+
+// controller flags
+var (
+	leaderElectionNamespace string
+	leaderElectionLeaseName string
+
+	staleFinalizerInterval    time.Duration
+	staleFinalizerGracePeriod time.Duration
+)
+
+var controllerCmd = &cobra.Command{
+	Use:          "controller",
+	Short:        "Serve the CSI controller service for persistent and generic ephemeral volumes",
+	Long:         "Serves the CSI identity and controller services only, for the external-provisioner sidecar to create the volumes of persistentVolumeClaims. The node service keeps running in the daemonset. The replica holding the leader election lease also removes the bucketAccess finalizers of volumes whose pod or node no longer exists.",
+	SilenceUsage: true,
+	RunE: func(c *cobra.Command, args []string) error {
+		return serveController()
+	},
+}
+
+func init() {
+	controllerCmd.Flags().StringVar(&leaderElectionNamespace, "leader-election-namespace", "default", "namespace of the lease electing the replica collecting stale finalizers")
+	controllerCmd.Flags().StringVar(&leaderElectionLeaseName, "leader-election-lease-name", "objectstorage-csi-adapter-collector", "name of the lease electing the replica collecting stale finalizers")
+	controllerCmd.Flags().DurationVar(&staleFinalizerInterval, "stale-finalizer-interval", 10*time.Minute, "how often bucketAccesses are checked for stale finalizers, disabled when 0")
+	controllerCmd.Flags().DurationVar(&staleFinalizerGracePeriod, "stale-finalizer-grace-period", 5*time.Minute, "how long a finalizer must be found stale before it is removed")
+
+	driverCmd.AddCommand(controllerCmd)
+}
+
+func serveController() error {
+	if protocol == "unix" {
+		if err := os.RemoveAll(listen); err != nil {
+			klog.Fatalf("could not prepare socket: %v", err)
+		}
+	}
+
+	idServer, err := id.NewIdentityServer(identity, Version, map[string]string{}, nil)
+	if err != nil {
+		return err
+	}
+	controllerServer, err := controller.NewControllerServer()
+	if err != nil {
+		return err
+	}
+	klog.InfoS("controller server prepared")
+
+	if staleFinalizerInterval > 0 {
+		if err := startCollector(context.Background()); err != nil {
+			return err
+		}
+	}
+
+	s := csicommon.NewNonBlockingGRPCServer()
+	s.Start(listen, idServer, controllerServer, nil)
+	s.Wait()
+
+	return nil
+}
+
+// startCollector collects stale finalizers in the background while this replica holds the lease.
+// Losing the lease exits, so that a single replica ever removes finalizers.
+func startCollector(ctx context.Context) error {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return err
+	}
+	cosiClient, err := cs.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+	kube, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+
+	holder, err := os.Hostname()
+	if err != nil {
+		return err
+	}
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta:  metav1.ObjectMeta{Namespace: leaderElectionNamespace, Name: leaderElectionLeaseName},
+		Client:     kube.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{Identity: holder},
+	}
+	c := collector.NewCollector(cosiClient, kube, client.NewRecorder(kube, identity, holder), staleFinalizerGracePeriod)
+
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		LeaseDuration:   15 * time.Second,
+		RenewDeadline:   10 * time.Second,
+		RetryPeriod:     2 * time.Second,
+		ReleaseOnCancel: true,
+		Name:            leaderElectionLeaseName,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				c.Run(ctx, staleFinalizerInterval)
+			},
+			OnStoppedLeading: func() {
+				klog.Fatalf("lost lease %s/%s", leaderElectionNamespace, leaderElectionLeaseName)
+			},
+			OnNewLeader: func(identity string) {
+				klog.InfoS("new stale finalizer collector elected", "holder", identity)
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	go elector.Run(ctx)
+	return nil
+}
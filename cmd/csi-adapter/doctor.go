@@ -0,0 +1,86 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/doctor"
+)
+
+const mountInfoPath = "/proc/self/mountinfo"
+
+// doctor flags
+var (
+	kubeletPodsDir string
+)
+
+var doctorCmd = &cobra.Command{
+	Use:          "doctor",
+	Short:        "Diagnose the installation of the adapter",
+	Long:         "Checks the RBAC permissions of the adapter, its CSIDriver object, and the directories and mount propagation it depends on, printing a PASS or FAIL line with remediation advice for each check. Run it inside the adapter container, with the same flags as the adapter.",
+	SilenceUsage: true,
+	RunE: func(c *cobra.Command, args []string) error {
+		return diagnose()
+	},
+}
+
+func init() {
+	doctorCmd.Flags().StringVar(&kubeletPodsDir, "kubelet-pods-dir", "/var/lib/kubelet/pods", "the directory kubelet mounts the volumes of pods under, as seen by the adapter")
+
+	driverCmd.AddCommand(doctorCmd)
+}
+
+func diagnose() error {
+	var checks []doctor.Check
+
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return err
+	}
+	kube, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+	checks = append(checks, doctor.AccessChecks(kube)...)
+	checks = append(checks, doctor.CSIDriverChecks(kube, identity)...)
+
+	if protocol == "unix" {
+		// csicommon accepts the endpoint with or without the scheme
+		socketPath := strings.TrimPrefix(listen, "unix://")
+		checks = append(checks, doctor.WritableDirCheck("socket directory", filepath.Dir(socketPath),
+			"mount a writable volume at the directory of --listen, shared with the node-driver-registrar"))
+	}
+	checks = append(checks,
+		doctor.WritableDirCheck("data path", dataRoot, "mount a writable hostPath volume at --data-path"),
+		doctor.PropagationCheck("data path", dataRoot, mountInfoPath),
+		doctor.PropagationCheck("kubelet pods directory", kubeletPodsDir, mountInfoPath),
+	)
+
+	if failed := doctor.Run(context.Background(), os.Stdout, checks...); failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(checks))
+	}
+	return nil
+}
@@ -17,13 +17,16 @@ limitations under the License.
 package main
 
 import (
+	"net/http"
 	"os"
 
 	csicommon "github.com/kubernetes-csi/drivers/pkg/csi-common"
 	"k8s.io/klog/v2"
 
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/audit"
 	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/controller"
 	id "sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/identity"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/metrics"
 	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/node"
 )
 
@@ -34,15 +37,53 @@ func driver(args []string) error {
 		}
 	}
 
-	idServer, err := id.NewIdentityServer(identity, Version, map[string]string{})
+	policy := node.RevocationPolicy(revocationPolicy)
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	opts := []node.Option{
+		node.WithRevocationPolicy(policy),
+		node.WithCredentialsExpiry(expiryAnnotation, expiryWarningWindow),
+	}
+	if connectivityCheckTimeout > 0 {
+		opts = append(opts, node.WithConnectivityCheck(connectivityCheckTimeout))
+	}
+	if auditLogPath != "" {
+		auditLog, err := audit.Open(auditLogPath, auditLogMaxSize, auditLogMaxBackups)
+		if err != nil {
+			return err
+		}
+		defer auditLog.Close()
+		opts = append(opts, node.WithAuditor(auditLog))
+	}
+	nodeServer := node.NewNodeServerOrDie(identity, nodeID, dataRoot, volumeLimit, opts...)
+	nodeServer.Start(stopCh)
+
+	readiness := id.NewReadiness(nodeServer.ReadinessChecks()...)
+	idServer, err := id.NewIdentityServer(identity, Version, map[string]string{}, readiness)
 	if err != nil {
 		return err
 	}
 	klog.InfoS("identity server prepared")
 
-	nodeServer := node.NewNodeServerOrDie(identity, nodeID, dataRoot, volumeLimit)
 	controllerServer, err := controller.NewControllerServer()
 
+	if httpEndpoint != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/readyz", readiness)
+		mux.Handle("/metrics", metrics.Handler())
+		go func() {
+			klog.InfoS("serving http endpoint", "address", httpEndpoint)
+			if err := http.ListenAndServe(httpEndpoint, mux); err != nil {
+				klog.ErrorS(err, "http endpoint stopped", "address", httpEndpoint)
+			}
+		}()
+	}
+
 	s := csicommon.NewNonBlockingGRPCServer()
 	s.Start(listen, idServer, controllerServer, nodeServer)
 	s.Wait()
@@ -0,0 +1,144 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/mount-utils"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/node"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// inspect flags
+var (
+	output      string
+	offline     bool
+	syncTimeout time.Duration
+)
+
+var inspectCmd = &cobra.Command{
+	Use:          "inspect",
+	Short:        "List the volumes staged on this node",
+	Long:         "Lists every volume staged under the data path, together with the pod and bucketAccess it was published for, whether it is still mounted and whether its finalizer is present on the bucketAccess.",
+	SilenceUsage: true,
+	RunE: func(c *cobra.Command, args []string) error {
+		return inspect(os.Stdout)
+	},
+}
+
+func init() {
+	inspectCmd.Flags().StringVarP(&output, "output", "o", outputTable, "output format, one of table, json")
+	inspectCmd.Flags().BoolVar(&offline, "offline", false, "do not contact the API server, leaving the finalizer state unknown")
+	inspectCmd.Flags().DurationVar(&syncTimeout, "sync-timeout", 30*time.Second, "how long to wait for the bucketAccess cache to sync")
+
+	driverCmd.AddCommand(inspectCmd)
+}
+
+func inspect(w io.Writer) error {
+	if output != outputTable && output != outputJSON {
+		return fmt.Errorf("unsupported output format %q, must be one of %s, %s", output, outputTable, outputJSON)
+	}
+
+	var cosiClient client.NodeClient
+	if !offline {
+		c, err := client.NewClient(identity, nodeID)
+		if err != nil {
+			return err
+		}
+
+		stopCh := make(chan struct{})
+		defer close(stopCh)
+		c.Start(stopCh)
+
+		ctx, cancel := context.WithTimeout(context.Background(), syncTimeout)
+		defer cancel()
+		if !cache.WaitForCacheSync(ctx.Done(), c.HasSynced) {
+			return fmt.Errorf("bucketAccess cache did not sync within %s", syncTimeout)
+		}
+		cosiClient = c
+	}
+
+	provisioner := node.NewProvisioner(dataRoot, mount.New(""), client.NewProvisionerClient())
+	states, err := node.InspectVolumes(context.Background(), provisioner, cosiClient)
+	if err != nil {
+		return err
+	}
+
+	if output == outputJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(states)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "VOLUME ID\tTARGET PATH\tPOD\tBUCKET ACCESS\tMOUNTED\tFINALIZER\tERROR")
+	for _, s := range states {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			s.VolumeID,
+			orUnknown(s.TargetPath),
+			orUnknown(podRef(s.PodNamespace, s.PodName)),
+			orUnknown(s.BucketAccess),
+			yesNo(s.Mounted),
+			finalizerState(s.FinalizerPresent),
+			s.Error,
+		)
+	}
+	return tw.Flush()
+}
+
+func podRef(namespace, name string) string {
+	if namespace == "" && name == "" {
+		return ""
+	}
+	return namespace + "/" + name
+}
+
+func orUnknown(s string) string {
+	if s == "" {
+		return "<unknown>"
+	}
+	return s
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+func finalizerState(present *bool) string {
+	if present == nil {
+		return "<unknown>"
+	}
+	return yesNo(*present)
+}
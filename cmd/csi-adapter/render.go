@@ -0,0 +1,185 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/node"
+	bucketprotocol "sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/protocol"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+// render flags
+var (
+	bucketFile           string
+	bucketAccessFile     string
+	secretFile           string
+	volumeAttributesFile string
+	outputDir            string
+	format               string
+)
+
+var renderCmd = &cobra.Command{
+	Use:   "render",
+	Short: "Render the files of a volume without mounting it",
+	Long: "Reads a Bucket, BucketAccess and minted Secret from YAML files and writes the files NodePublishVolume would expose to the pod into a local directory. " +
+		"Given the volume attributes, the files are rendered like for a volume with those attributes: presigned URLs, or only the bucket information for volumes of a bucketRequest, which need no BucketAccess and Secret. " +
+		"Service account tokens, passed by the kubelet, are not rendered.",
+	SilenceUsage: true,
+	RunE: func(c *cobra.Command, args []string) error {
+		return render(c.Flags().Changed("format"))
+	},
+}
+
+func init() {
+	renderCmd.Flags().StringVar(&bucketFile, "bucket", "", "path to the Bucket YAML")
+	renderCmd.Flags().StringVar(&bucketAccessFile, "bucket-access", "", "path to the BucketAccess YAML")
+	renderCmd.Flags().StringVar(&secretFile, "secret", "", "path to the minted Secret YAML")
+	renderCmd.Flags().StringVar(&volumeAttributesFile, "volume-attributes", "", "path to a YAML map of the volume attributes")
+	renderCmd.Flags().StringVar(&outputDir, "output-dir", "", "directory to write the rendered files to")
+	renderCmd.Flags().StringVar(&format, "format", bucketprotocol.FormatJSON, "the format requested in the volume attributes, one of "+strings.Join(bucketprotocol.Formats(), ", ")+", overrides the volume attributes")
+	for _, f := range []string{"bucket", "output-dir"} {
+		_ = renderCmd.MarkFlagRequired(f)
+	}
+
+	driverCmd.AddCommand(renderCmd)
+}
+
+// render writes the files of a volume, dispatching on its attributes like NodePublishVolume.
+func render(formatSet bool) error {
+	volCtx := map[string]string{}
+	if volumeAttributesFile != "" {
+		if err := readObject(volumeAttributesFile, &volCtx); err != nil {
+			return err
+		}
+	}
+	if formatSet {
+		volCtx[client.FormatKey] = format
+	}
+
+	bkt := &v1alpha1.Bucket{}
+	if err := readObject(bucketFile, bkt); err != nil {
+		return err
+	}
+
+	var files map[string][]byte
+	var err error
+	if _, ok := volCtx[client.BrNameKey]; ok {
+		if _, _, err := client.ParseWebIdentity(volCtx); err != nil {
+			return err
+		}
+		files, err = node.RenderInfoFiles(bkt)
+	} else {
+		files, err = renderAccessFiles(bkt, volCtx)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(outputDir, 0750); err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		path := filepath.Join(outputDir, name)
+		if err := ioutil.WriteFile(path, files[name], 0640); err != nil {
+			return err
+		}
+		fmt.Println(path)
+	}
+	return nil
+}
+
+// renderAccessFiles returns the files of a volume of a bucketAccessRequest, credentials rendered in
+// the format or presigned URLs.
+func renderAccessFiles(bkt *v1alpha1.Bucket, volCtx map[string]string) (map[string][]byte, error) {
+	format, err := node.ParseFormat(volCtx)
+	if err != nil {
+		return nil, err
+	}
+	presign, err := node.ParsePresign(volCtx)
+	if err != nil {
+		return nil, err
+	}
+	if bucketAccessFile == "" || secretFile == "" {
+		return nil, fmt.Errorf("--bucket-access and --secret are required unless the volume attributes set %s", client.BrNameKey)
+	}
+
+	ba := &v1alpha1.BucketAccess{}
+	if err := readObject(bucketAccessFile, ba); err != nil {
+		return nil, err
+	}
+	secret := &v1.Secret{}
+	if err := readObject(secretFile, secret); err != nil {
+		return nil, err
+	}
+
+	if !ba.Status.AccessGranted {
+		return nil, util.ErrorBANoAccess
+	}
+	if ba.Status.MintedSecret == nil {
+		return nil, util.ErrorBANoMintedSecret
+	}
+	if ba.Status.MintedSecret.Name != secret.Name || ba.Status.MintedSecret.Namespace != secret.Namespace {
+		return nil, fmt.Errorf("secret %s/%s is not the minted secret %s/%s of bucketAccess %s",
+			secret.Namespace, secret.Name, ba.Status.MintedSecret.Namespace, ba.Status.MintedSecret.Name, ba.Name)
+	}
+
+	expiresAt, err := node.CredentialsExpiry(secret, expiryAnnotation)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if expiresAt != nil && !now.Before(*expiresAt) {
+		return nil, fmt.Errorf(util.ErrorTemplateCredentialsExpired, expiresAt.Format(time.RFC3339))
+	}
+
+	if presign != nil {
+		presign.SetExpiry(now, expiresAt)
+		return node.RenderPresignedFiles(bkt, secret, expiresAt, *presign, now)
+	}
+	return node.RenderFiles(bkt, secret, expiresAt, format)
+}
+
+func readObject(path string, obj interface{}) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := yaml.Unmarshal(data, obj); err != nil {
+		return fmt.Errorf("failed to decode %s: %v", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"net/http"
+
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog/v2"
+
+	cs "sigs.k8s.io/container-object-storage-interface-api/clientset/typed/objectstorage.k8s.io/v1alpha1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/webhook"
+)
+
+// webhook flags
+var (
+	webhookAddress string
+	webhookMode    string
+	tlsCertFile    string
+	tlsKeyFile     string
+)
+
+var webhookCmd = &cobra.Command{
+	Use:          "webhook",
+	Short:        "Serve the admission webhooks for pods using COSI volumes",
+	Long:         "Serves a validating admission webhook on /validate, checking the bucketAccessRequests and bucketAccesses referenced by the inline COSI volumes of pods on creation, the same checks the node runs when mounting them. Pods failing validation are admitted with warnings, or denied, depending on --webhook-mode. Serves a mutating admission webhook on /mutate, injecting the environment variables the cloud SDKs read into the containers mounting COSI volumes.",
+	SilenceUsage: true,
+	RunE: func(c *cobra.Command, args []string) error {
+		return serveWebhook()
+	},
+}
+
+func init() {
+	webhookCmd.Flags().StringVar(&webhookAddress, "webhook-address", ":9443", "address of the HTTPS server serving /validate and /mutate")
+	webhookCmd.Flags().StringVar(&webhookMode, "webhook-mode", string(webhook.ModeWarn), "what happens to pods failing validation, one of warn, deny")
+	webhookCmd.Flags().StringVar(&tlsCertFile, "tls-cert-file", "/etc/webhook/certs/tls.crt", "the serving certificate of the webhook")
+	webhookCmd.Flags().StringVar(&tlsKeyFile, "tls-key-file", "/etc/webhook/certs/tls.key", "the private key of the serving certificate")
+
+	driverCmd.AddCommand(webhookCmd)
+}
+
+func serveWebhook() error {
+	mode := webhook.Mode(webhookMode)
+	if err := mode.Validate(); err != nil {
+		return err
+	}
+
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return err
+	}
+	cosiClient, err := cs.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+	kube, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/validate", webhook.NewValidator(identity, mode, cosiClient, kube))
+	mux.Handle("/mutate", webhook.NewMutator(identity, cosiClient, kube))
+
+	klog.InfoS("serving admission webhook", "address", webhookAddress, "mode", mode)
+	return http.ListenAndServeTLS(webhookAddress, tlsCertFile, tlsKeyFile, mux)
+}
@@ -0,0 +1,321 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package audit writes a tamper-evident log of the credentials handed out to pods. Every entry
+// records who received which credentials, identified by a hash, never by value. Entries are
+// chained by hash, so that removing or altering an entry breaks the chain from there on.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Action is what happened to the credentials of a volume.
+type Action string
+
+const (
+	// ActionPublish records credentials being mounted into a pod.
+	ActionPublish Action = "publish"
+	// ActionUnpublish records credentials being removed from a pod.
+	ActionUnpublish Action = "unpublish"
+	// ActionRotate records the credentials of a mounted volume being replaced.
+	ActionRotate Action = "rotate"
+	// ActionRevoke records the bucketAccess of a mounted volume being revoked.
+	ActionRevoke Action = "revoke"
+)
+
+// Entry is a line of the audit log.
+type Entry struct {
+	Time           time.Time `json:"time"`
+	Action         Action    `json:"action"`
+	Node           string    `json:"node"`
+	VolumeID       string    `json:"volumeID"`
+	Pod            string    `json:"pod"`
+	PodNamespace   string    `json:"podNamespace"`
+	ServiceAccount string    `json:"serviceAccount,omitempty"`
+	BucketAccess   string    `json:"bucketAccess"`
+	Bucket         string    `json:"bucket,omitempty"`
+	// SecretResourceVersion is the resourceVersion of the minted secret the credentials were read
+	// from.
+	SecretResourceVersion string `json:"secretResourceVersion,omitempty"`
+	// CredentialsHash identifies the delivered credentials, see CredentialsHash.
+	CredentialsHash string `json:"credentialsHash,omitempty"`
+	// Reason explains revocations.
+	Reason string `json:"reason,omitempty"`
+
+	// PrevHash is the hash of the previous entry, empty for the first entry of a log.
+	PrevHash string `json:"prevHash"`
+	// Hash is the sha256 of the entry without Hash, which covers PrevHash.
+	Hash string `json:"hash"`
+}
+
+// hash returns the hash of the entry, ignoring the Hash it carries.
+func (e Entry) hash() (string, error) {
+	e.Hash = ""
+	data, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// CredentialsHash identifies the credentials delivered in a volume by the checksums of its files,
+// keyed by file name, leaving out the files which hold no credentials. The same credentials
+// delivered in the same format always have the same hash.
+func CredentialsHash(checksums map[string]string, exclude ...string) string {
+	excluded := map[string]bool{}
+	for _, name := range exclude {
+		excluded[name] = true
+	}
+	names := []string{}
+	for name := range checksums {
+		if !excluded[name] {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	for _, name := range names {
+		fmt.Fprintf(h, "%s:%s\n", name, checksums[name])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Log is an append-only audit log in JSON lines. It is rotated once it would grow beyond maxSize,
+// keeping maxBackups rotated files named after the log with the suffixes .1, the newest, to
+// .maxBackups. The chain continues across rotations.
+type Log struct {
+	sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	file     *os.File
+	size     int64
+	prevHash string
+}
+
+// Open opens the audit log at path for appending, continuing the chain of the entries already in it.
+func Open(path string, maxSize int64, maxBackups int) (*Log, error) {
+	l := &Log{path: path, maxSize: maxSize, maxBackups: maxBackups}
+
+	prevHash, err := lastHash(path)
+	if err == nil && prevHash == "" {
+		// the log may just have been rotated
+		prevHash, err = lastHash(l.backup(1))
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the last entry of the audit log")
+	}
+	l.prevHash = prevHash
+
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *Log) open() error {
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return errors.Wrap(err, "failed to open the audit log")
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return errors.Wrap(err, "failed to open the audit log")
+	}
+	l.file, l.size = f, info.Size()
+	return nil
+}
+
+func (l *Log) backup(n int) string {
+	return fmt.Sprintf("%s.%d", l.path, n)
+}
+
+// Record chains the entry to the previous one and appends it to the log. The entry is synced to
+// disk before Record returns.
+func (l *Log) Record(e Entry) error {
+	l.Lock()
+	defer l.Unlock()
+
+	e.PrevHash = l.prevHash
+	hash, err := e.hash()
+	if err != nil {
+		return errors.Wrap(err, "failed to hash audit entry")
+	}
+	e.Hash = hash
+	line, err := json.Marshal(e)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal audit entry")
+	}
+	line = append(line, '\n')
+
+	if l.size > 0 && l.size+int64(len(line)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+	if _, err := l.file.Write(line); err != nil {
+		return errors.Wrap(err, "failed to write audit entry")
+	}
+	if err := l.file.Sync(); err != nil {
+		return errors.Wrap(err, "failed to write audit entry")
+	}
+	l.size += int64(len(line))
+	l.prevHash = hash
+	return nil
+}
+
+// rotate moves the log to the first backup, shifting the older backups and removing the oldest.
+func (l *Log) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return errors.Wrap(err, "failed to rotate the audit log")
+	}
+	if l.maxBackups < 1 {
+		if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+			return errors.Wrap(err, "failed to rotate the audit log")
+		}
+		return l.open()
+	}
+
+	if err := os.Remove(l.backup(l.maxBackups)); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "failed to rotate the audit log")
+	}
+	for n := l.maxBackups - 1; n >= 1; n-- {
+		if err := os.Rename(l.backup(n), l.backup(n+1)); err != nil && !os.IsNotExist(err) {
+			return errors.Wrap(err, "failed to rotate the audit log")
+		}
+	}
+	if err := os.Rename(l.path, l.backup(1)); err != nil {
+		return errors.Wrap(err, "failed to rotate the audit log")
+	}
+	return l.open()
+}
+
+// Close closes the log.
+func (l *Log) Close() error {
+	l.Lock()
+	defer l.Unlock()
+	return l.file.Close()
+}
+
+// lastHash returns the hash of the last entry of the log at path, empty when there is none.
+func lastHash(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	lines := bytes.Split(bytes.TrimSpace(data), []byte("\n"))
+	last := lines[len(lines)-1]
+	if len(last) == 0 {
+		return "", nil
+	}
+	e := Entry{}
+	if err := json.Unmarshal(last, &e); err != nil {
+		return "", err
+	}
+	return e.Hash, nil
+}
+
+// Verify checks the chain of the entries read from r, which continue the chain ending in prevHash,
+// empty for the start of a log. It returns the hash of the last entry, to verify the next file of a
+// rotated log with, and fails at the first entry which was altered or does not follow its
+// predecessor.
+func Verify(r io.Reader, prevHash string) (string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		e := Entry{}
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return "", errors.Wrapf(err, "line %d", line)
+		}
+		if e.PrevHash != prevHash {
+			return "", fmt.Errorf("line %d: entry does not follow the previous entry", line)
+		}
+		hash, err := e.hash()
+		if err != nil {
+			return "", errors.Wrapf(err, "line %d", line)
+		}
+		if hash != e.Hash {
+			return "", fmt.Errorf("line %d: entry was modified", line)
+		}
+		prevHash = e.Hash
+	}
+	return prevHash, scanner.Err()
+}
+
+// VerifyLog checks the chain of the audit log at path across its rotated files, from the oldest to
+// the log itself. The oldest entry kept continues from an entry rotated away, so it is trusted to.
+// It returns the number of entries verified.
+func VerifyLog(path string) (int, error) {
+	files := []string{}
+	for n := 1; ; n++ {
+		backup := fmt.Sprintf("%s.%d", path, n)
+		if _, err := os.Stat(backup); err != nil {
+			break
+		}
+		files = append([]string{backup}, files...)
+	}
+	files = append(files, path)
+
+	count := 0
+	prevHash := ""
+	for i, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return count, err
+		}
+		if i == 0 {
+			first := Entry{}
+			if line := bytes.SplitN(data, []byte("\n"), 2)[0]; len(line) > 0 {
+				if err := json.Unmarshal(line, &first); err != nil {
+					return count, errors.Wrap(err, file)
+				}
+			}
+			prevHash = first.PrevHash
+		}
+		if prevHash, err = Verify(bytes.NewReader(data), prevHash); err != nil {
+			return count, errors.Wrap(err, file)
+		}
+		count += bytes.Count(data, []byte("\n"))
+	}
+	return count, nil
+}
@@ -0,0 +1,179 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func entry(action Action, pod string) Entry {
+	return Entry{
+		Time:            time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+		Action:          action,
+		Node:            "node",
+		VolumeID:        "volume",
+		Pod:             pod,
+		PodNamespace:    "namespace",
+		BucketAccess:    "bucketAccess",
+		CredentialsHash: "hash",
+	}
+}
+
+func TestLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	l, err := Open(path, 1<<20, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pod := range []string{"a", "b"} {
+		if err := l.Record(entry(ActionPublish, pod)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l.Close()
+
+	// reopening continues the chain
+	l, err = Open(path, 1<<20, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Record(entry(ActionUnpublish, "a")); err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+
+	count, err := VerifyLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(3, count); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+}
+
+func TestLogRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	// room for two entries per file
+	l, err := Open(path, 700, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 7; i++ {
+		if err := l.Record(entry(ActionRotate, "pod")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l.Close()
+
+	for _, name := range []string{"audit.log", "audit.log.1", "audit.log.2"} {
+		data, err := ioutil.ReadFile(filepath.Join(filepath.Dir(path), name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(data) > 700 {
+			t.Errorf("expected %s to be rotated at 700 bytes, got %d", name, len(data))
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("expected only 2 rotated files to be kept, got %v", err)
+	}
+
+	// the oldest entries are gone, the rest still chain
+	count, err := VerifyLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(5, count); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l, err := Open(path, 1<<20, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pod := range []string{"a", "b", "c"} {
+		if err := l.Record(entry(ActionPublish, pod)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l.Close()
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+
+	cases := map[string]struct {
+		log  string
+		want string
+	}{
+		"Intact": {
+			log: strings.Join(lines, ""),
+		},
+		"Modified": {
+			log:  lines[0] + strings.Replace(lines[1], `"pod":"b"`, `"pod":"x"`, 1) + lines[2],
+			want: "line 2: entry was modified",
+		},
+		"Removed": {
+			log:  lines[0] + lines[2],
+			want: "line 2: entry does not follow the previous entry",
+		},
+		"Truncated": {
+			log:  lines[1] + lines[2],
+			want: "line 1: entry does not follow the previous entry",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := Verify(strings.NewReader(tc.log), "")
+			got := ""
+			if err != nil {
+				got = err.Error()
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCredentialsHash(t *testing.T) {
+	files := map[string]string{"credentials": "a", "protocolConn.json": "b"}
+
+	if got := CredentialsHash(files, "protocolConn.json"); got != CredentialsHash(map[string]string{"credentials": "a"}) {
+		t.Errorf("expected excluded files not to change the hash")
+	}
+	if CredentialsHash(files) == CredentialsHash(map[string]string{"credentials": "c", "protocolConn.json": "b"}) {
+		t.Errorf("expected different credentials to hash differently")
+	}
+	if diff := cmp.Diff("", CredentialsHash(map[string]string{"protocolConn.json": "b"}, "protocolConn.json")); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+}
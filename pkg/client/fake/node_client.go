@@ -2,6 +2,7 @@ package fake
 
 import (
 	"context"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
 
 	v1 "k8s.io/api/core/v1"
@@ -19,19 +20,37 @@ type FakeNodeClient struct {
 	MockGetB   func(ctx context.Context, pod *v1.Pod, bName string) (*v1alpha1.Bucket, error)
 	MockGetPod func(ctx context.Context, podName, podNs string) (*v1.Pod, error)
 
-	MockGetResources func(ctx context.Context, barName, podName, podNs string) (bkt *v1alpha1.Bucket, ba *v1alpha1.BucketAccess, secret *v1.Secret, pod *v1.Pod, err error)
+	MockGetCachedBA func(baName string) (*v1alpha1.BucketAccess, error)
 
-	MockAddBAFinalizer    func(ctx context.Context, ba *v1alpha1.BucketAccess, BAFinalizer string) error
-	MockRemoveBAFinalizer func(ctx context.Context, ba *v1alpha1.BucketAccess, BAFinalizer string) error
+	MockGetResources  func(ctx context.Context, barName, barNs, podName, podNs string) (bkt *v1alpha1.Bucket, ba *v1alpha1.BucketAccess, pod *v1.Pod, err error)
+	MockGetBucketInfo func(ctx context.Context, brName, podName, podNs string) (bkt *v1alpha1.Bucket, pod *v1.Pod, err error)
+	MockGetSecret     func(ctx context.Context, pod *v1.Pod, ba *v1alpha1.BucketAccess) (*v1.Secret, error)
+
+	MockUpdateBAFinalizers func(ctx context.Context, baName string, add map[string]client.FinalizerOwner, remove []string) (*v1alpha1.BucketAccess, error)
+
+	// MockRecorder replaces the shared fake recorder when set.
+	MockRecorder record.EventRecorder
+
+	MockAddBAEventHandler func(handler cache.ResourceEventHandler)
+	MockHasSynced         func() bool
+	MockPing              func(ctx context.Context) error
 }
 
 func (f FakeNodeClient) GetPod(ctx context.Context, podName, podNs string) (*v1.Pod, error) {
 	return f.MockGetPod(ctx, podName, podNs)
 }
 
-var fRecorder = record.NewFakeRecorder(10)
+func (f FakeNodeClient) GetCachedBA(baName string) (*v1alpha1.BucketAccess, error) {
+	return f.MockGetCachedBA(baName)
+}
+
+// fRecorder discards events, nothing drains a buffered recorder shared by all tests.
+var fRecorder = &record.FakeRecorder{}
 
 func (f FakeNodeClient) Recorder() record.EventRecorder {
+	if f.MockRecorder != nil {
+		return f.MockRecorder
+	}
 	return fRecorder
 }
 
@@ -51,14 +70,32 @@ func (f FakeNodeClient) GetB(ctx context.Context, pod *v1.Pod, bName string) (*v
 	return f.MockGetB(ctx, pod, bName)
 }
 
-func (f FakeNodeClient) GetResources(ctx context.Context, barName, podName, podNs string) (bkt *v1alpha1.Bucket, ba *v1alpha1.BucketAccess, secret *v1.Secret, pod *v1.Pod, err error) {
-	return f.MockGetResources(ctx, barName, podName, podNs)
+func (f FakeNodeClient) GetBucketInfo(ctx context.Context, brName, podName, podNs string) (bkt *v1alpha1.Bucket, pod *v1.Pod, err error) {
+	return f.MockGetBucketInfo(ctx, brName, podName, podNs)
+}
+
+func (f FakeNodeClient) GetResources(ctx context.Context, barName, barNs, podName, podNs string) (bkt *v1alpha1.Bucket, ba *v1alpha1.BucketAccess, pod *v1.Pod, err error) {
+	return f.MockGetResources(ctx, barName, barNs, podName, podNs)
 }
 
-func (f FakeNodeClient) AddBAFinalizer(ctx context.Context, ba *v1alpha1.BucketAccess, BAFinalizer string) error {
-	return f.MockAddBAFinalizer(ctx, ba, BAFinalizer)
+func (f FakeNodeClient) GetSecret(ctx context.Context, pod *v1.Pod, ba *v1alpha1.BucketAccess) (*v1.Secret, error) {
+	return f.MockGetSecret(ctx, pod, ba)
+}
+
+func (f FakeNodeClient) UpdateBAFinalizers(ctx context.Context, baName string, add map[string]client.FinalizerOwner, remove []string) (*v1alpha1.BucketAccess, error) {
+	return f.MockUpdateBAFinalizers(ctx, baName, add, remove)
+}
+
+func (f FakeNodeClient) AddBAEventHandler(handler cache.ResourceEventHandler) {
+	f.MockAddBAEventHandler(handler)
+}
+
+func (f FakeNodeClient) Start(stopCh <-chan struct{}) {}
+
+func (f FakeNodeClient) HasSynced() bool {
+	return f.MockHasSynced()
 }
 
-func (f FakeNodeClient) RemoveBAFinalizer(ctx context.Context, ba *v1alpha1.BucketAccess, BAFinalizer string) error {
-	return f.MockRemoveBAFinalizer(ctx, ba, BAFinalizer)
+func (f FakeNodeClient) Ping(ctx context.Context) error {
+	return f.MockPing(ctx)
 }
@@ -13,12 +13,24 @@ type MockProvisionerClient struct {
 	MockRemoveAll func(path string) error
 	MockWriteFile func(data []byte, filepath string) error
 	MockReadFile  func(filename string) ([]byte, error)
+	MockReadDir   func(dirname string) ([]os.FileInfo, error)
+	MockRename    func(oldpath, newpath string) error
+	MockDirUsage  func(path string) (bytes, inodes int64, err error)
+	MockFsStats   func(path string) (client.FsStats, error)
 }
 
 func (p MockProvisionerClient) ReadFile(filename string) ([]byte, error) {
 	return p.MockReadFile(filename)
 }
 
+func (p MockProvisionerClient) ReadDir(dirname string) ([]os.FileInfo, error) {
+	return p.MockReadDir(dirname)
+}
+
+func (p MockProvisionerClient) Rename(oldpath, newpath string) error {
+	return p.MockRename(oldpath, newpath)
+}
+
 func (p MockProvisionerClient) MkdirAll(path string, perm os.FileMode) error {
 	return p.MockMkdirAll(path, perm)
 }
@@ -30,3 +42,11 @@ func (p MockProvisionerClient) RemoveAll(path string) error {
 func (p MockProvisionerClient) WriteFile(data []byte, filepath string) error {
 	return p.MockWriteFile(data, filepath)
 }
+
+func (p MockProvisionerClient) DirUsage(path string) (bytes, inodes int64, err error) {
+	return p.MockDirUsage(path)
+}
+
+func (p MockProvisionerClient) FsStats(path string) (client.FsStats, error) {
+	return p.MockFsStats(path)
+}
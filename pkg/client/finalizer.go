@@ -0,0 +1,94 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+	cs "sigs.k8s.io/container-object-storage-interface-api/clientset/typed/objectstorage.k8s.io/v1alpha1"
+)
+
+// FinalizerOwner records the volume a finalizer protects the bucketAccess for. It is kept in an
+// annotation of the bucketAccess named like the finalizer, so that finalizers left behind by
+// volumes which will never be unpublished, because their node is gone, can be told apart.
+type FinalizerOwner struct {
+	Node         string    `json:"node"`
+	PodNamespace string    `json:"podNamespace"`
+	PodName      string    `json:"podName"`
+	PodUID       types.UID `json:"podUID,omitempty"`
+}
+
+// FinalizerOwners returns the recorded owners of the finalizers of the bucketAccess. Finalizers
+// without a readable owner are left out.
+func FinalizerOwners(ba *v1alpha1.BucketAccess) map[string]FinalizerOwner {
+	owners := map[string]FinalizerOwner{}
+	for _, f := range ba.Finalizers {
+		value, ok := ba.Annotations[f]
+		if !ok {
+			continue
+		}
+		owner := FinalizerOwner{}
+		if err := json.Unmarshal([]byte(value), &owner); err != nil {
+			continue
+		}
+		owners[f] = owner
+	}
+	return owners
+}
+
+// UpdateBAFinalizers adds the given finalizers, recording their owners, and removes the given
+// finalizers along with their owners, on the named bucketAccess in a single update, retrying on
+// conflicts. Removing finalizers from a bucketAccess which no longer exists succeeds, returning no
+// bucketAccess.
+func UpdateBAFinalizers(ctx context.Context, cosiClient cs.ObjectstorageV1alpha1Interface, baName string, add map[string]FinalizerOwner, remove []string) (*v1alpha1.BucketAccess, error) {
+	var ba *v1alpha1.BucketAccess
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		current, err := cosiClient.BucketAccesses().Get(ctx, baName, metav1.GetOptions{})
+		if kerrors.IsNotFound(err) && len(add) == 0 {
+			ba = nil
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		updated := current.DeepCopy()
+		for f, owner := range add {
+			controllerutil.AddFinalizer(updated, f)
+			data, err := json.Marshal(owner)
+			if err != nil {
+				return err
+			}
+			if updated.Annotations == nil {
+				updated.Annotations = map[string]string{}
+			}
+			updated.Annotations[f] = string(data)
+		}
+		for _, f := range remove {
+			controllerutil.RemoveFinalizer(updated, f)
+			delete(updated.Annotations, f)
+		}
+		if reflect.DeepEqual(current.Finalizers, updated.Finalizers) && reflect.DeepEqual(current.Annotations, updated.Annotations) {
+			ba = current
+			return nil
+		}
+
+		ba, err = cosiClient.BucketAccesses().Update(ctx, updated, metav1.UpdateOptions{})
+		if kerrors.IsNotFound(err) && len(add) == 0 {
+			ba = nil
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ba, nil
+}
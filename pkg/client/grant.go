@@ -0,0 +1,68 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+const (
+	// BARGrantsConfigMapName names the configMap granting other namespaces access to the
+	// bucketAccessRequests of its namespace. Every key is a consumer namespace, its value the comma
+	// separated names of the bucketAccessRequests it may mount, or GrantAll.
+	BARGrantsConfigMapName = "objectstorage-bar-grants"
+	// GrantAll grants access to every bucketAccessRequest of the namespace.
+	GrantAll = "*"
+)
+
+// ParseBARNamespace returns the namespace of the bucketAccessRequest of a volume, the namespace of
+// the pod unless the bar-namespace attribute is set.
+func ParseBARNamespace(volCtx map[string]string, podNs string) string {
+	if barNs := volCtx[BarNamespaceKey]; barNs != "" {
+		return barNs
+	}
+	return podNs
+}
+
+// CheckBARGrant fails unless pods in consumerNs may mount the bucketAccessRequest. Pods may always
+// mount the bucketAccessRequests of their own namespace, any other namespace has to grant access
+// in its BARGrantsConfigMapName configMap.
+func CheckBARGrant(ctx context.Context, kube kubernetes.Interface, barName, barNs, consumerNs string) error {
+	if barNs == consumerNs {
+		return nil
+	}
+
+	cm, err := kube.CoreV1().ConfigMaps(barNs).Get(ctx, BARGrantsConfigMapName, metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		return fmt.Errorf(util.ErrorTemplateBARNotGranted, barNs, barName, consumerNs)
+	}
+	if err != nil {
+		return errors.Wrap(err, util.WrapErrorGetBARGrantsFailed)
+	}
+	if !barGranted(cm, barName, consumerNs) {
+		return fmt.Errorf(util.ErrorTemplateBARNotGranted, barNs, barName, consumerNs)
+	}
+	return nil
+}
+
+func barGranted(cm *v1.ConfigMap, barName, consumerNs string) bool {
+	granted, ok := cm.Data[consumerNs]
+	if !ok {
+		return false
+	}
+	for _, name := range strings.Split(granted, ",") {
+		name = strings.TrimSpace(name)
+		if name == GrantAll || name == barName {
+			return true
+		}
+	}
+	return false
+}
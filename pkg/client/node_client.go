@@ -2,23 +2,27 @@ package client
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
+	"time"
 
 	"github.com/pkg/errors"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/kubernetes/scheme"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/klog/v2"
-	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
 	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
 	cs "sigs.k8s.io/container-object-storage-interface-api/clientset/typed/objectstorage.k8s.io/v1alpha1"
+	listers "sigs.k8s.io/container-object-storage-interface-api/listers/objectstorage.k8s.io/v1alpha1"
 
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/logging"
 	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
 )
 
@@ -27,22 +31,90 @@ const (
 	PodNamespaceKey = "csi.storage.k8s.io/pod.namespace"
 
 	BarNameKey = "bar-name"
+	// BarNamespaceKey names the namespace of the bucketAccessRequest, when it is not the namespace
+	// of the pod. The namespace has to grant access, see CheckBARGrant.
+	BarNamespaceKey = "bar-namespace"
+	// BrNameKey names a bucketRequest, for volumes holding only the connection information of its
+	// bucket. It cannot be combined with BarNameKey.
+	BrNameKey = "br-name"
+	// FormatKey selects the format the files of the volume are rendered in.
+	FormatKey = "format"
+	// PresignObjectsKey lists the comma separated keys of the objects the volume publishes
+	// presigned URLs for, instead of credentials. PresignMethodsKey lists the methods the URLs are
+	// presigned for, GET unless set, and PresignTTLKey how long they stay valid.
+	PresignObjectsKey = "presign-objects"
+	PresignMethodsKey = "presign-methods"
+	PresignTTLKey     = "presign-ttl"
+	// TokenAudienceKey selects the service account token of the pod written to a volume, for
+	// workload identity federation. RoleARNKey names the AWS role the token is exchanged for, and
+	// MountPathKey the path the volume is mounted at in the containers, which the AWS config
+	// written next to the token needs to name the token file.
+	TokenAudienceKey = "token-audience"
+	RoleARNKey       = "role-arn"
+	MountPathKey     = "mount-path"
+	// ServiceAccountTokensKey is set by the kubelet to the tokens requested in the tokenRequests
+	// of the CSIDriver, keyed by audience.
+	ServiceAccountTokensKey = "csi.storage.k8s.io/serviceAccount.tokens"
+
+	// PVCNameKey and PVCNamespaceKey are passed to CreateVolume by the external-provisioner when it
+	// runs with --extra-create-metadata.
+	PVCNameKey      = "csi.storage.k8s.io/pvc/name"
+	PVCNamespaceKey = "csi.storage.k8s.io/pvc/namespace"
+
+	// PersistentVolumeIDPrefix marks the IDs of volumes created by the controller server. Unlike
+	// inline ephemeral volumes, these can be published to several pods on the same node.
+	PersistentVolumeIDPrefix = "persistent-"
+
+	informerResync = 10 * time.Minute
 )
 
 var _ NodeClient = &nodeClient{}
 
-func newRecorder(kubeClient *kubernetes.Clientset, driverName, nodeID string) record.EventRecorder {
-	eventBroadcaster := record.NewBroadcaster()
-	eventBroadcaster.StartLogging(klog.Infof)
+// eventScheme knows the COSI types besides the built-in ones, events on them are dropped otherwise.
+var eventScheme = newEventScheme()
+
+func newEventScheme() *runtime.Scheme {
+	s := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(s))
+	utilruntime.Must(v1alpha1.AddToScheme(s))
+	return s
+}
+
+// NewRecorder returns a recorder emitting the events of the driver, from the given host, to the
+// cluster.
+func NewRecorder(kubeClient kubernetes.Interface, driverName, host string) record.EventRecorder {
+	eventBroadcaster := record.NewBroadcasterWithCorrelatorOptions(record.CorrelatorOptions{
+		KeyFunc: util.EventAggregatorByVolume,
+	})
+	eventBroadcaster.StartStructuredLogging(0)
 	eventBroadcaster.StartRecordingToSink(
 		&typedcorev1.EventSinkImpl{Interface: kubeClient.CoreV1().Events("")})
-	return eventBroadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: driverName, Host: nodeID})
+	return eventBroadcaster.NewRecorder(eventScheme, v1.EventSource{Component: driverName, Host: host})
+}
+
+func newBAInformer(cosiClient cs.ObjectstorageV1alpha1Interface) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				return cosiClient.BucketAccesses().List(context.Background(), options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				return cosiClient.BucketAccesses().Watch(context.Background(), options)
+			},
+		},
+		&v1alpha1.BucketAccess{},
+		informerResync,
+		cache.Indexers{},
+	)
 }
 
 type nodeClient struct {
 	cosiClient cs.ObjectstorageV1alpha1Interface
 	kubeClient kubernetes.Interface
 	recorder   record.EventRecorder
+
+	baInformer cache.SharedIndexInformer
+	baLister   listers.BucketAccessLister
 }
 
 type NodeClient interface {
@@ -51,33 +123,83 @@ type NodeClient interface {
 	GetBR(ctx context.Context, pod *v1.Pod, brName, brNs string) (*v1alpha1.BucketRequest, error)
 	GetB(ctx context.Context, pod *v1.Pod, bName string) (*v1alpha1.Bucket, error)
 	GetPod(ctx context.Context, podName, podNs string) (*v1.Pod, error)
+	// GetCachedBA returns the BucketAccess from the informer cache without validating its status.
+	GetCachedBA(baName string) (*v1alpha1.BucketAccess, error)
 
-	GetResources(ctx context.Context, barName, podName, podNs string) (bkt *v1alpha1.Bucket, ba *v1alpha1.BucketAccess, secret *v1.Secret, pod *v1.Pod, err error)
+	// GetResources resolves the bucketAccessRequest of a pod to the bucket and bucketAccess it grants.
+	// A bucketAccessRequest outside the namespace of the pod has to be granted to it.
+	GetResources(ctx context.Context, barName, barNs, podName, podNs string) (bkt *v1alpha1.Bucket, ba *v1alpha1.BucketAccess, pod *v1.Pod, err error)
+	// GetBucketInfo resolves the bucketRequest of a pod to its bucket, for volumes without credentials.
+	GetBucketInfo(ctx context.Context, brName, podName, podNs string) (bkt *v1alpha1.Bucket, pod *v1.Pod, err error)
+	// GetSecret returns the secret minted for the bucketAccess.
+	GetSecret(ctx context.Context, pod *v1.Pod, ba *v1alpha1.BucketAccess) (*v1.Secret, error)
 
-	AddBAFinalizer(ctx context.Context, ba *v1alpha1.BucketAccess, BAFinalizer string) error
-	RemoveBAFinalizer(ctx context.Context, ba *v1alpha1.BucketAccess, BAFinalizer string) error
+	// UpdateBAFinalizers adds and removes finalizers of the bucketAccess, see UpdateBAFinalizers.
+	UpdateBAFinalizers(ctx context.Context, baName string, add map[string]FinalizerOwner, remove []string) (*v1alpha1.BucketAccess, error)
 
 	Recorder() record.EventRecorder
+
+	// AddBAEventHandler registers a handler for changes to BucketAccess objects seen by the informer.
+	AddBAEventHandler(handler cache.ResourceEventHandler)
+	// Start runs the informers backing the client until stopCh is closed.
+	Start(stopCh <-chan struct{})
+	// HasSynced reports whether the informers have completed their initial list.
+	HasSynced() bool
+	// Ping verifies that the API server is reachable.
+	Ping(ctx context.Context) error
 }
 
 func NewClientOrDie(driverName, nodeId string) NodeClient {
-	config, err := rest.InClusterConfig()
+	client, err := NewClient(driverName, nodeId)
 	if err != nil {
 		panic(err.Error())
 	}
-	// The following function calls may panic based on the config
-	client := cs.NewForConfigOrDie(config)
-	kube := kubernetes.NewForConfigOrDie(config)
+	return client
+}
+
+// NewClient creates a NodeClient from the in-cluster configuration.
+func NewClient(driverName, nodeId string) (NodeClient, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+	client, err := cs.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	kube, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	baInformer := newBAInformer(client)
 	return &nodeClient{
 		cosiClient: client,
 		kubeClient: kube,
-		recorder:   newRecorder(kube, driverName, nodeId),
+		recorder:   NewRecorder(kube, driverName, nodeId),
+		baInformer: baInformer,
+		baLister:   listers.NewBucketAccessLister(baInformer.GetIndexer()),
+	}, nil
+}
+
+// ParseBucketInfoContext parses the volume context of a volume holding only bucket information.
+func ParseBucketInfoContext(volCtx map[string]string) (brname, podname, podns string, err error) {
+	if _, ok := volCtx[BarNameKey]; ok {
+		err = util.ErrorBARAndBRSet
+		return
 	}
+	if brname, err = util.ParseValue(BrNameKey, volCtx); err != nil {
+		return
+	}
+	if podname, err = util.ParseValue(PodNameKey, volCtx); err != nil {
+		return
+	}
+	if podns, err = util.ParseValue(PodNamespaceKey, volCtx); err != nil {
+		return
+	}
+	return brname, podname, podns, nil
 }
 
 func ParseVolumeContext(volCtx map[string]string) (barname, podname, podns string, err error) {
-	klog.Info("parsing bucketAccessRequest namespace/name from volume context")
-
 	if barname, err = util.ParseValue(BarNameKey, volCtx); err != nil {
 		return
 	}
@@ -90,153 +212,200 @@ func ParseVolumeContext(volCtx map[string]string) (barname, podname, podns strin
 	return barname, podname, podns, nil
 }
 
+// emitWarningEvent records the event on the pod as well as on the COSI objects involved, so that
+// bucket admins see failing mounts without looking at the pod.
+func (n *nodeClient) emitWarningEvent(ctx context.Context, resource util.EventResource, objects ...runtime.Object) {
+	for _, obj := range objects {
+		util.EmitWarningEvent(ctx, n.recorder, obj, resource)
+	}
+}
+
 func (n *nodeClient) GetBAR(ctx context.Context, pod *v1.Pod, barName, barNs string) (*v1alpha1.BucketAccessRequest, error) {
-	klog.Infof("getting bucketAccessRequest %q", fmt.Sprintf("%s/%s", barNs, barName))
+	logging.FromContext(ctx).V(4).Info("getting bucketAccessRequest", "bar", klog.KRef(barNs, barName))
 	bar, err := n.cosiClient.BucketAccessRequests(barNs).Get(ctx, barName, metav1.GetOptions{})
 	if err != nil {
-		return nil, util.LogErr(errors.Wrap(err, util.WrapErrorGetBARFailed))
+		return nil, errors.Wrap(err, util.WrapErrorGetBARFailed)
+	}
+	if err := ValidateBAR(bar); err != nil {
+		n.emitWarningEvent(ctx, barEvents[err], pod, bar)
+		return nil, err
+	}
+	return bar, nil
+}
+
+func (n *nodeClient) GetBA(ctx context.Context, pod *v1.Pod, baName string) (*v1alpha1.BucketAccess, error) {
+	logging.FromContext(ctx).V(4).Info("getting bucketAccess", "bucketAccess", baName)
+	ba, err := n.cosiClient.BucketAccesses().Get(ctx, baName, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, util.WrapErrorGetBAFailed)
+	}
+	if err := ValidateBA(ba); err != nil {
+		n.emitWarningEvent(ctx, baEvents[err], pod, ba)
+		return nil, err
 	}
+	return ba, nil
+}
+
+var barEvents = map[error]util.EventResource{
+	util.ErrorBARUnsetBR:  util.BARBucketRequestNotSet,
+	util.ErrorBARNoAccess: util.BARAccessNotGranted,
+	util.ErrorBARUnsetBA:  util.BARBucketAccessNotSet,
+}
+
+// ValidateBAR checks that the bucketAccessRequest grants access to a bucketAccess.
+func ValidateBAR(bar *v1alpha1.BucketAccessRequest) error {
 	// TODO: BAR.Spec.BucketRequestName can be unset if the BucketName is set
 	if len(bar.Spec.BucketRequestName) == 0 {
-		util.EmitWarningEvent(n.recorder, pod, util.BARBucketRequestNotSet)
-		return nil, util.LogErr(util.ErrorBARUnsetBR)
+		return util.ErrorBARUnsetBR
 	}
 	if !bar.Status.AccessGranted {
-		util.EmitWarningEvent(n.recorder, pod, util.BARAccessNotGranted)
-		return nil, util.LogErr(util.ErrorBARNoAccess)
+		return util.ErrorBARNoAccess
 	}
 	if len(bar.Status.BucketAccessName) == 0 {
-		util.EmitWarningEvent(n.recorder, pod, util.BARBucketAccessNotSet)
-		return nil, util.LogErr(util.ErrorBARUnsetBA)
+		return util.ErrorBARUnsetBA
 	}
-	return bar, nil
+	return nil
 }
 
-func (n *nodeClient) GetBA(ctx context.Context, pod *v1.Pod, baName string) (*v1alpha1.BucketAccess, error) {
-	klog.Infof("getting bucketAccess %q", fmt.Sprintf("%s", baName))
-	ba, err := n.cosiClient.BucketAccesses().Get(ctx, baName, metav1.GetOptions{})
-	if err != nil {
-		return nil, util.LogErr(errors.Wrap(err, util.WrapErrorGetBAFailed))
+var baEvents = map[error]util.EventResource{
+	util.ErrorBADeleting:       util.BAMarkedForDeletion,
+	util.ErrorBANoAccess:       util.BAAccessNotGranted,
+	util.ErrorBANoMintedSecret: util.BAMintedSecretNotSet,
+}
+
+// ValidateBA checks that the bucketAccess grants access and has minted credentials.
+func ValidateBA(ba *v1alpha1.BucketAccess) error {
+	if ba.DeletionTimestamp != nil {
+		return util.ErrorBADeleting
 	}
 	if !ba.Status.AccessGranted {
-		util.EmitWarningEvent(n.recorder, pod, util.BAAccessNotGranted)
-		return nil, util.LogErr(util.ErrorBANoAccess)
+		return util.ErrorBANoAccess
 	}
 	if ba.Status.MintedSecret == nil {
-		util.EmitWarningEvent(n.recorder, pod, util.BAMintedSecretNotSet)
-		return nil, util.LogErr(util.ErrorBANoMintedSecret)
+		return util.ErrorBANoMintedSecret
 	}
-	return ba, nil
+	return nil
 }
 
 func (n *nodeClient) GetBR(ctx context.Context, pod *v1.Pod, brName, brNs string) (*v1alpha1.BucketRequest, error) {
-	klog.Infof("getting bucketRequest %q", brName)
+	logging.FromContext(ctx).V(4).Info("getting bucketRequest", "br", klog.KRef(brNs, brName))
 	br, err := n.cosiClient.BucketRequests(brNs).Get(ctx, brName, metav1.GetOptions{})
 	if err != nil {
-		return nil, util.LogErr(errors.Wrap(err, util.WrapErrorGetBRFailed))
+		return nil, errors.Wrap(err, util.WrapErrorGetBRFailed)
 	}
 	if !br.Status.BucketAvailable {
-		util.EmitWarningEvent(n.recorder, pod, util.BRNotAvailable)
-		return nil, util.LogErr(util.ErrorBRNotAvailable)
+		util.EmitWarningEvent(ctx, n.recorder, pod, util.BRNotAvailable)
+		return nil, util.ErrorBRNotAvailable
 	}
 	if len(br.Status.BucketName) == 0 {
-		util.EmitWarningEvent(n.recorder, pod, util.BRBucketNameNotSet)
-		return nil, util.LogErr(util.ErrorBRUnsetBucketName)
+		util.EmitWarningEvent(ctx, n.recorder, pod, util.BRBucketNameNotSet)
+		return nil, util.ErrorBRUnsetBucketName
 	}
 	return br, nil
 }
 
 func (n *nodeClient) GetB(ctx context.Context, pod *v1.Pod, bName string) (*v1alpha1.Bucket, error) {
-	klog.Infof("getting bucket %q", bName)
+	logging.FromContext(ctx).V(4).Info("getting bucket", "bucket", bName)
 	// is BucketInstanceName the correct field, or should it be BucketClass
 	bkt, err := n.cosiClient.Buckets().Get(ctx, bName, metav1.GetOptions{})
 	if err != nil {
-		return nil, util.LogErr(errors.Wrap(err, util.WrapErrorGetBFailed))
+		return nil, errors.Wrap(err, util.WrapErrorGetBFailed)
 	}
 	if !bkt.Status.BucketAvailable {
-		util.EmitWarningEvent(n.recorder, pod, util.BNotAvailable)
-		return nil, util.LogErr(util.ErrorBNotAvailable)
+		util.EmitWarningEvent(ctx, n.recorder, pod, util.BNotAvailable)
+		return nil, util.ErrorBNotAvailable
 	}
 	return bkt, nil
 }
 
+func (n *nodeClient) GetCachedBA(baName string) (*v1alpha1.BucketAccess, error) {
+	return n.baLister.Get(baName)
+}
+
 func (n *nodeClient) GetPod(ctx context.Context, podName, podNs string) (*v1.Pod, error) {
 	return n.kubeClient.CoreV1().Pods(podNs).Get(ctx, podName, metav1.GetOptions{})
 }
 
-func (n *nodeClient) GetResources(ctx context.Context, barName, podName, podNs string) (bkt *v1alpha1.Bucket, ba *v1alpha1.BucketAccess, secret *v1.Secret, pod *v1.Pod, err error) {
+func (n *nodeClient) GetResources(ctx context.Context, barName, barNs, podName, podNs string) (bkt *v1alpha1.Bucket, ba *v1alpha1.BucketAccess, pod *v1.Pod, err error) {
 	var bar *v1alpha1.BucketAccessRequest
 
 	if pod, err = n.GetPod(ctx, podName, podNs); err != nil {
 		return
 	}
 
-	if bar, err = n.GetBAR(ctx, pod, barName, podNs); err != nil {
+	if err = CheckBARGrant(ctx, n.kubeClient, barName, barNs, podNs); err != nil {
+		util.EmitWarningEvent(ctx, n.recorder, pod, util.BARReferenceNotGranted)
 		return
 	}
 
-	if ba, err = n.GetBA(ctx, pod, bar.Status.BucketAccessName); err != nil {
+	if bar, err = n.GetBAR(ctx, pod, barName, barNs); err != nil {
 		return
 	}
 
-	if bkt, err = n.GetB(ctx, pod, ba.Spec.BucketName); err != nil {
+	if ba, err = n.GetBA(ctx, pod, bar.Status.BucketAccessName); err != nil {
 		return
 	}
 
-	if secret, err = n.kubeClient.CoreV1().Secrets(ba.Status.MintedSecret.Namespace).Get(ctx, ba.Status.MintedSecret.Name, metav1.GetOptions{}); err != nil {
-		util.EmitWarningEvent(n.recorder, pod, util.MintedSecretNotFound)
-		err = errors.Wrap(err, util.WrapErrorGetSecretFailed)
+	if bkt, err = n.GetB(ctx, pod, ba.Spec.BucketName); err != nil {
 		return
 	}
-	util.EmitNormalEvent(n.recorder, pod, util.AllResourcesReady)
+	util.EmitNormalEvent(ctx, n.recorder, pod, util.AllResourcesReady)
 	return
 }
 
-func GetProtocol(bkt *v1alpha1.Bucket) ([]byte, error) {
-	klog.Infof("bucket protocol %+v", bkt.Spec.Protocol)
-	var (
-		data               []byte
-		err                error
-		protocolConnection interface{}
-	)
+func (n *nodeClient) GetBucketInfo(ctx context.Context, brName, podName, podNs string) (bkt *v1alpha1.Bucket, pod *v1.Pod, err error) {
+	var br *v1alpha1.BucketRequest
 
-	switch {
-	case bkt.Spec.Protocol.S3 != nil:
-		protocolConnection = bkt.Spec.Protocol.S3
-	case bkt.Spec.Protocol.AzureBlob != nil:
-		protocolConnection = bkt.Spec.Protocol.AzureBlob
-	case bkt.Spec.Protocol.GCS != nil:
-		protocolConnection = bkt.Spec.Protocol.GCS
-	default:
-		err = util.ErrorInvalidProtocol
+	if pod, err = n.GetPod(ctx, podName, podNs); err != nil {
+		return
 	}
 
-	if err != nil {
-		return nil, util.LogErr(err)
+	if br, err = n.GetBR(ctx, pod, brName, podNs); err != nil {
+		return
 	}
 
-	if data, err = json.Marshal(protocolConnection); err != nil {
-		return nil, util.LogErr(errors.Wrap(err, util.WrapErrorMarshalProtocolFailed))
+	if bkt, err = n.GetB(ctx, pod, br.Status.BucketName); err != nil {
+		return
 	}
-	return data, nil
+	util.EmitNormalEvent(ctx, n.recorder, pod, util.AllResourcesReady)
+	return
 }
 
-func (n *nodeClient) AddBAFinalizer(ctx context.Context, ba *v1alpha1.BucketAccess, BAFinalizer string) error {
-	controllerutil.AddFinalizer(ba, BAFinalizer)
-	if _, err := n.cosiClient.BucketAccesses().Update(ctx, ba, metav1.UpdateOptions{}); err != nil {
-		return err
+func (n *nodeClient) GetSecret(ctx context.Context, pod *v1.Pod, ba *v1alpha1.BucketAccess) (*v1.Secret, error) {
+	logging.FromContext(ctx).V(4).Info("getting minted secret", "mintedSecret", klog.KRef(ba.Status.MintedSecret.Namespace, ba.Status.MintedSecret.Name))
+	secret, err := n.kubeClient.CoreV1().Secrets(ba.Status.MintedSecret.Namespace).Get(ctx, ba.Status.MintedSecret.Name, metav1.GetOptions{})
+	if err != nil {
+		n.emitWarningEvent(ctx, util.MintedSecretNotFound, pod, ba)
+		return nil, errors.Wrap(err, util.WrapErrorGetSecretFailed)
 	}
-	return nil
+	return secret, nil
 }
 
-func (n *nodeClient) RemoveBAFinalizer(ctx context.Context, ba *v1alpha1.BucketAccess, BAFinalizer string) error {
-	controllerutil.RemoveFinalizer(ba, BAFinalizer)
-	if _, err := n.cosiClient.BucketAccesses().Update(ctx, ba, metav1.UpdateOptions{}); err != nil {
-		return err
-	}
-	return nil
+func (n *nodeClient) UpdateBAFinalizers(ctx context.Context, baName string, add map[string]FinalizerOwner, remove []string) (*v1alpha1.BucketAccess, error) {
+	return UpdateBAFinalizers(ctx, n.cosiClient, baName, add, remove)
 }
 
 func (n *nodeClient) Recorder() record.EventRecorder {
 	return n.recorder
 }
+
+func (n *nodeClient) AddBAEventHandler(handler cache.ResourceEventHandler) {
+	n.baInformer.AddEventHandler(handler)
+}
+
+func (n *nodeClient) Start(stopCh <-chan struct{}) {
+	go n.baInformer.Run(stopCh)
+}
+
+func (n *nodeClient) HasSynced() bool {
+	return n.baInformer.HasSynced()
+}
+
+// Ping requests the version of the API server. Unlike ServerVersion of the discovery client, the
+// request honors the context, so that readiness checks can time it out.
+func (n *nodeClient) Ping(ctx context.Context) error {
+	if err := n.kubeClient.Discovery().RESTClient().Get().AbsPath("/version").Do(ctx).Error(); err != nil {
+		return errors.Wrap(err, util.WrapErrorAPIServerUnreachable)
+	}
+	return nil
+}
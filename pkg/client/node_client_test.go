@@ -2,17 +2,23 @@ package client
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"k8s.io/client-go/tools/record"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes"
 	k8sfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
 
 	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
 	cosifake "sigs.k8s.io/container-object-storage-interface-api/clientset/fake"
@@ -191,6 +197,21 @@ func TestGetBA(t *testing.T) {
 				err: util.ErrorBANoAccess,
 			},
 		},
+		"FailBeingDeleted": {
+			args: args{
+				prepare: func(cs kubernetes.Interface, cosi cs.ObjectstorageV1alpha1Interface) {
+					ba := testutils.GetBA()
+					now := metav1.Now()
+					ba.DeletionTimestamp = &now
+					_, _ = cosi.BucketAccesses().Create(ctx, ba, metav1.CreateOptions{})
+				},
+				baName: "bucketAccessName",
+			},
+			want: want{
+				ba:  nil,
+				err: util.ErrorBADeleting,
+			},
+		},
 		"FailNoMintedSecretRef": {
 			args: args{
 				prepare: func(cs kubernetes.Interface, cosi cs.ObjectstorageV1alpha1Interface) {
@@ -230,6 +251,129 @@ func TestGetBA(t *testing.T) {
 	}
 }
 
+func TestUpdateBAFinalizers(t *testing.T) {
+	owner := FinalizerOwner{Node: "node", PodNamespace: testutils.Namespace, PodName: "podName", PodUID: "uid"}
+	ownerJSON := `{"node":"node","podNamespace":"` + testutils.Namespace + `","podName":"podName","podUID":"uid"}`
+
+	type args struct {
+		existing    []string
+		annotations map[string]string
+		add         map[string]FinalizerOwner
+		remove      []string
+	}
+
+	type want struct {
+		finalizers  []string
+		annotations map[string]string
+	}
+
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"Add": {
+			args: args{
+				existing: []string{"a"},
+				add:      map[string]FinalizerOwner{"b": owner},
+			},
+			want: want{
+				finalizers:  []string{"a", "b"},
+				annotations: map[string]string{"b": ownerJSON},
+			},
+		},
+		"AddAndRemove": {
+			args: args{
+				existing:    []string{"a", "legacy", "old"},
+				annotations: map[string]string{"old": ownerJSON, "other": "value"},
+				add:         map[string]FinalizerOwner{"b": owner},
+				remove:      []string{"legacy", "old"},
+			},
+			want: want{
+				finalizers:  []string{"a", "b"},
+				annotations: map[string]string{"b": ownerJSON, "other": "value"},
+			},
+		},
+		"Unchanged": {
+			args: args{
+				existing:    []string{"a"},
+				annotations: map[string]string{"a": ownerJSON},
+				add:         map[string]FinalizerOwner{"a": owner},
+				remove:      []string{"missing"},
+			},
+			want: want{
+				finalizers:  []string{"a"},
+				annotations: map[string]string{"a": ownerJSON},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			nc := &nodeClient{
+				kubeClient: k8sfake.NewSimpleClientset(),
+				cosiClient: cosifake.NewSimpleClientset().ObjectstorageV1alpha1(),
+				recorder:   record.NewFakeRecorder(10),
+			}
+
+			ba := testutils.GetBA()
+			ba.Finalizers = tc.args.existing
+			ba.Annotations = tc.args.annotations
+			_, _ = nc.cosiClient.BucketAccesses().Create(ctx, ba, metav1.CreateOptions{})
+
+			updated, err := nc.UpdateBAFinalizers(ctx, ba.Name, tc.args.add, tc.args.remove)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(tc.want.finalizers, updated.Finalizers); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+
+			stored, _ := nc.cosiClient.BucketAccesses().Get(ctx, ba.Name, metav1.GetOptions{})
+			if diff := cmp.Diff(tc.want.finalizers, stored.Finalizers); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.annotations, stored.Annotations); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestUpdateBAFinalizersDeleted(t *testing.T) {
+	cosiClient := cosifake.NewSimpleClientset().ObjectstorageV1alpha1()
+
+	// nothing is left to protect once the bucketAccess is gone
+	ba, err := UpdateBAFinalizers(ctx, cosiClient, "deleted", nil, []string{"a"})
+	if err != nil {
+		t.Errorf("expected removing finalizers from a deleted bucketAccess to succeed, got %v", err)
+	}
+	if ba != nil {
+		t.Errorf("expected no bucketAccess, got %v", ba)
+	}
+
+	if _, err := UpdateBAFinalizers(ctx, cosiClient, "deleted", map[string]FinalizerOwner{"a": {}}, nil); !kerrors.IsNotFound(err) {
+		t.Errorf("expected adding finalizers to a deleted bucketAccess to fail with NotFound, got %v", err)
+	}
+}
+
+func TestFinalizerOwners(t *testing.T) {
+	ba := testutils.GetBA()
+	ba.Finalizers = []string{"owned", "unowned", "invalid"}
+	ba.Annotations = map[string]string{
+		"owned":   `{"node":"node","podNamespace":"default","podName":"app"}`,
+		"invalid": "not json",
+		"stale":   `{"node":"node","podNamespace":"default","podName":"gone"}`,
+	}
+
+	want := map[string]FinalizerOwner{
+		"owned": {Node: "node", PodNamespace: "default", PodName: "app"},
+	}
+	if diff := cmp.Diff(want, FinalizerOwners(ba)); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+}
+
 func TestGetBR(t *testing.T) {
 	type args struct {
 		prepare func(cs kubernetes.Interface, cosi cs.ObjectstorageV1alpha1Interface)
@@ -405,6 +549,88 @@ func TestGetB(t *testing.T) {
 	}
 }
 
+func TestGetBucketInfo(t *testing.T) {
+	type args struct {
+		prepare func(cs kubernetes.Interface, cosi cs.ObjectstorageV1alpha1Interface)
+		brName  string
+	}
+
+	type want struct {
+		b   *v1alpha1.Bucket
+		err error
+	}
+
+	cases := map[string]struct {
+		args
+		want
+	}{
+		"Successful": {
+			args: args{
+				prepare: func(cs kubernetes.Interface, cosi cs.ObjectstorageV1alpha1Interface) {
+					_, _ = cs.CoreV1().Pods(testutils.Namespace).Create(ctx, testutils.GetPod(), metav1.CreateOptions{})
+					_, _ = cosi.BucketRequests(testutils.Namespace).Create(ctx, testutils.GetBR(), metav1.CreateOptions{})
+					_, _ = cosi.Buckets().Create(ctx, testutils.GetB(), metav1.CreateOptions{})
+				},
+				brName: testutils.GetBR().Name,
+			},
+			want: want{
+				b:   testutils.GetB(),
+				err: nil,
+			},
+		},
+		"BRNotAvailable": {
+			args: args{
+				prepare: func(cs kubernetes.Interface, cosi cs.ObjectstorageV1alpha1Interface) {
+					br := testutils.GetBR()
+					br.Status.BucketAvailable = false
+					_, _ = cs.CoreV1().Pods(testutils.Namespace).Create(ctx, testutils.GetPod(), metav1.CreateOptions{})
+					_, _ = cosi.BucketRequests(testutils.Namespace).Create(ctx, br, metav1.CreateOptions{})
+				},
+				brName: testutils.GetBR().Name,
+			},
+			want: want{
+				b:   nil,
+				err: util.ErrorBRNotAvailable,
+			},
+		},
+		"BucketNotFound": {
+			args: args{
+				prepare: func(cs kubernetes.Interface, cosi cs.ObjectstorageV1alpha1Interface) {
+					_, _ = cs.CoreV1().Pods(testutils.Namespace).Create(ctx, testutils.GetPod(), metav1.CreateOptions{})
+					_, _ = cosi.BucketRequests(testutils.Namespace).Create(ctx, testutils.GetBR(), metav1.CreateOptions{})
+				},
+				brName: testutils.GetBR().Name,
+			},
+			want: want{
+				b:   nil,
+				err: errors.Wrap(fmt.Errorf("%s \"%s\" not found", "buckets.objectstorage.k8s.io", "bucketName"), util.WrapErrorGetBFailed),
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			nc := &nodeClient{
+				kubeClient: k8sfake.NewSimpleClientset(),
+				cosiClient: cosifake.NewSimpleClientset().ObjectstorageV1alpha1(),
+				recorder:   record.NewFakeRecorder(10),
+			}
+
+			tc.prepare(nc.kubeClient, nc.cosiClient)
+
+			b, _, err := nc.GetBucketInfo(ctx, tc.brName, testutils.GetPod().Name, testutils.Namespace)
+
+			if diff := cmp.Diff(tc.want.b, b); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+
+			if diff := cmp.Diff(tc.want.err, err, util.EquateErrors()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
 func TestGetResources(t *testing.T) {
 	type args struct {
 		prepare func(cs kubernetes.Interface, cosi cs.ObjectstorageV1alpha1Interface)
@@ -413,10 +639,9 @@ func TestGetResources(t *testing.T) {
 	}
 
 	type want struct {
-		b      *v1alpha1.Bucket
-		ba     *v1alpha1.BucketAccess
-		secret *corev1.Secret
-		err    error
+		b   *v1alpha1.Bucket
+		ba  *v1alpha1.BucketAccess
+		err error
 	}
 
 	cases := map[string]struct {
@@ -438,33 +663,73 @@ func TestGetResources(t *testing.T) {
 				barNs:   testutils.Namespace,
 			},
 			want: want{
-				b:      testutils.GetB(),
-				ba:     testutils.GetBA(),
-				secret: testutils.GetSecret(),
-				err:    nil,
+				b:   testutils.GetB(),
+				ba:  testutils.GetBA(),
+				err: nil,
 			},
 		},
-		"failedMissingBAR": {
+		"CrossNamespaceGranted": {
+			args: args{
+				prepare: func(cs kubernetes.Interface, cosi cs.ObjectstorageV1alpha1Interface) {
+					bar := testutils.GetBAR()
+					bar.Namespace = "shared"
+					_, _ = cosi.Buckets().Create(ctx, testutils.GetB(), metav1.CreateOptions{})
+					_, _ = cosi.BucketAccessRequests(bar.Namespace).Create(ctx, bar, metav1.CreateOptions{})
+					_, _ = cosi.BucketAccesses().Create(ctx, testutils.GetBA(), metav1.CreateOptions{})
+
+					_, _ = cs.CoreV1().ConfigMaps(bar.Namespace).Create(ctx, &corev1.ConfigMap{
+						ObjectMeta: metav1.ObjectMeta{Name: BARGrantsConfigMapName, Namespace: bar.Namespace},
+						Data:       map[string]string{testutils.Namespace: "otherBAR, bucketAccessRequestName"},
+					}, metav1.CreateOptions{})
+					_, _ = cs.CoreV1().Pods(testutils.Namespace).Create(ctx, testutils.GetPod(), metav1.CreateOptions{})
+				},
+				barName: "bucketAccessRequestName",
+				barNs:   "shared",
+			},
+			want: want{
+				b:  testutils.GetB(),
+				ba: testutils.GetBA(),
+			},
+		},
+		"CrossNamespaceNotGranted": {
 			args: args{
 				prepare: func(cs kubernetes.Interface, cosi cs.ObjectstorageV1alpha1Interface) {
+					bar := testutils.GetBAR()
+					bar.Namespace = "shared"
 					_, _ = cosi.Buckets().Create(ctx, testutils.GetB(), metav1.CreateOptions{})
+					_, _ = cosi.BucketAccessRequests(bar.Namespace).Create(ctx, bar, metav1.CreateOptions{})
 					_, _ = cosi.BucketAccesses().Create(ctx, testutils.GetBA(), metav1.CreateOptions{})
 
-					_, _ = cs.CoreV1().Secrets(testutils.Namespace).Create(ctx, testutils.GetSecret(), metav1.CreateOptions{})
+					_, _ = cs.CoreV1().ConfigMaps(bar.Namespace).Create(ctx, &corev1.ConfigMap{
+						ObjectMeta: metav1.ObjectMeta{Name: BARGrantsConfigMapName, Namespace: bar.Namespace},
+						Data:       map[string]string{"other": GrantAll},
+					}, metav1.CreateOptions{})
 					_, _ = cs.CoreV1().Pods(testutils.Namespace).Create(ctx, testutils.GetPod(), metav1.CreateOptions{})
 				},
 				barName: "bucketAccessRequestName",
-				barNs:   testutils.Namespace,
+				barNs:   "shared",
 			},
 			want: want{
-				err: errors.Wrap(fmt.Errorf("%s \"%s\" not found", "bucketaccessrequests.objectstorage.k8s.io", "bucketAccessRequestName"), util.WrapErrorGetBARFailed),
+				err: fmt.Errorf(util.ErrorTemplateBARNotGranted, "shared", "bucketAccessRequestName", testutils.Namespace),
 			},
 		},
-		"failedMissingBA": {
+		"CrossNamespaceNoGrants": {
+			args: args{
+				prepare: func(cs kubernetes.Interface, cosi cs.ObjectstorageV1alpha1Interface) {
+					_, _ = cs.CoreV1().Pods(testutils.Namespace).Create(ctx, testutils.GetPod(), metav1.CreateOptions{})
+				},
+				barName: "bucketAccessRequestName",
+				barNs:   "shared",
+			},
+			want: want{
+				err: fmt.Errorf(util.ErrorTemplateBARNotGranted, "shared", "bucketAccessRequestName", testutils.Namespace),
+			},
+		},
+		"failedMissingBAR": {
 			args: args{
 				prepare: func(cs kubernetes.Interface, cosi cs.ObjectstorageV1alpha1Interface) {
 					_, _ = cosi.Buckets().Create(ctx, testutils.GetB(), metav1.CreateOptions{})
-					_, _ = cosi.BucketAccessRequests(testutils.Namespace).Create(ctx, testutils.GetBAR(), metav1.CreateOptions{})
+					_, _ = cosi.BucketAccesses().Create(ctx, testutils.GetBA(), metav1.CreateOptions{})
 
 					_, _ = cs.CoreV1().Secrets(testutils.Namespace).Create(ctx, testutils.GetSecret(), metav1.CreateOptions{})
 					_, _ = cs.CoreV1().Pods(testutils.Namespace).Create(ctx, testutils.GetPod(), metav1.CreateOptions{})
@@ -473,14 +738,14 @@ func TestGetResources(t *testing.T) {
 				barNs:   testutils.Namespace,
 			},
 			want: want{
-				err: errors.Wrap(fmt.Errorf("%s \"%s\" not found", "bucketaccesses.objectstorage.k8s.io", "bucketAccessName"), util.WrapErrorGetBAFailed),
+				err: errors.Wrap(fmt.Errorf("%s \"%s\" not found", "bucketaccessrequests.objectstorage.k8s.io", "bucketAccessRequestName"), util.WrapErrorGetBARFailed),
 			},
 		},
-		"failedMissingB": {
+		"failedMissingBA": {
 			args: args{
 				prepare: func(cs kubernetes.Interface, cosi cs.ObjectstorageV1alpha1Interface) {
+					_, _ = cosi.Buckets().Create(ctx, testutils.GetB(), metav1.CreateOptions{})
 					_, _ = cosi.BucketAccessRequests(testutils.Namespace).Create(ctx, testutils.GetBAR(), metav1.CreateOptions{})
-					_, _ = cosi.BucketAccesses().Create(ctx, testutils.GetBA(), metav1.CreateOptions{})
 
 					_, _ = cs.CoreV1().Secrets(testutils.Namespace).Create(ctx, testutils.GetSecret(), metav1.CreateOptions{})
 					_, _ = cs.CoreV1().Pods(testutils.Namespace).Create(ctx, testutils.GetPod(), metav1.CreateOptions{})
@@ -489,25 +754,24 @@ func TestGetResources(t *testing.T) {
 				barNs:   testutils.Namespace,
 			},
 			want: want{
-				ba:  testutils.GetBA(),
-				err: errors.Wrap(fmt.Errorf("%s \"%s\" not found", "buckets.objectstorage.k8s.io", "bucketName"), util.WrapErrorGetBFailed),
+				err: errors.Wrap(fmt.Errorf("%s \"%s\" not found", "bucketaccesses.objectstorage.k8s.io", "bucketAccessName"), util.WrapErrorGetBAFailed),
 			},
 		},
-		"failedMissingSecret": {
+		"failedMissingB": {
 			args: args{
 				prepare: func(cs kubernetes.Interface, cosi cs.ObjectstorageV1alpha1Interface) {
-					_, _ = cosi.Buckets().Create(ctx, testutils.GetB(), metav1.CreateOptions{})
 					_, _ = cosi.BucketAccessRequests(testutils.Namespace).Create(ctx, testutils.GetBAR(), metav1.CreateOptions{})
 					_, _ = cosi.BucketAccesses().Create(ctx, testutils.GetBA(), metav1.CreateOptions{})
+
+					_, _ = cs.CoreV1().Secrets(testutils.Namespace).Create(ctx, testutils.GetSecret(), metav1.CreateOptions{})
 					_, _ = cs.CoreV1().Pods(testutils.Namespace).Create(ctx, testutils.GetPod(), metav1.CreateOptions{})
 				},
 				barName: "bucketAccessRequestName",
 				barNs:   testutils.Namespace,
 			},
 			want: want{
-				b:   testutils.GetB(),
 				ba:  testutils.GetBA(),
-				err: errors.Wrap(fmt.Errorf("%s \"%s\" not found", "secrets", "mintedSecretName"), util.WrapErrorGetSecretFailed),
+				err: errors.Wrap(fmt.Errorf("%s \"%s\" not found", "buckets.objectstorage.k8s.io", "bucketName"), util.WrapErrorGetBFailed),
 			},
 		},
 	}
@@ -522,7 +786,7 @@ func TestGetResources(t *testing.T) {
 
 			tc.prepare(nc.kubeClient, nc.cosiClient)
 
-			b, ba, secret, _, err := nc.GetResources(ctx, tc.barName, "podName", testutils.Namespace)
+			b, ba, _, err := nc.GetResources(ctx, tc.barName, tc.barNs, "podName", testutils.Namespace)
 
 			if diff := cmp.Diff(tc.want.b, b); diff != "" {
 				t.Errorf("r: -want, +got:\n%s", diff)
@@ -532,10 +796,6 @@ func TestGetResources(t *testing.T) {
 				t.Errorf("r: -want, +got:\n%s", diff)
 			}
 
-			if diff := cmp.Diff(tc.want.secret, secret); diff != "" {
-				t.Errorf("r: -want, +got:\n%s", diff)
-			}
-
 			if diff := cmp.Diff(tc.want.err, err, util.EquateErrors()); diff != "" {
 				t.Errorf("r: -want, +got:\n%s", diff)
 			}
@@ -543,102 +803,39 @@ func TestGetResources(t *testing.T) {
 	}
 }
 
-func TestGetProtocol(t *testing.T) {
-	type args struct {
-		prepare func(bkt *v1alpha1.Bucket) *v1alpha1.Bucket
-	}
-
+func TestGetSecret(t *testing.T) {
 	type want struct {
-		data string
-		err  error
+		secret *corev1.Secret
+		err    error
 	}
 
 	cases := map[string]struct {
-		args
+		secrets []runtime.Object
 		want
 	}{
-		"SuccessfulS3": {
-			args: args{
-				prepare: func(bkt *v1alpha1.Bucket) *v1alpha1.Bucket {
-					bkt.Spec.Protocol = v1alpha1.Protocol{
-						S3: &v1alpha1.S3Protocol{
-							Endpoint:         "endpoint",
-							BucketName:       "bucketName",
-							Region:           "region",
-							SignatureVersion: "signatureVersion",
-						},
-					}
-					return bkt
-				},
-			},
-			want: want{
-				data: `{"endpoint":"endpoint", "bucketName":"bucketName", "region":"region", "signatureVersion":"signatureVersion"}`,
-				err:  nil,
-			},
-		},
-		"SuccessfulGCP": {
-			args: args{
-				prepare: func(bkt *v1alpha1.Bucket) *v1alpha1.Bucket {
-					bkt.Spec.Protocol = v1alpha1.Protocol{
-						GCS: &v1alpha1.GCSProtocol{
-							BucketName:     "bucketName",
-							PrivateKeyName: "privateKeyName",
-							ProjectID:      "projectID",
-							ServiceAccount: "serviceAccount",
-						},
-					}
-					return bkt
-				},
-			},
-			want: want{
-				data: `{"bucketName":"bucketName", "privateKeyName":"privateKeyName", "projectID":"projectID", "serviceAccount":"serviceAccount"}`,
-				err:  nil,
-			},
-		},
-		"SuccessfulAzure": {
-			args: args{
-				prepare: func(bkt *v1alpha1.Bucket) *v1alpha1.Bucket {
-					bkt.Spec.Protocol = v1alpha1.Protocol{
-						AzureBlob: &v1alpha1.AzureProtocol{
-							ContainerName:  "containerName",
-							StorageAccount: "storageAccount",
-						},
-					}
-					return bkt
-				},
-			},
+		"Successful": {
+			secrets: []runtime.Object{testutils.GetSecret()},
 			want: want{
-				data: `{"containerName":"containerName", "storageAccount":"storageAccount"}`,
-				err:  nil,
+				secret: testutils.GetSecret(),
 			},
 		},
-		"FailMissingProtocol": {
-			args: args{
-				prepare: func(bkt *v1alpha1.Bucket) *v1alpha1.Bucket {
-					bkt.Spec.Protocol = v1alpha1.Protocol{}
-					return bkt
-				},
-			},
+		"failedMissingSecret": {
 			want: want{
-				err: util.ErrorInvalidProtocol,
+				err: errors.Wrap(fmt.Errorf("%s \"%s\" not found", "secrets", "mintedSecretName"), util.WrapErrorGetSecretFailed),
 			},
 		},
 	}
 
 	for name, tc := range cases {
 		t.Run(name, func(t *testing.T) {
+			nc := &nodeClient{
+				kubeClient: k8sfake.NewSimpleClientset(tc.secrets...),
+				recorder:   record.NewFakeRecorder(10),
+			}
 
-			bkt := testutils.GetB()
-
-			data, err := GetProtocol(tc.prepare(bkt))
-
-			var wantData interface{}
-			var haveData interface{}
-
-			_ = json.Unmarshal(data, &haveData)
-			_ = json.Unmarshal([]byte(tc.data), &wantData)
+			secret, err := nc.GetSecret(ctx, testutils.GetPod(), testutils.GetBA())
 
-			if diff := cmp.Diff(wantData, haveData); diff != "" {
+			if diff := cmp.Diff(tc.want.secret, secret); diff != "" {
 				t.Errorf("r: -want, +got:\n%s", diff)
 			}
 
@@ -648,3 +845,63 @@ func TestGetProtocol(t *testing.T) {
 		})
 	}
 }
+
+func TestNewRecorder(t *testing.T) {
+	kube := k8sfake.NewSimpleClientset()
+	created := make(chan *corev1.Event, 1)
+	kube.PrependReactor("create", "events", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		event := action.(k8stesting.CreateAction).GetObject().(*corev1.Event)
+		created <- event
+		return true, event, nil
+	})
+	recorder := NewRecorder(kube, "driver", "node")
+
+	ba := testutils.GetBA()
+	ba.UID = "uid"
+	util.EmitNormalEvent(util.WithVolumeID(ctx, "volID"), recorder, ba, util.SuccessfullyPublishedVolume)
+
+	var event *corev1.Event
+	select {
+	case event = <-created:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the event on the bucketAccess to be recorded")
+	}
+
+	want := corev1.ObjectReference{
+		Kind:       "BucketAccess",
+		APIVersion: v1alpha1.SchemeGroupVersion.String(),
+		Name:       ba.Name,
+		UID:        ba.UID,
+	}
+	if diff := cmp.Diff(want, event.InvolvedObject); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+}
+
+func TestPing(t *testing.T) {
+	// the API server only answers once the client gives up
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	kube, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nc := &nodeClient{kubeClient: kube}
+
+	ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- nc.Ping(ctx) }()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("expected the stalled API server to fail the ping")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the ping to honor the context")
+	}
+}
@@ -2,17 +2,34 @@ package client
 
 import (
 	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 
 	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
 )
 
+// FsStats describes the filesystem backing a path.
+type FsStats struct {
+	CapacityBytes  int64
+	AvailableBytes int64
+	Inodes         int64
+	InodesFree     int64
+}
+
 type ProvisionerClient interface {
 	MkdirAll(path string, perm os.FileMode) error
 	RemoveAll(path string) error
 	WriteFile(data []byte, filepath string) error
 	ReadFile(filename string) ([]byte, error)
+	ReadDir(dirname string) ([]os.FileInfo, error)
+	Rename(oldpath, newpath string) error
+
+	// DirUsage returns the bytes and inodes consumed by path and everything below it.
+	DirUsage(path string) (bytes, inodes int64, err error)
+	// FsStats returns the capacity of the filesystem containing path.
+	FsStats(path string) (FsStats, error)
 }
 
 func NewProvisionerClient() ProvisionerClient {
@@ -27,6 +44,14 @@ func (p provisionerClient) ReadFile(filename string) ([]byte, error) {
 	return ioutil.ReadFile(filename)
 }
 
+func (p provisionerClient) ReadDir(dirname string) ([]os.FileInfo, error) {
+	return ioutil.ReadDir(dirname)
+}
+
+func (p provisionerClient) Rename(oldpath, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
+
 func (p provisionerClient) MkdirAll(path string, perm os.FileMode) error {
 	return os.MkdirAll(path, perm)
 }
@@ -38,13 +63,38 @@ func (p provisionerClient) RemoveAll(path string) error {
 func (p provisionerClient) WriteFile(data []byte, filepath string) error {
 	file, err := os.OpenFile(filepath, os.O_CREATE|os.O_WRONLY|os.O_EXCL, os.FileMode(0440))
 	if err != nil {
-		return util.LogErr(errors.Wrap(err, util.WrapErrorCreatingFile))
+		return errors.Wrap(err, util.WrapErrorCreatingFile)
 	}
 
 	defer file.Close()
 	_, err = file.Write(data)
 	if err != nil {
-		return util.LogErr(errors.Wrap(err, util.WrapErrorWritingToFile))
+		return errors.Wrap(err, util.WrapErrorWritingToFile)
 	}
 	return nil
 }
+
+func (p provisionerClient) DirUsage(path string) (bytes, inodes int64, err error) {
+	err = filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		inodes++
+		bytes += info.Size()
+		return nil
+	})
+	return bytes, inodes, err
+}
+
+func (p provisionerClient) FsStats(path string) (FsStats, error) {
+	st := unix.Statfs_t{}
+	if err := unix.Statfs(path, &st); err != nil {
+		return FsStats{}, err
+	}
+	return FsStats{
+		CapacityBytes:  int64(st.Blocks) * int64(st.Bsize),
+		AvailableBytes: int64(st.Bavail) * int64(st.Bsize),
+		Inodes:         int64(st.Files),
+		InodesFree:     int64(st.Ffree),
+	}, nil
+}
@@ -0,0 +1,54 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+// serviceAccountToken is a token of the pod, as passed by the kubelet.
+type serviceAccountToken struct {
+	Token string `json:"token"`
+}
+
+// ParseWebIdentity returns the audience of the service account token a volume requests, and the
+// AWS role to exchange it for. Both are empty for volumes without workload identity.
+func ParseWebIdentity(volCtx map[string]string) (audience, roleARN string, err error) {
+	audience, roleARN = volCtx[TokenAudienceKey], volCtx[RoleARNKey]
+	if mountPath, ok := volCtx[MountPathKey]; ok && !path.IsAbs(mountPath) {
+		return "", "", fmt.Errorf(util.ErrorTemplateMountPathInvalid, mountPath)
+	}
+	if roleARN == "" {
+		return audience, "", nil
+	}
+	if audience == "" {
+		return "", "", util.ErrorRoleARNWithoutAudience
+	}
+	if !strings.HasPrefix(roleARN, "arn:") {
+		return "", "", fmt.Errorf(util.ErrorTemplateRoleARNInvalid, roleARN)
+	}
+	return audience, roleARN, nil
+}
+
+// ServiceAccountToken returns the token of the pod for the audience, which the kubelet only passes
+// when it is requested in the tokenRequests of the CSIDriver.
+func ServiceAccountToken(volCtx map[string]string, audience string) (string, error) {
+	raw, ok := volCtx[ServiceAccountTokensKey]
+	if !ok {
+		return "", fmt.Errorf(util.ErrorTemplateTokenNotRequested, audience)
+	}
+	tokens := map[string]serviceAccountToken{}
+	if err := json.Unmarshal([]byte(raw), &tokens); err != nil {
+		return "", errors.Wrap(err, util.WrapErrorFailedToParseTokens)
+	}
+	token, ok := tokens[audience]
+	if !ok || token.Token == "" {
+		return "", fmt.Errorf(util.ErrorTemplateTokenNotRequested, audience)
+	}
+	return token.Token, nil
+}
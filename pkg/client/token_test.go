@@ -0,0 +1,90 @@
+package client
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+func TestParseWebIdentity(t *testing.T) {
+	type want struct {
+		audience string
+		roleARN  string
+		err      error
+	}
+
+	cases := map[string]struct {
+		volCtx map[string]string
+		want   want
+	}{
+		"Unset": {
+			volCtx: map[string]string{BrNameKey: "br"},
+		},
+		"AudienceOnly": {
+			volCtx: map[string]string{TokenAudienceKey: "storage.example.com"},
+			want:   want{audience: "storage.example.com"},
+		},
+		"Role": {
+			volCtx: map[string]string{TokenAudienceKey: "sts.amazonaws.com", RoleARNKey: "arn:aws:iam::123456789012:role/reader"},
+			want:   want{audience: "sts.amazonaws.com", roleARN: "arn:aws:iam::123456789012:role/reader"},
+		},
+		"RoleWithoutAudience": {
+			volCtx: map[string]string{RoleARNKey: "arn:aws:iam::123456789012:role/reader"},
+			want:   want{err: util.ErrorRoleARNWithoutAudience},
+		},
+		"InvalidRole": {
+			volCtx: map[string]string{TokenAudienceKey: "sts.amazonaws.com", RoleARNKey: "reader"},
+			want:   want{err: fmt.Errorf(util.ErrorTemplateRoleARNInvalid, "reader")},
+		},
+		"RelativeMountPath": {
+			volCtx: map[string]string{TokenAudienceKey: "sts.amazonaws.com", MountPathKey: "cosi"},
+			want:   want{err: fmt.Errorf(util.ErrorTemplateMountPathInvalid, "cosi")},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			audience, roleARN, err := ParseWebIdentity(tc.volCtx)
+			if diff := cmp.Diff(tc.want, want{audience: audience, roleARN: roleARN, err: err}, cmp.AllowUnexported(want{}), util.EquateErrors()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestServiceAccountToken(t *testing.T) {
+	type want struct {
+		token string
+		err   error
+	}
+
+	cases := map[string]struct {
+		volCtx map[string]string
+		want   want
+	}{
+		"Requested": {
+			volCtx: map[string]string{ServiceAccountTokensKey: `{"sts.amazonaws.com":{"token":"token","expirationTimestamp":"2021-01-01T13:00:00Z"}}`},
+			want:   want{token: "token"},
+		},
+		"OtherAudience": {
+			volCtx: map[string]string{ServiceAccountTokensKey: `{"storage.example.com":{"token":"token"}}`},
+			want:   want{err: fmt.Errorf(util.ErrorTemplateTokenNotRequested, "sts.amazonaws.com")},
+		},
+		"NoTokens": {
+			volCtx: map[string]string{},
+			want:   want{err: fmt.Errorf(util.ErrorTemplateTokenNotRequested, "sts.amazonaws.com")},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			token, err := ServiceAccountToken(tc.volCtx, "sts.amazonaws.com")
+			if diff := cmp.Diff(tc.want, want{token: token, err: err}, cmp.AllowUnexported(want{}), util.EquateErrors()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+
+	cs "sigs.k8s.io/container-object-storage-interface-api/clientset/typed/objectstorage.k8s.io/v1alpha1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/logging"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/node"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+// Collector removes the finalizers nodes added to bucketAccesses for volumes which will never be
+// unpublished, because their pod or their node no longer exists. Only finalizers of the adapter
+// with a recorded owner, or legacy finalizers naming their pod, are considered, and a finalizer is
+// only removed once it has been found stale for the whole grace period, so that volumes being
+// published are left alone.
+type Collector struct {
+	cosiClient  cs.ObjectstorageV1alpha1Interface
+	kubeClient  kubernetes.Interface
+	recorder    record.EventRecorder
+	gracePeriod time.Duration
+	clock       clock.Clock
+
+	// suspects holds when each finalizer, keyed by bucketAccess and finalizer, was first found
+	// stale.
+	suspects map[string]time.Time
+}
+
+func NewCollector(cosiClient cs.ObjectstorageV1alpha1Interface, kubeClient kubernetes.Interface, recorder record.EventRecorder, gracePeriod time.Duration) *Collector {
+	return &Collector{
+		cosiClient:  cosiClient,
+		kubeClient:  kubeClient,
+		recorder:    recorder,
+		gracePeriod: gracePeriod,
+		clock:       clock.RealClock{},
+		suspects:    map[string]time.Time{},
+	}
+}
+
+// Run collects stale finalizers every interval until the context is done.
+func (c *Collector) Run(ctx context.Context, interval time.Duration) {
+	logging.FromContext(ctx).Info("collecting stale finalizers", "interval", interval, "gracePeriod", c.gracePeriod)
+	wait.UntilWithContext(ctx, c.Collect, interval)
+}
+
+// Collect runs a single pass over all bucketAccesses, removing the finalizers found stale for
+// longer than the grace period.
+func (c *Collector) Collect(ctx context.Context) {
+	bas, err := c.cosiClient.BucketAccesses().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		logging.FromContext(ctx).Error(err, "failed to list bucketAccesses")
+		return
+	}
+
+	now := c.clock.Now()
+	suspects := map[string]time.Time{}
+	for i := range bas.Items {
+		ba := &bas.Items[i]
+		owners := client.FinalizerOwners(ba)
+		for _, f := range ba.Finalizers {
+			if !strings.HasPrefix(f, node.Finalizer) {
+				continue
+			}
+
+			var check func() (string, error)
+			var of string
+			ctx := logging.NewContext(ctx, "bucketAccess", ba.Name, "finalizer", f)
+			if owner, ok := owners[f]; ok {
+				ctx = logging.NewContext(ctx, "node", owner.Node, "pod", klog.KRef(owner.PodNamespace, owner.PodName))
+				check = func() (string, error) { return c.staleCause(ctx, owner) }
+				of = fmt.Sprintf("of pod %s/%s on node %s", owner.PodNamespace, owner.PodName, owner.Node)
+			} else if pods := node.LegacyFinalizerPods(f); len(pods) > 0 {
+				// finalizers of earlier releases name the pod, but not the node
+				check = func() (string, error) { return c.legacyStaleCause(ctx, pods) }
+				of = "of an earlier release"
+			} else {
+				continue
+			}
+			logger := logging.FromContext(ctx)
+
+			cause, err := check()
+			if err != nil {
+				logger.Error(err, "failed to check finalizer owner")
+				continue
+			}
+			if cause == "" {
+				continue
+			}
+
+			key := ba.Name + "/" + f
+			since, ok := c.suspects[key]
+			if !ok {
+				since = now
+			}
+			if now.Sub(since) < c.gracePeriod {
+				logger.V(4).Info("finalizer found stale", "cause", cause, "since", since)
+				suspects[key] = since
+				continue
+			}
+
+			// the owner may have come back while waiting, check again right before removing
+			if cause, err = check(); err != nil || cause == "" {
+				continue
+			}
+			updated, err := client.UpdateBAFinalizers(ctx, c.cosiClient, ba.Name, nil, []string{f})
+			if err != nil {
+				logger.Error(err, "failed to remove stale finalizer")
+				suspects[key] = since
+				continue
+			}
+			logger.Info("removed stale finalizer", "cause", cause)
+			if updated == nil {
+				// the bucketAccess is gone, there is nothing to report on
+				continue
+			}
+			util.EmitNormalEventf(ctx, c.recorder, updated, util.StaleFinalizerRemoved, "finalizer %s %s, %s", f, of, cause)
+		}
+	}
+	c.suspects = suspects
+}
+
+// legacyStaleCause returns why the volume owning a legacy finalizer, added for one of the given
+// pods, will never be unpublished, or an empty string if it may still be. Legacy finalizers do not
+// record the node, so they are only stale once none of the pods exists.
+func (c *Collector) legacyStaleCause(ctx context.Context, pods []types.NamespacedName) (string, error) {
+	for _, p := range pods {
+		_, err := c.kubeClient.CoreV1().Pods(p.Namespace).Get(ctx, p.Name, metav1.GetOptions{})
+		if err == nil {
+			return "", nil
+		}
+		if !kerrors.IsNotFound(err) {
+			return "", err
+		}
+	}
+	return "pod no longer exists", nil
+}
+
+// staleCause returns why the volume owning a finalizer will never be unpublished, or an empty
+// string if it may still be.
+func (c *Collector) staleCause(ctx context.Context, owner client.FinalizerOwner) (string, error) {
+	_, err := c.kubeClient.CoreV1().Nodes().Get(ctx, owner.Node, metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		return "node no longer exists", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	pod, err := c.kubeClient.CoreV1().Pods(owner.PodNamespace).Get(ctx, owner.PodName, metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		return "pod no longer exists", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if owner.PodUID != "" && pod.UID != owner.PodUID {
+		return fmt.Sprintf("pod was replaced by %s", pod.UID), nil
+	}
+	if pod.Spec.NodeName != "" && pod.Spec.NodeName != owner.Node {
+		return fmt.Sprintf("pod runs on node %s", pod.Spec.NodeName), nil
+	}
+	return "", nil
+}
@@ -0,0 +1,228 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/clock"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+
+	cosifake "sigs.k8s.io/container-object-storage-interface-api/clientset/fake"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/node"
+	testutils "sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util/test"
+)
+
+const (
+	nodeName    = "node"
+	gracePeriod = 5 * time.Minute
+)
+
+var (
+	ctx     = context.Background()
+	testNow = time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	volFinalizer    = node.Finalizer + "-0123456789abcdef"
+	legacyFinalizer = node.Finalizer + "-" + testutils.Namespace + "-legacy-pod"
+)
+
+func getNode() *v1.Node {
+	return &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: nodeName}}
+}
+
+func getPod() *v1.Pod {
+	pod := testutils.GetPod()
+	pod.UID = "uid"
+	pod.Spec.NodeName = nodeName
+	return pod
+}
+
+func owner() string {
+	return `{"node":"` + nodeName + `","podNamespace":"` + testutils.Namespace + `","podName":"podName","podUID":"uid"}`
+}
+
+func TestCollect(t *testing.T) {
+	type args struct {
+		objects     []runtime.Object
+		finalizers  []string
+		annotations map[string]string
+	}
+
+	type want struct {
+		finalizers []string
+		events     int
+	}
+
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"Alive": {
+			args: args{
+				objects:     []runtime.Object{getNode(), getPod()},
+				finalizers:  []string{volFinalizer},
+				annotations: map[string]string{volFinalizer: owner()},
+			},
+			want: want{finalizers: []string{volFinalizer}},
+		},
+		"NodeGone": {
+			args: args{
+				objects:     []runtime.Object{getPod()},
+				finalizers:  []string{volFinalizer},
+				annotations: map[string]string{volFinalizer: owner()},
+			},
+			want: want{events: 1},
+		},
+		"PodGone": {
+			args: args{
+				objects:     []runtime.Object{getNode()},
+				finalizers:  []string{volFinalizer, "other"},
+				annotations: map[string]string{volFinalizer: owner()},
+			},
+			want: want{finalizers: []string{"other"}, events: 1},
+		},
+		"PodReplaced": {
+			args: args{
+				objects: []runtime.Object{getNode(), func() *v1.Pod {
+					pod := getPod()
+					pod.UID = "other"
+					return pod
+				}()},
+				finalizers:  []string{volFinalizer},
+				annotations: map[string]string{volFinalizer: owner()},
+			},
+			want: want{events: 1},
+		},
+		"PodMoved": {
+			args: args{
+				objects: []runtime.Object{getNode(), func() *v1.Pod {
+					pod := getPod()
+					pod.Spec.NodeName = "other"
+					return pod
+				}()},
+				finalizers:  []string{volFinalizer},
+				annotations: map[string]string{volFinalizer: owner()},
+			},
+			want: want{events: 1},
+		},
+		"NoOwner": {
+			args: args{
+				finalizers: []string{volFinalizer},
+			},
+			want: want{finalizers: []string{volFinalizer}},
+		},
+		"LegacyAlive": {
+			args: args{
+				objects: []runtime.Object{func() *v1.Pod {
+					pod := getPod()
+					pod.Name = "legacy-pod"
+					return pod
+				}()},
+				finalizers: []string{legacyFinalizer},
+			},
+			want: want{finalizers: []string{legacyFinalizer}},
+		},
+		"LegacyPodGone": {
+			args: args{
+				finalizers: []string{legacyFinalizer, volFinalizer},
+			},
+			want: want{finalizers: []string{volFinalizer}, events: 1},
+		},
+		"NotAdapterFinalizer": {
+			args: args{
+				finalizers:  []string{"other"},
+				annotations: map[string]string{"other": owner()},
+			},
+			want: want{finalizers: []string{"other"}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			ba := testutils.GetBA()
+			ba.Finalizers = tc.args.finalizers
+			ba.Annotations = tc.args.annotations
+			cosiClient := cosifake.NewSimpleClientset(ba).ObjectstorageV1alpha1()
+			recorder := record.NewFakeRecorder(10)
+			fakeClock := clock.NewFakeClock(testNow)
+
+			c := NewCollector(cosiClient, k8sfake.NewSimpleClientset(tc.args.objects...), recorder, gracePeriod)
+			c.clock = fakeClock
+
+			// nothing is removed before the grace period is over
+			c.Collect(ctx)
+			got, _ := cosiClient.BucketAccesses().Get(ctx, ba.Name, metav1.GetOptions{})
+			if diff := cmp.Diff(tc.args.finalizers, got.Finalizers); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+
+			fakeClock.Step(gracePeriod)
+			c.Collect(ctx)
+			got, _ = cosiClient.BucketAccesses().Get(ctx, ba.Name, metav1.GetOptions{})
+			if diff := cmp.Diff(tc.want.finalizers, got.Finalizers, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			if _, ok := got.Annotations[volFinalizer]; ok && tc.want.events > 0 {
+				t.Errorf("expected the owner of the removed finalizer to be removed, got %v", got.Annotations)
+			}
+			if diff := cmp.Diff(tc.want.events, len(recorder.Events)); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCollectOwnerReturns(t *testing.T) {
+	ba := testutils.GetBA()
+	ba.Finalizers = []string{volFinalizer}
+	ba.Annotations = map[string]string{volFinalizer: owner()}
+	cosiClient := cosifake.NewSimpleClientset(ba).ObjectstorageV1alpha1()
+	kubeClient := k8sfake.NewSimpleClientset(getPod())
+	fakeClock := clock.NewFakeClock(testNow)
+
+	c := NewCollector(cosiClient, kubeClient, record.NewFakeRecorder(10), gracePeriod)
+	c.clock = fakeClock
+
+	// the node is missing for a moment only, which restarts the grace period
+	c.Collect(ctx)
+	if _, err := kubeClient.CoreV1().Nodes().Create(ctx, getNode(), metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	fakeClock.Step(gracePeriod)
+	c.Collect(ctx)
+	if len(c.suspects) != 0 {
+		t.Errorf("expected no suspect finalizer, got %v", c.suspects)
+	}
+
+	if err := kubeClient.CoreV1().Nodes().Delete(ctx, nodeName, metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	c.Collect(ctx)
+	got, _ := cosiClient.BucketAccesses().Get(ctx, ba.Name, metav1.GetOptions{})
+	if diff := cmp.Diff([]string{volFinalizer}, got.Finalizers); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+}
@@ -17,13 +17,140 @@ limitations under the License.
 package controller
 
 import (
+	"context"
+
 	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/logging"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/node"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
 )
 
+var _ csi.ControllerServer = &ControllerServer{}
+
 func NewControllerServer() (csi.ControllerServer, error) {
 	return &ControllerServer{}, nil
 }
 
+// ControllerServer creates the volumes of persistentVolumeClaims, including the claims of generic
+// ephemeral volumes. Nothing is provisioned, the bucketAccessRequest to mount is recorded in the
+// volume context and resolved by NodePublishVolume, exactly like for inline ephemeral volumes.
 type ControllerServer struct {
 	csi.UnimplementedControllerServer
 }
+
+func (c *ControllerServer) CreateVolume(ctx context.Context, request *csi.CreateVolumeRequest) (*csi.CreateVolumeResponse, error) {
+	logging.FromContext(ctx).Info("CreateVolume", "name", request.GetName())
+
+	if request.GetName() == "" {
+		return nil, status.Error(codes.InvalidArgument, util.ErrorVolumeNameUnset.Error())
+	}
+	if err := validateCapabilities(request.GetVolumeCapabilities()); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if request.GetVolumeContentSource() != nil {
+		return nil, status.Error(codes.InvalidArgument, util.ErrorVolumeSourceUnsupported.Error())
+	}
+
+	barName, err := barReference(request.GetParameters())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	volCtx := map[string]string{
+		client.BarNameKey: barName,
+	}
+	// granting access is checked on the node, against the namespace of the pod
+	if barNs := request.GetParameters()[client.BarNamespaceKey]; barNs != "" {
+		volCtx[client.BarNamespaceKey] = barNs
+	}
+	if _, ok := request.GetParameters()[client.FormatKey]; ok {
+		format, err := node.ParseFormat(request.GetParameters())
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		volCtx[client.FormatKey] = format
+	}
+
+	return &csi.CreateVolumeResponse{
+		Volume: &csi.Volume{
+			// the name is derived from the UID of the claim, so retries yield the same volume
+			VolumeId:      client.PersistentVolumeIDPrefix + request.GetName(),
+			VolumeContext: volCtx,
+		},
+	}, nil
+}
+
+func (c *ControllerServer) DeleteVolume(ctx context.Context, request *csi.DeleteVolumeRequest) (*csi.DeleteVolumeResponse, error) {
+	logging.FromContext(ctx).Info("DeleteVolume", "volumeID", request.GetVolumeId())
+
+	if request.GetVolumeId() == "" {
+		return nil, status.Error(codes.InvalidArgument, util.ErrorVolumeIDUnset.Error())
+	}
+	// nothing was provisioned, the node server cleans up on unpublish
+	return &csi.DeleteVolumeResponse{}, nil
+}
+
+func (c *ControllerServer) ValidateVolumeCapabilities(ctx context.Context, request *csi.ValidateVolumeCapabilitiesRequest) (*csi.ValidateVolumeCapabilitiesResponse, error) {
+	if request.GetVolumeId() == "" {
+		return nil, status.Error(codes.InvalidArgument, util.ErrorVolumeIDUnset.Error())
+	}
+	if err := validateCapabilities(request.GetVolumeCapabilities()); err != nil {
+		if len(request.GetVolumeCapabilities()) == 0 {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		return &csi.ValidateVolumeCapabilitiesResponse{Message: err.Error()}, nil
+	}
+
+	return &csi.ValidateVolumeCapabilitiesResponse{
+		Confirmed: &csi.ValidateVolumeCapabilitiesResponse_Confirmed{
+			VolumeContext:      request.GetVolumeContext(),
+			VolumeCapabilities: request.GetVolumeCapabilities(),
+			Parameters:         request.GetParameters(),
+		},
+	}, nil
+}
+
+func (c *ControllerServer) ControllerGetCapabilities(ctx context.Context, request *csi.ControllerGetCapabilitiesRequest) (*csi.ControllerGetCapabilitiesResponse, error) {
+	return &csi.ControllerGetCapabilitiesResponse{
+		Capabilities: []*csi.ControllerServiceCapability{
+			{
+				Type: &csi.ControllerServiceCapability_Rpc{
+					Rpc: &csi.ControllerServiceCapability_RPC{
+						Type: csi.ControllerServiceCapability_RPC_CREATE_DELETE_VOLUME,
+					},
+				},
+			},
+		},
+	}, nil
+}
+
+// barReference returns the name of the bucketAccessRequest to mount. It is taken from the
+// parameters of the storageClass, defaulting to the name of the claim. The bucketAccessRequest is
+// looked up in the namespace of the pod, unless the bar-namespace parameter names another
+// namespace, which has to grant the namespace of the pod access to it.
+func barReference(params map[string]string) (string, error) {
+	if barName := params[client.BarNameKey]; barName != "" {
+		return barName, nil
+	}
+	if pvcName := params[client.PVCNameKey]; pvcName != "" {
+		return pvcName, nil
+	}
+	return "", util.ErrorBARReferenceUnset
+}
+
+// validateCapabilities accepts any access mode, the credentials are the same for every pod. Only
+// mount volumes can hold files though.
+func validateCapabilities(caps []*csi.VolumeCapability) error {
+	if len(caps) == 0 {
+		return util.ErrorVolumeCapabilitiesUnset
+	}
+	for _, c := range caps {
+		if c.GetBlock() != nil {
+			return util.ErrorBlockVolumeUnsupported
+		}
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/testing/protocmp"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+var mountCapability = &csi.VolumeCapability{
+	AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+	AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY},
+}
+
+func TestCreateVolume(t *testing.T) {
+	type want struct {
+		resp *csi.CreateVolumeResponse
+		err  error
+	}
+
+	cases := map[string]struct {
+		request *csi.CreateVolumeRequest
+		want
+	}{
+		"BARFromStorageClass": {
+			request: &csi.CreateVolumeRequest{
+				Name:               "pvc-1234",
+				VolumeCapabilities: []*csi.VolumeCapability{mountCapability},
+				Parameters: map[string]string{
+					client.BarNameKey: "sample-bar",
+					client.PVCNameKey: "data",
+				},
+			},
+			want: want{
+				resp: &csi.CreateVolumeResponse{
+					Volume: &csi.Volume{
+						VolumeId:      client.PersistentVolumeIDPrefix + "pvc-1234",
+						VolumeContext: map[string]string{client.BarNameKey: "sample-bar"},
+					},
+				},
+			},
+		},
+		"BARFromClaimName": {
+			request: &csi.CreateVolumeRequest{
+				Name:               "pvc-1234",
+				VolumeCapabilities: []*csi.VolumeCapability{mountCapability},
+				Parameters: map[string]string{
+					client.PVCNameKey:      "data",
+					client.PVCNamespaceKey: "default",
+				},
+			},
+			want: want{
+				resp: &csi.CreateVolumeResponse{
+					Volume: &csi.Volume{
+						VolumeId:      client.PersistentVolumeIDPrefix + "pvc-1234",
+						VolumeContext: map[string]string{client.BarNameKey: "data"},
+					},
+				},
+			},
+		},
+		"Format": {
+			request: &csi.CreateVolumeRequest{
+				Name:               "pvc-1234",
+				VolumeCapabilities: []*csi.VolumeCapability{mountCapability},
+				Parameters: map[string]string{
+					client.BarNameKey: "sample-bar",
+					client.FormatKey:  "sdk",
+				},
+			},
+			want: want{
+				resp: &csi.CreateVolumeResponse{
+					Volume: &csi.Volume{
+						VolumeId: client.PersistentVolumeIDPrefix + "pvc-1234",
+						VolumeContext: map[string]string{
+							client.BarNameKey: "sample-bar",
+							client.FormatKey:  "sdk",
+						},
+					},
+				},
+			},
+		},
+		"BARNamespace": {
+			request: &csi.CreateVolumeRequest{
+				Name:               "pvc-1234",
+				VolumeCapabilities: []*csi.VolumeCapability{mountCapability},
+				Parameters: map[string]string{
+					client.BarNameKey:      "sample-bar",
+					client.BarNamespaceKey: "shared",
+				},
+			},
+			want: want{
+				resp: &csi.CreateVolumeResponse{
+					Volume: &csi.Volume{
+						VolumeId: client.PersistentVolumeIDPrefix + "pvc-1234",
+						VolumeContext: map[string]string{
+							client.BarNameKey:      "sample-bar",
+							client.BarNamespaceKey: "shared",
+						},
+					},
+				},
+			},
+		},
+		"InvalidFormat": {
+			request: &csi.CreateVolumeRequest{
+				Name:               "pvc-1234",
+				VolumeCapabilities: []*csi.VolumeCapability{mountCapability},
+				Parameters: map[string]string{
+					client.BarNameKey: "sample-bar",
+					client.FormatKey:  "yaml",
+				},
+			},
+			want: want{
+				err: status.Error(codes.InvalidArgument, fmt.Sprintf(util.ErrorTemplateInvalidFormat, "yaml", "json, sdk")),
+			},
+		},
+		"BARUnset": {
+			request: &csi.CreateVolumeRequest{
+				Name:               "pvc-1234",
+				VolumeCapabilities: []*csi.VolumeCapability{mountCapability},
+			},
+			want: want{
+				err: status.Error(codes.InvalidArgument, util.ErrorBARReferenceUnset.Error()),
+			},
+		},
+		"NameUnset": {
+			request: &csi.CreateVolumeRequest{
+				VolumeCapabilities: []*csi.VolumeCapability{mountCapability},
+				Parameters:         map[string]string{client.BarNameKey: "sample-bar"},
+			},
+			want: want{
+				err: status.Error(codes.InvalidArgument, util.ErrorVolumeNameUnset.Error()),
+			},
+		},
+		"BlockVolume": {
+			request: &csi.CreateVolumeRequest{
+				Name: "pvc-1234",
+				VolumeCapabilities: []*csi.VolumeCapability{
+					{AccessType: &csi.VolumeCapability_Block{Block: &csi.VolumeCapability_BlockVolume{}}},
+				},
+				Parameters: map[string]string{client.BarNameKey: "sample-bar"},
+			},
+			want: want{
+				err: status.Error(codes.InvalidArgument, util.ErrorBlockVolumeUnsupported.Error()),
+			},
+		},
+		"VolumeSource": {
+			request: &csi.CreateVolumeRequest{
+				Name:                "pvc-1234",
+				VolumeCapabilities:  []*csi.VolumeCapability{mountCapability},
+				Parameters:          map[string]string{client.BarNameKey: "sample-bar"},
+				VolumeContentSource: &csi.VolumeContentSource{},
+			},
+			want: want{
+				err: status.Error(codes.InvalidArgument, util.ErrorVolumeSourceUnsupported.Error()),
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			c := &ControllerServer{}
+
+			resp, err := c.CreateVolume(context.Background(), tc.request)
+
+			if diff := cmp.Diff(tc.want.resp, resp, protocmp.Transform()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.err, err, util.EquateErrors()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDeleteVolume(t *testing.T) {
+	c := &ControllerServer{}
+
+	if _, err := c.DeleteVolume(context.Background(), &csi.DeleteVolumeRequest{VolumeId: client.PersistentVolumeIDPrefix + "pvc-1234"}); err != nil {
+		t.Errorf("expected volume to be deleted, got %v", err)
+	}
+
+	_, err := c.DeleteVolume(context.Background(), &csi.DeleteVolumeRequest{})
+	if diff := cmp.Diff(status.Error(codes.InvalidArgument, util.ErrorVolumeIDUnset.Error()), err, util.EquateErrors()); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package doctor
+
+import (
+	"context"
+	"fmt"
+
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// CSIDriverChecks returns the checks of the CSIDriver object registered for driverName. The
+// adapter reads the pod from the volume context, so kubelet has to pass pod info on mount, and it
+// only serves inline ephemeral volumes.
+func CSIDriverChecks(kube kubernetes.Interface, driverName string) []Check {
+	get := func(ctx context.Context) (*storagev1.CSIDriver, error) {
+		return kube.StorageV1().CSIDrivers().Get(ctx, driverName, metav1.GetOptions{})
+	}
+
+	return []Check{
+		{
+			Name: fmt.Sprintf("csidriver %s: podInfoOnMount", driverName),
+			Check: func(ctx context.Context) error {
+				driver, err := get(ctx)
+				if err != nil {
+					return err
+				}
+				if driver.Spec.PodInfoOnMount == nil || !*driver.Spec.PodInfoOnMount {
+					return fmt.Errorf("podInfoOnMount is not enabled")
+				}
+				return nil
+			},
+			Remediation: "set spec.podInfoOnMount: true on the CSIDriver, so that kubelet passes the pod name and namespace",
+		},
+		{
+			Name: fmt.Sprintf("csidriver %s: Ephemeral lifecycle mode", driverName),
+			Check: func(ctx context.Context) error {
+				driver, err := get(ctx)
+				if err != nil {
+					return err
+				}
+				for _, mode := range driver.Spec.VolumeLifecycleModes {
+					if mode == storagev1.VolumeLifecycleEphemeral {
+						return nil
+					}
+				}
+				return fmt.Errorf("volumeLifecycleModes %v does not include %s", driver.Spec.VolumeLifecycleModes, storagev1.VolumeLifecycleEphemeral)
+			},
+			Remediation: "add Ephemeral to spec.volumeLifecycleModes of the CSIDriver",
+		},
+	}
+}
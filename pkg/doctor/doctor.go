@@ -0,0 +1,49 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package doctor implements preflight diagnostics of an adapter installation.
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// Check is a single named diagnostic, together with the advice shown when it fails.
+type Check struct {
+	Name        string
+	Check       func(ctx context.Context) error
+	Remediation string
+}
+
+// Run runs every check, printing one PASS or FAIL line per check to w, and returns the number of
+// failed checks.
+func Run(ctx context.Context, w io.Writer, checks ...Check) int {
+	failed := 0
+	for _, c := range checks {
+		if err := c.Check(ctx); err != nil {
+			failed++
+			fmt.Fprintf(w, "[FAIL] %s: %v\n", c.Name, err)
+			if c.Remediation != "" {
+				fmt.Fprintf(w, "       %s\n", c.Remediation)
+			}
+			continue
+		}
+		fmt.Fprintf(w, "[PASS] %s\n", c.Name)
+	}
+	return failed
+}
@@ -0,0 +1,181 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package doctor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+var ctx = context.Background()
+
+// failures runs the checks and returns the name and error of every failed check.
+func failures(checks []Check) map[string]string {
+	failed := map[string]string{}
+	for _, c := range checks {
+		if err := c.Check(ctx); err != nil {
+			failed[c.Name] = err.Error()
+		}
+	}
+	return failed
+}
+
+func TestRun(t *testing.T) {
+	var out bytes.Buffer
+	failed := Run(ctx, &out,
+		Check{Name: "good", Check: func(ctx context.Context) error { return nil }},
+		Check{Name: "bad", Check: func(ctx context.Context) error { return errors.New("boom") }, Remediation: "fix it"},
+	)
+
+	if failed != 1 {
+		t.Errorf("expected 1 failed check, got %d", failed)
+	}
+	want := "[PASS] good\n[FAIL] bad: boom\n       fix it\n"
+	if diff := cmp.Diff(want, out.String()); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+}
+
+func TestAccessChecks(t *testing.T) {
+	kube := k8sfake.NewSimpleClientset()
+	kube.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		attrs := review.Spec.ResourceAttributes
+		review.Status.Allowed = !(attrs.Resource == "bucketaccesses" && attrs.Verb == "update")
+		return true, review, nil
+	})
+
+	want := map[string]string{
+		"rbac: update bucketaccesses.objectstorage.k8s.io": "not allowed",
+	}
+	if diff := cmp.Diff(want, failures(AccessChecks(kube))); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+}
+
+func TestCSIDriverChecks(t *testing.T) {
+	enabled := true
+
+	cases := map[string]struct {
+		driver *storagev1.CSIDriver
+		want   map[string]string
+	}{
+		"Valid": {
+			driver: &storagev1.CSIDriver{
+				ObjectMeta: metav1.ObjectMeta{Name: "objectstorage.k8s.io"},
+				Spec: storagev1.CSIDriverSpec{
+					PodInfoOnMount:       &enabled,
+					VolumeLifecycleModes: []storagev1.VolumeLifecycleMode{storagev1.VolumeLifecycleEphemeral},
+				},
+			},
+			want: map[string]string{},
+		},
+		"Misconfigured": {
+			driver: &storagev1.CSIDriver{
+				ObjectMeta: metav1.ObjectMeta{Name: "objectstorage.k8s.io"},
+				Spec: storagev1.CSIDriverSpec{
+					VolumeLifecycleModes: []storagev1.VolumeLifecycleMode{storagev1.VolumeLifecyclePersistent},
+				},
+			},
+			want: map[string]string{
+				"csidriver objectstorage.k8s.io: podInfoOnMount":           "podInfoOnMount is not enabled",
+				"csidriver objectstorage.k8s.io: Ephemeral lifecycle mode": "volumeLifecycleModes [Persistent] does not include Ephemeral",
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			kube := k8sfake.NewSimpleClientset(tc.driver)
+
+			if diff := cmp.Diff(tc.want, failures(CSIDriverChecks(kube, "objectstorage.k8s.io"))); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPropagationCheck(t *testing.T) {
+	mountInfo := filepath.Join("testdata", "mountinfo")
+
+	cases := map[string]struct {
+		path string
+		want string
+	}{
+		"Shared": {
+			path: "/cosi-secret-dir",
+			want: "",
+		},
+		"SharedParent": {
+			path: "/cosi-secret-dir/volume",
+			want: "",
+		},
+		"Slave": {
+			path: "/var/lib/kubelet/pods",
+			want: "mount /var/lib/kubelet/pods is not shared, propagation is slave",
+		},
+		"Private": {
+			path: "/csi",
+			want: "mount /csi is not shared, propagation is private",
+		},
+		"RootFallback": {
+			path: "/var/lib/other",
+			want: "mount / is not shared, propagation is slave",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := ""
+			if err := PropagationCheck("test", tc.path, mountInfo).Check(ctx); err != nil {
+				got = err.Error()
+			}
+
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWritableDirCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "doctor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := WritableDirCheck("test", dir, "").Check(ctx); err != nil {
+		t.Errorf("expected %s to be writable: %v", dir, err)
+	}
+	if err := WritableDirCheck("test", filepath.Join(dir, "missing"), "").Check(ctx); err == nil {
+		t.Errorf("expected missing directory to fail")
+	}
+}
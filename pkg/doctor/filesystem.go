@@ -0,0 +1,100 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/mount-utils"
+)
+
+// WritableDirCheck checks that a file can be created in dir.
+func WritableDirCheck(name, dir, remediation string) Check {
+	return Check{
+		Name: fmt.Sprintf("%s %s is writable", name, dir),
+		Check: func(ctx context.Context) error {
+			f, err := ioutil.TempFile(dir, ".doctor-")
+			if err != nil {
+				return err
+			}
+			f.Close()
+			return os.Remove(f.Name())
+		},
+		Remediation: remediation,
+	}
+}
+
+// PropagationCheck checks that the mount containing path is a shared mount, as set up by a
+// Bidirectional mountPropagation. Without it, mounts made by the adapter never reach kubelet, and
+// the mounts of kubelet never reach the adapter.
+func PropagationCheck(name, path, mountInfoPath string) Check {
+	return Check{
+		Name: fmt.Sprintf("%s %s has bidirectional mount propagation", name, path),
+		Check: func(ctx context.Context) error {
+			infos, err := mount.ParseMountInfo(mountInfoPath)
+			if err != nil {
+				return err
+			}
+
+			info, ok := containingMount(infos, path)
+			if !ok {
+				return fmt.Errorf("no mount contains %s", path)
+			}
+			for _, field := range info.OptionalFields {
+				if strings.HasPrefix(field, "shared:") {
+					return nil
+				}
+			}
+			return fmt.Errorf("mount %s is not shared, propagation is %s", info.MountPoint, propagation(info.OptionalFields))
+		},
+		Remediation: fmt.Sprintf("mount the volume holding %s with mountPropagation: Bidirectional in the adapter container", path),
+	}
+}
+
+// containingMount returns the mount with the longest mount point containing path. Later mounts
+// shadow earlier ones on the same mount point.
+func containingMount(infos []mount.MountInfo, path string) (mount.MountInfo, bool) {
+	path = filepath.Clean(path)
+
+	var best mount.MountInfo
+	found := false
+	for _, info := range infos {
+		mp := filepath.Clean(info.MountPoint)
+		if path != mp && !strings.HasPrefix(path, strings.TrimSuffix(mp, "/")+"/") {
+			continue
+		}
+		if !found || len(mp) >= len(filepath.Clean(best.MountPoint)) {
+			best = info
+			found = true
+		}
+	}
+	return best, found
+}
+
+func propagation(optionalFields []string) string {
+	for _, field := range optionalFields {
+		if strings.HasPrefix(field, "master:") {
+			return "slave"
+		}
+	}
+	return "private"
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package doctor
+
+import (
+	"context"
+	"fmt"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// access is a verb the node client issues on a resource.
+type access struct {
+	group    string
+	resource string
+	verbs    []string
+}
+
+// requiredAccess lists every request made by the node client, including its bucketAccess informer
+// and event recorder.
+var requiredAccess = []access{
+	{group: "", resource: "pods", verbs: []string{"get"}},
+	{group: "", resource: "secrets", verbs: []string{"get"}},
+	{group: "", resource: "configmaps", verbs: []string{"get"}},
+	{group: "", resource: "events", verbs: []string{"create", "patch"}},
+	{group: "objectstorage.k8s.io", resource: "bucketaccessrequests", verbs: []string{"get"}},
+	{group: "objectstorage.k8s.io", resource: "bucketrequests", verbs: []string{"get"}},
+	{group: "objectstorage.k8s.io", resource: "buckets", verbs: []string{"get"}},
+	{group: "objectstorage.k8s.io", resource: "bucketaccesses", verbs: []string{"get", "list", "watch", "update"}},
+}
+
+// AccessChecks returns one check per verb the node client needs, each asking the API server
+// whether the adapter is allowed to issue it, in any namespace.
+func AccessChecks(kube kubernetes.Interface) []Check {
+	var checks []Check
+	for _, a := range requiredAccess {
+		for _, verb := range a.verbs {
+			attrs := authorizationv1.ResourceAttributes{
+				Group:    a.group,
+				Resource: a.resource,
+				Verb:     verb,
+			}
+			checks = append(checks, Check{
+				Name:  fmt.Sprintf("rbac: %s %s", verb, qualifiedResource(a.group, a.resource)),
+				Check: accessCheck(kube, attrs),
+				Remediation: fmt.Sprintf("grant the adapter's service account %q on %q in apiGroup %q through its ClusterRole",
+					verb, a.resource, a.group),
+			})
+		}
+	}
+	return checks
+}
+
+func accessCheck(kube kubernetes.Interface, attrs authorizationv1.ResourceAttributes) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		review := &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &attrs,
+			},
+		}
+		result, err := kube.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+		if err != nil {
+			return err
+		}
+		if !result.Status.Allowed {
+			if result.Status.Reason != "" {
+				return fmt.Errorf("not allowed: %s", result.Status.Reason)
+			}
+			return fmt.Errorf("not allowed")
+		}
+		return nil
+	}
+}
+
+func qualifiedResource(group, resource string) string {
+	if group == "" {
+		return resource
+	}
+	return resource + "." + group
+}
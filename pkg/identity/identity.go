@@ -22,20 +22,25 @@ import (
 	"google.golang.org/grpc/status"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/golang/protobuf/ptypes/wrappers"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/logging"
 )
 
-func NewIdentityServer(ident, version string, manifest map[string]string) (csi.IdentityServer, error) {
+func NewIdentityServer(ident, version string, manifest map[string]string, readiness *Readiness) (csi.IdentityServer, error) {
 	return &IdentityServer{
-		Identity: ident,
-		Version:  version,
-		Manifest: manifest,
+		Identity:  ident,
+		Version:   version,
+		Manifest:  manifest,
+		Readiness: readiness,
 	}, nil
 }
 
 type IdentityServer struct {
-	Identity string
-	Version  string
-	Manifest map[string]string
+	Identity  string
+	Version   string
+	Manifest  map[string]string
+	Readiness *Readiness
 }
 
 func (i *IdentityServer) GetPluginInfo(ctx context.Context, req *csi.GetPluginInfoRequest) (*csi.GetPluginInfoResponse, error) {
@@ -54,9 +59,23 @@ func (i *IdentityServer) GetPluginInfo(ctx context.Context, req *csi.GetPluginIn
 }
 
 func (i *IdentityServer) Probe(ctx context.Context, req *csi.ProbeRequest) (*csi.ProbeResponse, error) {
-	return &csi.ProbeResponse{}, nil
+	if reasons := i.Readiness.Reasons(ctx); len(reasons) > 0 {
+		logging.FromContext(ctx).Info("driver is not ready", "reasons", reasons)
+		return &csi.ProbeResponse{Ready: &wrappers.BoolValue{Value: false}}, nil
+	}
+	return &csi.ProbeResponse{Ready: &wrappers.BoolValue{Value: true}}, nil
 }
 
 func (i *IdentityServer) GetPluginCapabilities(ctx context.Context, req *csi.GetPluginCapabilitiesRequest) (*csi.GetPluginCapabilitiesResponse, error) {
-	return &csi.GetPluginCapabilitiesResponse{}, nil
+	return &csi.GetPluginCapabilitiesResponse{
+		Capabilities: []*csi.PluginCapability{
+			{
+				Type: &csi.PluginCapability_Service_{
+					Service: &csi.PluginCapability_Service{
+						Type: csi.PluginCapability_Service_CONTROLLER_SERVICE,
+					},
+				},
+			},
+		},
+	}, nil
 }
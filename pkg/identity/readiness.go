@@ -0,0 +1,74 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package identity
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const readyzTimeout = 5 * time.Second
+
+// Check is a single named readiness condition.
+type Check struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// Readiness evaluates a set of checks, both for the CSI Probe call and for the HTTP /readyz endpoint.
+type Readiness struct {
+	checks []Check
+}
+
+func NewReadiness(checks ...Check) *Readiness {
+	return &Readiness{
+		checks: checks,
+	}
+}
+
+// Reasons runs every check and returns one reason per failed check. An empty result means ready.
+func (r *Readiness) Reasons(ctx context.Context) []string {
+	if r == nil {
+		return nil
+	}
+
+	var reasons []string
+	for _, c := range r.checks {
+		if err := c.Check(ctx); err != nil {
+			reasons = append(reasons, fmt.Sprintf("%s: %v", c.Name, err))
+		}
+	}
+	return reasons
+}
+
+func (r *Readiness) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	ctx, cancel := context.WithTimeout(req.Context(), readyzTimeout)
+	defer cancel()
+
+	reasons := r.Reasons(ctx)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if len(reasons) > 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		for _, reason := range reasons {
+			fmt.Fprintf(w, "[-]%s\n", reason)
+		}
+		return
+	}
+	fmt.Fprint(w, "ok\n")
+}
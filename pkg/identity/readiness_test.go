@@ -0,0 +1,111 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package identity
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func passing(name string) Check {
+	return Check{Name: name, Check: func(ctx context.Context) error { return nil }}
+}
+
+func failing(name, reason string) Check {
+	return Check{Name: name, Check: func(ctx context.Context) error { return errors.New(reason) }}
+}
+
+func TestReasons(t *testing.T) {
+	cases := map[string]struct {
+		readiness *Readiness
+		want      []string
+	}{
+		"NoReadiness": {},
+		"NoChecks": {
+			readiness: NewReadiness(),
+		},
+		"AllPassing": {
+			readiness: NewReadiness(passing("data-root"), passing("api-server")),
+		},
+		"SomeFailing": {
+			readiness: NewReadiness(
+				failing("data-root", "read-only file system"),
+				passing("mounter"),
+				failing("api-server", "connection refused"),
+			),
+			want: []string{
+				"data-root: read-only file system",
+				"api-server: connection refused",
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, tc.readiness.Reasons(context.Background())); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	type want struct {
+		status int
+		body   string
+	}
+
+	cases := map[string]struct {
+		readiness *Readiness
+		want      want
+	}{
+		"Ready": {
+			readiness: NewReadiness(passing("data-root")),
+			want: want{
+				status: http.StatusOK,
+				body:   "ok\n",
+			},
+		},
+		"NotReady": {
+			readiness: NewReadiness(
+				failing("data-root", "read-only file system"),
+				failing("api-server", "connection refused"),
+			),
+			want: want{
+				status: http.StatusServiceUnavailable,
+				body:   "[-]data-root: read-only file system\n[-]api-server: connection refused\n",
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			tc.readiness.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			got := want{status: rec.Code, body: rec.Body.String()}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/klog/v2"
+)
+
+// jsonLogger writes every line as a JSON object holding the time, the verbosity, the logger name,
+// the message, the error, if any, and the key value pairs, in the order they were added.
+type jsonLogger struct {
+	out    *jsonWriter
+	level  int
+	name   string
+	values []interface{}
+	now    func() time.Time
+}
+
+type jsonWriter struct {
+	sync.Mutex
+	w io.Writer
+}
+
+// NewJSONLogger returns a logger writing JSON lines to w. Lines above the klog verbosity are
+// dropped.
+func NewJSONLogger(w io.Writer) logr.Logger {
+	return jsonLogger{out: &jsonWriter{w: w}, now: time.Now}
+}
+
+func (l jsonLogger) Enabled() bool {
+	return bool(klog.V(klog.Level(l.level)).Enabled())
+}
+
+func (l jsonLogger) Info(msg string, keysAndValues ...interface{}) {
+	if l.Enabled() {
+		l.write(false, nil, msg, keysAndValues)
+	}
+}
+
+func (l jsonLogger) Error(err error, msg string, keysAndValues ...interface{}) {
+	l.write(true, err, msg, keysAndValues)
+}
+
+func (l jsonLogger) V(level int) logr.Logger {
+	l.level += level
+	return l
+}
+
+func (l jsonLogger) WithValues(keysAndValues ...interface{}) logr.Logger {
+	l.values = append(append([]interface{}{}, l.values...), keysAndValues...)
+	return l
+}
+
+func (l jsonLogger) WithName(name string) logr.Logger {
+	if l.name != "" {
+		name = l.name + "." + name
+	}
+	l.name = name
+	return l
+}
+
+// write writes a line, klog passes errors without an error value.
+func (l jsonLogger) write(isError bool, err error, msg string, keysAndValues []interface{}) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	field := func(key string, value interface{}) {
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		k, _ := json.Marshal(key)
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(marshalValue(value))
+	}
+
+	field("ts", l.now().UTC().Format(time.RFC3339Nano))
+	if isError {
+		field("level", "error")
+	} else {
+		field("level", "info")
+		field("v", l.level)
+	}
+	if l.name != "" {
+		field("logger", l.name)
+	}
+	field("msg", msg)
+	if err != nil {
+		field("err", err.Error())
+	}
+	for _, kv := range [][]interface{}{l.values, keysAndValues} {
+		for i := 0; i < len(kv); i += 2 {
+			var value interface{} = "(MISSING)"
+			if i+1 < len(kv) {
+				value = kv[i+1]
+			}
+			field(fmt.Sprint(kv[i]), value)
+		}
+	}
+	buf.WriteString("}\n")
+
+	l.out.Lock()
+	defer l.out.Unlock()
+	_, _ = l.out.w.Write(buf.Bytes())
+}
+
+// marshalValue encodes errors and stringers as their text, unless they encode themselves,
+// everything else as JSON, falling back to its text.
+func marshalValue(value interface{}) []byte {
+	switch v := value.(type) {
+	case json.Marshaler:
+	case error:
+		value = v.Error()
+	case fmt.Stringer:
+		value = v.String()
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprintf("%+v", value))
+	}
+	return data
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package logging provides the contextual loggers of the adapter. Handlers attach a logger carrying
+// the volume, pod and bucketAccessRequest they work on to their context, so that every line logged
+// while handling a request names them. Values of sensitive keys are never logged.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/go-logr/logr"
+	"k8s.io/klog/v2"
+	"k8s.io/klog/v2/klogr"
+)
+
+// Log formats.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// root is the logger of contexts without one.
+var root logr.Logger = Redacting(klogr.NewWithOptions(klogr.WithFormat(klogr.FormatKlog)))
+
+// Setup makes the adapter log in the format, text through klog, or JSON lines written to w. klog
+// calls not yet using a contextual logger are redacted and formatted the same way.
+func Setup(format string, w io.Writer) error {
+	switch format {
+	case FormatText:
+		root = Redacting(klogr.NewWithOptions(klogr.WithFormat(klogr.FormatKlog)))
+	case FormatJSON:
+		logger := NewJSONLogger(w)
+		klog.SetLogger(logger)
+		root = Redacting(logger)
+	default:
+		return fmt.Errorf("unsupported log format %q, must be one of %s, %s", format, FormatText, FormatJSON)
+	}
+	klog.SetLogFilter(redactingFilter{})
+	return nil
+}
+
+// FromContext returns the logger attached to the context, or the root logger.
+func FromContext(ctx context.Context) logr.Logger {
+	if logger := logr.FromContext(ctx); logger != nil {
+		return logger
+	}
+	return root
+}
+
+// NewContext returns a context whose logger adds the key value pairs to every line.
+func NewContext(ctx context.Context, keysAndValues ...interface{}) context.Context {
+	return logr.NewContext(ctx, FromContext(ctx).WithValues(keysAndValues...))
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+func TestRedact(t *testing.T) {
+	cases := map[string]struct {
+		key   interface{}
+		value interface{}
+		want  interface{}
+	}{
+		"Plain": {
+			key:   "bucket",
+			value: "bucketName",
+			want:  "bucketName",
+		},
+		"SensitiveKey": {
+			key:   "secretAccessKey",
+			value: "wJalrXUtnFEMI",
+			want:  Redacted,
+		},
+		"SensitiveKeyAnyCase": {
+			key:   "Token",
+			value: "eyJhbGciOi",
+			want:  Redacted,
+		},
+		"Secret": {
+			key:   "object",
+			value: &v1.Secret{Data: map[string][]byte{"credentials": []byte("test")}},
+			want:  Redacted,
+		},
+		"Bytes": {
+			key:   "contents",
+			value: []byte("test"),
+			want:  Redacted,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, Redact(tc.key, tc.value)); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func newTestLogger(buf *bytes.Buffer) logr.Logger {
+	return jsonLogger{
+		out: &jsonWriter{w: buf},
+		now: func() time.Time { return time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC) },
+	}
+}
+
+func TestJSONLogger(t *testing.T) {
+	cases := map[string]struct {
+		log  func(logger logr.Logger)
+		want string
+	}{
+		"Info": {
+			log: func(logger logr.Logger) {
+				logger.Info("published volume", "volumeID", "vol-1", "pod", klog.KRef("default", "app"))
+			},
+			want: `{"ts":"2021-01-01T00:00:00Z","level":"info","v":0,"msg":"published volume","volumeID":"vol-1","pod":"default/app"}` + "\n",
+		},
+		"Error": {
+			log: func(logger logr.Logger) {
+				logger.WithName("node").Error(errors.New("boom"), "failed to publish volume", "attempt", 2)
+			},
+			want: `{"ts":"2021-01-01T00:00:00Z","level":"error","logger":"node","msg":"failed to publish volume","err":"boom","attempt":2}` + "\n",
+		},
+		"Redacted": {
+			log: func(logger logr.Logger) {
+				Redacting(logger).WithValues("volumeID", "vol-1").Info("read credentials", "accessKeyID", "AKIAEXAMPLE", "secret", &v1.Secret{})
+			},
+			want: `{"ts":"2021-01-01T00:00:00Z","level":"info","v":0,"msg":"read credentials","volumeID":"vol-1","accessKeyID":"[REDACTED]","secret":"[REDACTED]"}` + "\n",
+		},
+		"Disabled": {
+			log: func(logger logr.Logger) {
+				logger.V(10).Info("noisy")
+			},
+			want: "",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tc.log(newTestLogger(&buf))
+			if diff := cmp.Diff(tc.want, buf.String()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestContext(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := logr.NewContext(context.Background(), Redacting(newTestLogger(&buf)))
+	ctx = NewContext(ctx, "volumeID", "vol-1")
+	ctx = NewContext(ctx, "bar", klog.KRef("default", "bar"))
+
+	FromContext(ctx).Info("resolved bucket", "token", "eyJhbGciOi")
+
+	want := `{"ts":"2021-01-01T00:00:00Z","level":"info","v":0,"msg":"resolved bucket","volumeID":"vol-1","bar":"default/bar","token":"[REDACTED]"}` + "\n"
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"strings"
+
+	"github.com/go-logr/logr"
+	v1 "k8s.io/api/core/v1"
+)
+
+// Redacted replaces the values which must not be logged.
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys are the keys, in lower case, whose values are never logged.
+var sensitiveKeys = map[string]bool{
+	"accesskeyid":       true,
+	"secretaccesskey":   true,
+	"sessiontoken":      true,
+	"accountkey":        true,
+	"connectionstring":  true,
+	"privatekey":        true,
+	"password":          true,
+	"token":             true,
+	"credentials":       true,
+	"secret":            true,
+	"data":              true,
+	"stringdata":        true,
+	"serviceaccountkey": true,
+}
+
+// Redact returns the value to log for the key: the value itself, or Redacted for sensitive keys,
+// secrets and raw bytes, which may hold credentials.
+func Redact(key interface{}, value interface{}) interface{} {
+	if k, ok := key.(string); ok && sensitiveKeys[strings.ToLower(k)] {
+		return Redacted
+	}
+	switch value.(type) {
+	case v1.Secret, *v1.Secret, []byte:
+		return Redacted
+	}
+	return value
+}
+
+// redactKeysAndValues returns a copy of the key value pairs with their values redacted.
+func redactKeysAndValues(keysAndValues []interface{}) []interface{} {
+	redacted := make([]interface{}, len(keysAndValues))
+	for i := 0; i < len(keysAndValues); i += 2 {
+		redacted[i] = keysAndValues[i]
+		if i+1 < len(keysAndValues) {
+			redacted[i+1] = Redact(keysAndValues[i], keysAndValues[i+1])
+		}
+	}
+	return redacted
+}
+
+// Redacting returns a logger redacting the values of every key value pair before passing them to
+// the logger.
+func Redacting(logger logr.Logger) logr.Logger {
+	return redactingLogger{Logger: logger}
+}
+
+type redactingLogger struct {
+	logr.Logger
+}
+
+func (l redactingLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.Logger.Info(msg, redactKeysAndValues(keysAndValues)...)
+}
+
+func (l redactingLogger) Error(err error, msg string, keysAndValues ...interface{}) {
+	l.Logger.Error(err, msg, redactKeysAndValues(keysAndValues)...)
+}
+
+func (l redactingLogger) V(level int) logr.Logger {
+	return redactingLogger{Logger: l.Logger.V(level)}
+}
+
+func (l redactingLogger) WithValues(keysAndValues ...interface{}) logr.Logger {
+	return redactingLogger{Logger: l.Logger.WithValues(redactKeysAndValues(keysAndValues)...)}
+}
+
+func (l redactingLogger) WithName(name string) logr.Logger {
+	return redactingLogger{Logger: l.Logger.WithName(name)}
+}
+
+// redactingFilter redacts the klog calls not yet using a contextual logger. Unstructured calls
+// cannot be redacted by key, secrets passed to them are still replaced.
+type redactingFilter struct{}
+
+func (redactingFilter) Filter(args []interface{}) []interface{} {
+	filtered := make([]interface{}, len(args))
+	for i, arg := range args {
+		filtered[i] = Redact(nil, arg)
+	}
+	return filtered
+}
+
+func (f redactingFilter) FilterF(format string, args []interface{}) (string, []interface{}) {
+	return format, f.Filter(args)
+}
+
+func (redactingFilter) FilterS(msg string, keysAndValues []interface{}) (string, []interface{}) {
+	return msg, redactKeysAndValues(keysAndValues)
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	namespace = "cosi"
+	subsystem = "csi_adapter"
+)
+
+var (
+	registry = prometheus.NewRegistry()
+
+	// CredentialsExpirySeconds is the time left before the credentials of a published volume expire.
+	CredentialsExpirySeconds = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "credentials_expiry_seconds",
+			Help:      "Seconds until the credentials written to a published volume expire.",
+		},
+		[]string{"volume_id", "pod_namespace", "pod_name"},
+	)
+
+	// PublishedVolumes is the number of volumes published on the node.
+	PublishedVolumes = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "published_volumes",
+			Help:      "Number of volumes published on the node, counted against --max-volumes.",
+		},
+	)
+)
+
+func init() {
+	registry.MustRegister(
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		prometheus.NewGoCollector(),
+		CredentialsExpirySeconds,
+		PublishedVolumes,
+	)
+}
+
+// Handler serves the adapter metrics in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
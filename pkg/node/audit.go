@@ -0,0 +1,57 @@
+package node
+
+import (
+	"context"
+
+	v1 "k8s.io/api/core/v1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/audit"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/logging"
+)
+
+// Auditor records the credentials handed out to pods.
+type Auditor interface {
+	Record(e audit.Entry) error
+}
+
+// audit records the action on the credentials of a volume. Failing to record does not fail the
+// action, the credentials have been rotated or removed already.
+func (n *NodeServer) audit(ctx context.Context, action audit.Action, volID string, meta Metadata, reason string) {
+	if err := n.recordAudit(action, volID, meta, reason); err != nil {
+		logging.FromContext(ctx).Error(err, "failed to record audit entry", "action", action)
+	}
+}
+
+// auditPublish records the publishing of a volume. Unlike the other actions, failing to record
+// fails the publishing, so that no credentials are handed out unaudited.
+func (n *NodeServer) auditPublish(volID string, meta Metadata) error {
+	return n.recordAudit(audit.ActionPublish, volID, meta, "")
+}
+
+func (n *NodeServer) recordAudit(action audit.Action, volID string, meta Metadata, reason string) error {
+	if n.auditor == nil {
+		return nil
+	}
+	e := audit.Entry{
+		Time:                  n.clock.Now().UTC(),
+		Action:                action,
+		Node:                  n.nodeID,
+		VolumeID:              volID,
+		Pod:                   meta.PodName,
+		PodNamespace:          meta.PodNamespace,
+		ServiceAccount:        meta.ServiceAccount,
+		BucketAccess:          meta.BaName,
+		Bucket:                meta.BucketName,
+		SecretResourceVersion: meta.SecretResourceVersion,
+		CredentialsHash:       audit.CredentialsHash(meta.Files, protocolFileName),
+		Reason:                reason,
+	}
+	return n.auditor.Record(e)
+}
+
+func serviceAccountName(pod *v1.Pod) string {
+	if pod == nil {
+		return ""
+	}
+	return pod.Spec.ServiceAccountName
+}
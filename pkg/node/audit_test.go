@@ -0,0 +1,217 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/mount-utils"
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/audit"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client/fake"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/protocol"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+	testutils "sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util/test"
+)
+
+type recordingAuditor []audit.Entry
+
+func (r *recordingAuditor) Record(e audit.Entry) error {
+	*r = append(*r, e)
+	return nil
+}
+
+type failingAuditor struct{}
+
+func (failingAuditor) Record(e audit.Entry) error {
+	return errors.New("disk full")
+}
+
+func TestAuditPublishFailure(t *testing.T) {
+	cases := map[string]struct {
+		volumeContext map[string]string
+	}{
+		"Staged": {},
+		"Presigned": {
+			volumeContext: map[string]string{client.PresignObjectsKey: "reports/daily.csv"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			bkt := testutils.GetB(testutils.WithProtocol(v1alpha1.Protocol{
+				S3: &v1alpha1.S3Protocol{Endpoint: "https://s3.example.com", BucketName: "bucketName"},
+			}))
+			secret := testutils.GetSecret()
+			secret.Data = map[string][]byte{
+				"accessKeyID":     []byte("AKIAEXAMPLE"),
+				"secretAccessKey": []byte("secret"),
+			}
+			fs := memFS{}
+			ns := &NodeServer{
+				name:        name,
+				nodeID:      nodeId,
+				volumeLimit: volLimit,
+				cosiClient: &fake.FakeNodeClient{
+					MockGetResources: func(ctx context.Context, barName, barNs, podName, podNs string) (*v1alpha1.Bucket, *v1alpha1.BucketAccess, *v1.Pod, error) {
+						return bkt, testutils.GetBA(), testutils.GetPod(), nil
+					},
+					MockGetSecret: func(ctx context.Context, pod *v1.Pod, ba *v1alpha1.BucketAccess) (*v1.Secret, error) {
+						return secret, nil
+					},
+					MockUpdateBAFinalizers: func(ctx context.Context, baName string, add map[string]client.FinalizerOwner, remove []string) (*v1alpha1.BucketAccess, error) {
+						return testutils.GetBA(), nil
+					},
+					MockRecorder: &record.FakeRecorder{},
+				},
+				provisioner: NewProvisioner("/data", mount.NewFakeMounter(nil), fs.client()),
+				auditor:     failingAuditor{},
+				clock:       clock.NewFakeClock(testNow),
+			}
+
+			volCtx := map[string]string{
+				client.BarNameKey:      testutils.GetBAR().Name,
+				client.PodNameKey:      podName,
+				client.PodNamespaceKey: testutils.Namespace,
+			}
+			for key, value := range tc.volumeContext {
+				volCtx[key] = value
+			}
+			_, err := ns.NodePublishVolume(ctx, &csi.NodePublishVolumeRequest{
+				VolumeContext: volCtx,
+				VolumeId:      provVolumeId,
+				TargetPath:    provTargetPath,
+			})
+			want := status.Error(codes.Internal, "failed to record audit entry: disk full")
+			if diff := cmp.Diff(want, err, util.EquateErrors()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+
+			// no credentials are left behind for the pod
+			if len(fs) > 0 {
+				t.Errorf("expected no files left after the failed audit, got %d", len(fs))
+			}
+		})
+	}
+}
+
+func TestAudit(t *testing.T) {
+	fs := memFS{}
+	ba := testutils.GetBA()
+	pod := testutils.GetPod()
+	pod.Spec.ServiceAccountName = "reader"
+	secret := testutils.GetSecret()
+	secret.ResourceVersion = "1"
+	auditor := &recordingAuditor{}
+
+	ns := &NodeServer{
+		name:        name,
+		nodeID:      nodeId,
+		volumeLimit: volLimit,
+		cosiClient: &fake.FakeNodeClient{
+			MockGetResources: func(ctx context.Context, barName, barNs, podName, podNs string) (*v1alpha1.Bucket, *v1alpha1.BucketAccess, *v1.Pod, error) {
+				return testutils.GetB(), ba, pod, nil
+			},
+			MockGetSecret: func(ctx context.Context, pod *v1.Pod, ba *v1alpha1.BucketAccess) (*v1.Secret, error) {
+				return secret, nil
+			},
+			MockGetPod: func(ctx context.Context, podName, podNs string) (*v1.Pod, error) {
+				return pod, nil
+			},
+			MockUpdateBAFinalizers: func(ctx context.Context, baName string, add map[string]client.FinalizerOwner, remove []string) (*v1alpha1.BucketAccess, error) {
+				return ba, nil
+			},
+			MockRecorder: &record.FakeRecorder{},
+		},
+		provisioner: NewProvisioner("/data", mount.NewFakeMounter(nil), fs.client()),
+		auditor:     auditor,
+		clock:       clock.NewFakeClock(testNow),
+	}
+
+	publish := func(volID string) {
+		_, err := ns.NodePublishVolume(ctx, &csi.NodePublishVolumeRequest{
+			VolumeContext: map[string]string{
+				client.BarNameKey:      testutils.GetBAR().Name,
+				client.PodNameKey:      podName,
+				client.PodNamespaceKey: testutils.Namespace,
+			},
+			VolumeId:   volID,
+			TargetPath: "/pods/" + volID,
+		})
+		if err != nil {
+			t.Fatalf("failed to publish %s: %v", volID, err)
+		}
+	}
+
+	publish("vol-1")
+
+	// a new minted secret is staged for the next volume, replacing the credentials of the first
+	ba.Status.MintedSecret.Name = "rotatedSecretName"
+	secret = testutils.GetSecret()
+	secret.ResourceVersion = "2"
+	secret.Data["credentials"] = []byte("rotated")
+	publish("vol-2")
+
+	if err := ns.revoke(ctx, ba.Name, util.BADeleted, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ns.NodeUnpublishVolume(ctx, &csi.NodeUnpublishVolumeRequest{VolumeId: "vol-1", TargetPath: "/pods/vol-1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	credentialsHash := func(secret *v1.Secret) string {
+		files, err := RenderFiles(testutils.GetB(), secret, nil, protocol.FormatJSON)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return audit.CredentialsHash(map[string]string{credsFileName: util.Checksum(files[credsFileName])})
+	}
+	first, rotated := testutils.GetSecret(), secret
+
+	entry := func(action audit.Action, volID, secretVersion string, secret *v1.Secret, reason string) audit.Entry {
+		return audit.Entry{
+			Time:                  testNow.UTC(),
+			Action:                action,
+			Node:                  nodeId,
+			VolumeID:              volID,
+			Pod:                   podName,
+			PodNamespace:          testutils.Namespace,
+			ServiceAccount:        "reader",
+			BucketAccess:          ba.Name,
+			Bucket:                testutils.GetB().Name,
+			SecretResourceVersion: secretVersion,
+			CredentialsHash:       credentialsHash(secret),
+			Reason:                reason,
+		}
+	}
+	want := []audit.Entry{
+		entry(audit.ActionPublish, volumeDirID("vol-1", "/pods/vol-1"), "1", first, ""),
+		entry(audit.ActionRotate, volumeDirID("vol-1", "/pods/vol-1"), "2", rotated, ""),
+		entry(audit.ActionPublish, volumeDirID("vol-2", "/pods/vol-2"), "2", rotated, ""),
+		entry(audit.ActionRevoke, volumeDirID("vol-1", "/pods/vol-1"), "2", rotated, util.BADeleted.Message()),
+		entry(audit.ActionRevoke, volumeDirID("vol-2", "/pods/vol-2"), "2", rotated, util.BADeleted.Message()),
+		entry(audit.ActionUnpublish, volumeDirID("vol-1", "/pods/vol-1"), "2", rotated, ""),
+	}
+	// revocations are recorded in the order the volumes are listed in
+	got := []audit.Entry(*auditor)
+	if len(got) != len(want) {
+		t.Fatalf("expected %d audit entries, got %+v", len(want), got)
+	}
+	for _, entries := range [][]audit.Entry{want[3:5], got[3:5]} {
+		sort.Slice(entries, func(i, j int) bool { return entries[i].VolumeID < entries[j].VolumeID })
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+}
@@ -0,0 +1,68 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	v1 "k8s.io/api/core/v1"
+
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/logging"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/protocol"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+// checkBucket checks the credentials returned by readCredentials, as published in the credentials
+// file, against the bucket, when the connectivity check is enabled and the protocol of the bucket
+// supports it. Failures are reported on the pod, so that a volume with bad credentials or an
+// unreachable endpoint fails to mount instead of the app failing later.
+func (n *NodeServer) checkBucket(ctx context.Context, pod *v1.Pod, bkt *v1alpha1.Bucket, readCredentials func() ([]byte, error)) error {
+	if n.checkClient == nil {
+		return nil
+	}
+
+	p, err := protocol.Lookup(bkt)
+	if err != nil {
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	checker, ok := p.(protocol.Checker)
+	if !ok {
+		logging.FromContext(ctx).Info("bucket protocol cannot be checked, publishing unchecked", "bucket", bkt.Name, "protocol", p.Name())
+		return nil
+	}
+
+	data, err := readCredentials()
+	if err != nil {
+		return status.Error(codes.Internal, errors.Wrap(err, util.WrapErrorFailedToReadCredentials).Error())
+	}
+	creds := map[string]string{}
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return status.Error(codes.Internal, errors.Wrap(err, util.WrapErrorFailedToReadCredentials).Error())
+	}
+
+	if err := checker.Check(ctx, n.checkClient, bkt, creds); err != nil {
+		util.EmitWarningEvent(ctx, n.cosiClient.Recorder(), pod, protocol.Event(err))
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	logging.FromContext(ctx).Info("bucket check succeeded", "bucket", bkt.Name, "protocol", p.Name())
+	return nil
+}
+
+// stagedCredentials reads the credentials file of a stage.
+func (p Provisioner) stagedCredentials(stageID string) func() ([]byte, error) {
+	return func() ([]byte, error) {
+		return p.pclient.ReadFile(filepath.Join(p.stagedBucketPath(stageID), credsFileName))
+	}
+}
+
+// secretCredentials renders the credentials file of a secret.
+func secretCredentials(secret *v1.Secret) func() ([]byte, error) {
+	return func() ([]byte, error) {
+		return util.ParseData(secret)
+	}
+}
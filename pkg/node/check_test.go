@@ -0,0 +1,157 @@
+package node
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/mount-utils"
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client/fake"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+	testutils "sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util/test"
+)
+
+func TestConnectivityCheck(t *testing.T) {
+	secret := testutils.GetSecret()
+	secret.Data = map[string][]byte{
+		"accessKeyID":     []byte("AKIAEXAMPLE"),
+		"secretAccessKey": []byte("secret"),
+	}
+
+	type want struct {
+		err        error
+		stageFiles []string
+		events     []string
+	}
+
+	cases := map[string]struct {
+		// status is answered by the S3 stand-in for signed HEAD requests of the bucket
+		status int
+		// volumeContext is added to the volume context of the request
+		volumeContext map[string]string
+		want          want
+	}{
+		"Successful": {
+			status: http.StatusOK,
+			want: want{
+				stageFiles: []string{
+					"bucketAccessName/bucket/credentials",
+					"bucketAccessName/bucket/protocolConn.json",
+					"bucketAccessName/stage.json",
+				},
+				// on the pod and the bucketAccess
+				events: []string{
+					"Normal VolumePublished Volume " + provVolumeId + ": Publish credentials completed successfully",
+					"Normal VolumePublished Volume " + provVolumeId + ": Publish credentials completed successfully",
+				},
+			},
+		},
+		"AccessDenied": {
+			status: http.StatusForbidden,
+			want: want{
+				err: status.Error(codes.FailedPrecondition, "bucket bucketName denied access to the mounted credentials with status 403"),
+				events: []string{
+					"Warning BucketCheckFailed Volume " + provVolumeId + ": Bucket denied access to the mounted credentials",
+				},
+			},
+		},
+		"AccessDeniedPresigned": {
+			status:        http.StatusForbidden,
+			volumeContext: map[string]string{client.PresignObjectsKey: "reports/daily.csv"},
+			want: want{
+				err: status.Error(codes.FailedPrecondition, "bucket bucketName denied access to the mounted credentials with status 403"),
+				events: []string{
+					"Warning BucketCheckFailed Volume " + provVolumeId + ": Bucket denied access to the mounted credentials",
+				},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodHead || r.URL.Path != "/bucketName" ||
+					!strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKIAEXAMPLE/") {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				w.WriteHeader(tc.status)
+			}))
+			defer server.Close()
+
+			bkt := testutils.GetB(testutils.WithProtocol(v1alpha1.Protocol{
+				S3: &v1alpha1.S3Protocol{Endpoint: server.URL, BucketName: "bucketName"},
+			}))
+			fs := memFS{}
+			recorder := record.NewFakeRecorder(10)
+			ns := &NodeServer{
+				name:        name,
+				nodeID:      nodeId,
+				volumeLimit: volLimit,
+				cosiClient: &fake.FakeNodeClient{
+					MockGetResources: func(ctx context.Context, barName, barNs, podName, podNs string) (*v1alpha1.Bucket, *v1alpha1.BucketAccess, *v1.Pod, error) {
+						return bkt, testutils.GetBA(), testutils.GetPod(), nil
+					},
+					MockGetSecret: func(ctx context.Context, pod *v1.Pod, ba *v1alpha1.BucketAccess) (*v1.Secret, error) {
+						return secret, nil
+					},
+					MockUpdateBAFinalizers: func(ctx context.Context, baName string, add map[string]client.FinalizerOwner, remove []string) (*v1alpha1.BucketAccess, error) {
+						return testutils.GetBA(), nil
+					},
+					MockRecorder: recorder,
+				},
+				provisioner: NewProvisioner("/data", mount.NewFakeMounter(nil), fs.client()),
+				checkClient: server.Client(),
+				clock:       clock.NewFakeClock(testNow),
+			}
+
+			volCtx := map[string]string{
+				client.BarNameKey:      testutils.GetBAR().Name,
+				client.PodNameKey:      podName,
+				client.PodNamespaceKey: testutils.Namespace,
+			}
+			for key, value := range tc.volumeContext {
+				volCtx[key] = value
+			}
+			_, err := ns.NodePublishVolume(ctx, &csi.NodePublishVolumeRequest{
+				VolumeContext: volCtx,
+				VolumeId:      provVolumeId,
+				TargetPath:    provTargetPath,
+			})
+			if diff := cmp.Diff(tc.want.err, err, util.EquateErrors()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+
+			// the stage is released when the check fails
+			if diff := cmp.Diff(tc.want.stageFiles, fs.stageFiles()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			if err != nil && len(fs) > 0 {
+				t.Errorf("expected no files left after the failed check, got %d", len(fs))
+			}
+
+			var events []string
+			for len(recorder.Events) > 0 {
+				event := <-recorder.Events
+				if strings.Contains(event, "VolumePublished") || strings.Contains(event, "BucketCheckFailed") {
+					events = append(events, event)
+				}
+			}
+			if diff := cmp.Diff(tc.want.events, events); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
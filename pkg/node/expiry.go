@@ -0,0 +1,132 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/logging"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/metrics"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+const (
+	expiresAtKey = "expiresAt"
+
+	expiryCheckInterval = time.Minute
+)
+
+type expiryState int
+
+const (
+	expiryStateValid expiryState = iota
+	expiryStateExpiring
+	expiryStateExpired
+)
+
+// expiryTracker remembers which warnings have already been emitted for a volume, so that every
+// state change is reported exactly once.
+type expiryTracker struct {
+	sync.Mutex
+	states map[string]expiryState
+}
+
+func (e *expiryTracker) transition(volID string, state expiryState) bool {
+	e.Lock()
+	defer e.Unlock()
+	if e.states == nil {
+		e.states = map[string]expiryState{}
+	}
+	if e.states[volID] == state {
+		return false
+	}
+	e.states[volID] = state
+	return true
+}
+
+func (e *expiryTracker) forget(volID string) {
+	e.Lock()
+	defer e.Unlock()
+	delete(e.states, volID)
+}
+
+// CredentialsExpiry reads the expiry recorded on the minted secret under the given annotation. It
+// returns nil when the annotation is empty or not set on the secret.
+func CredentialsExpiry(secret *v1.Secret, annotation string) (*time.Time, error) {
+	if annotation == "" {
+		return nil, nil
+	}
+	value, ok := secret.Annotations[annotation]
+	if !ok {
+		return nil, nil
+	}
+	expiresAt, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, errors.Wrap(err, util.WrapErrorInvalidExpiryAnnotation)
+	}
+	return &expiresAt, nil
+}
+
+// withExpiry adds the expiry of the credentials to the protocol connection document.
+func withExpiry(protocolConnection []byte, expiresAt time.Time) ([]byte, error) {
+	conn := map[string]interface{}{}
+	if err := json.Unmarshal(protocolConnection, &conn); err != nil {
+		return nil, errors.Wrap(err, util.WrapErrorFailedToAddExpiry)
+	}
+	conn[expiresAtKey] = expiresAt.UTC().Format(time.RFC3339)
+	data, err := json.Marshal(conn)
+	if err != nil {
+		return nil, errors.Wrap(err, util.WrapErrorFailedToAddExpiry)
+	}
+	return data, nil
+}
+
+// checkExpiry updates the expiry metric of every published volume and warns pods whose credentials
+// are about to expire or have expired.
+func (n *NodeServer) checkExpiry(ctx context.Context) {
+	volIDs, err := n.provisioner.listVolumes()
+	if err != nil {
+		logging.FromContext(ctx).Error(err, "failed to list volumes for expiry check")
+		return
+	}
+
+	for _, volID := range volIDs {
+		meta, err := n.provisioner.readMetadata(volID)
+		if err != nil || meta.ExpiresAt == nil {
+			continue
+		}
+
+		remaining := meta.ExpiresAt.Sub(n.clock.Now())
+		metrics.CredentialsExpirySeconds.WithLabelValues(volID, meta.PodNamespace, meta.PodName).Set(remaining.Seconds())
+
+		state, event := expiryStateValid, util.EventResource{}
+		switch {
+		case remaining <= 0:
+			state, event = expiryStateExpired, util.CredentialsExpired
+		case remaining <= n.expiryWarningWindow:
+			state, event = expiryStateExpiring, util.CredentialsExpiring
+		}
+		if !n.expiry.transition(volID, state) || state == expiryStateValid {
+			continue
+		}
+
+		ctx := volumeContext(ctx, volID, meta)
+		logging.FromContext(ctx).Info("credentials of published volume are expiring", "expiresAt", meta.ExpiresAt)
+		pod, err := n.cosiClient.GetPod(ctx, meta.PodName, meta.PodNamespace)
+		if err != nil {
+			logging.FromContext(ctx).Error(err, "failed to get pod for expiring volume")
+			continue
+		}
+		util.EmitWarningEvent(ctx, n.cosiClient.Recorder(), pod, event)
+	}
+}
+
+func (n *NodeServer) forgetExpiry(volID string, meta Metadata) {
+	n.expiry.forget(volID)
+	n.presignExpired.forget(volID)
+	metrics.CredentialsExpirySeconds.DeleteLabelValues(volID, meta.PodNamespace, meta.PodName)
+}
@@ -0,0 +1,213 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc/codes"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/tools/record"
+
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client/fake"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+	testutils "sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util/test"
+)
+
+const expiryAnnotation = "example.com/expires-at"
+
+var testNow = time.Date(2021, time.January, 1, 12, 0, 0, 0, time.UTC)
+
+func TestNodePublishVolumeExpiry(t *testing.T) {
+	type args struct {
+		expiresAt string
+	}
+
+	type want struct {
+		err       error
+		expiresAt interface{}
+	}
+
+	cases := map[string]struct {
+		args
+		want
+	}{
+		"NoAnnotation": {
+			args: args{},
+			want: want{
+				err:       nil,
+				expiresAt: nil,
+			},
+		},
+		"NotExpired": {
+			args: args{
+				expiresAt: "2021-01-01T13:00:00Z",
+			},
+			want: want{
+				err:       nil,
+				expiresAt: "2021-01-01T13:00:00Z",
+			},
+		},
+		"Expired": {
+			args: args{
+				expiresAt: "2021-01-01T11:00:00Z",
+			},
+			want: want{
+				err: genRPCError(codes.FailedPrecondition, fmt.Errorf(util.ErrorTemplateCredentialsExpired, "2021-01-01T11:00:00Z")),
+			},
+		},
+		"InvalidAnnotation": {
+			args: args{
+				expiresAt: "tomorrow",
+			},
+			want: want{
+				err: genRPCError(codes.FailedPrecondition, fmt.Errorf("%s: %s", util.WrapErrorInvalidExpiryAnnotation,
+					`parsing time "tomorrow" as "2006-01-02T15:04:05Z07:00": cannot parse "tomorrow" as "2006"`)),
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			written := map[string][]byte{}
+			ns := &NodeServer{
+				name:        name,
+				nodeID:      nodeId,
+				volumeLimit: volLimit,
+				cosiClient: &fake.FakeNodeClient{
+					MockGetResources: func(ctx context.Context, barName, barNs, podName, podNs string) (bkt *v1alpha1.Bucket, ba *v1alpha1.BucketAccess, pod *v1.Pod, err error) {
+						return testutils.GetB(), testutils.GetBA(), testutils.GetPod(), nil
+					},
+					MockGetSecret: func(ctx context.Context, pod *v1.Pod, ba *v1alpha1.BucketAccess) (*v1.Secret, error) {
+						secret := testutils.GetSecret()
+						if tc.args.expiresAt != "" {
+							secret.Annotations = map[string]string{expiryAnnotation: tc.args.expiresAt}
+						}
+						return secret, nil
+					},
+					MockUpdateBAFinalizers: func(ctx context.Context, baName string, add map[string]client.FinalizerOwner, remove []string) (*v1alpha1.BucketAccess, error) {
+						return testutils.GetBA(), nil
+					},
+					MockRecorder: record.NewFakeRecorder(10),
+				},
+				provisioner: getTestProvisioner(
+					&fake.MockProvisionerClient{
+						MockReadFile: noStage,
+						MockMkdirAll: func(path string, perm os.FileMode) error {
+							return nil
+						},
+						MockRename: func(oldpath, newpath string) error {
+							written[filepath.Base(newpath)] = written[filepath.Base(oldpath)]
+							return nil
+						},
+						MockWriteFile: func(data []byte, fp string) error {
+							written[filepath.Base(fp)] = data
+							return nil
+						},
+						MockRemoveAll: func(path string) error {
+							return nil
+						},
+					},
+				),
+				expiryAnnotation: expiryAnnotation,
+				clock:            clock.NewFakeClock(testNow),
+			}
+
+			_, err := ns.NodePublishVolume(ctx, &csi.NodePublishVolumeRequest{
+				VolumeContext: map[string]string{
+					client.BarNameKey:      testutils.GetBAR().Name,
+					client.PodNameKey:      podName,
+					client.PodNamespaceKey: testutils.Namespace,
+				},
+				VolumeId:   provVolumeId,
+				TargetPath: provTargetPath,
+			})
+
+			if diff := cmp.Diff(tc.want.err, err, util.EquateErrors()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+
+			if err != nil {
+				return
+			}
+
+			conn := map[string]interface{}{}
+			if err := json.Unmarshal(written[protocolFileName], &conn); err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(tc.want.expiresAt, conn[expiresAtKey]); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCheckExpiry(t *testing.T) {
+	expiresAt := testNow.Add(30 * time.Minute)
+	recorder := record.NewFakeRecorder(10)
+	fakeClock := clock.NewFakeClock(testNow)
+
+	ns := &NodeServer{
+		name:        name,
+		nodeID:      nodeId,
+		volumeLimit: volLimit,
+		cosiClient: &fake.FakeNodeClient{
+			MockGetPod: func(ctx context.Context, podName, podNs string) (*v1.Pod, error) {
+				return testutils.GetPod(), nil
+			},
+			MockRecorder: recorder,
+		},
+		provisioner: getTestProvisioner(
+			&fake.MockProvisionerClient{
+				MockReadDir: func(dirname string) ([]os.FileInfo, error) {
+					return []os.FileInfo{fakeDirEntry{name: provVolumeId}}, nil
+				},
+				MockReadFile: func(filename string) ([]byte, error) {
+					return json.Marshal(Metadata{
+						BaName:       testutils.GetBA().Name,
+						PodName:      podName,
+						PodNamespace: testutils.Namespace,
+						ExpiresAt:    &expiresAt,
+					})
+				},
+			},
+		),
+		expiryAnnotation:    expiryAnnotation,
+		expiryWarningWindow: time.Hour,
+		clock:               fakeClock,
+	}
+
+	steps := []struct {
+		advance time.Duration
+		events  []string
+	}{
+		{advance: 0, events: []string{fmt.Sprintf("%s %s Volume %s: %s", v1.EventTypeWarning, util.CredentialsExpiry, provVolumeId, "Mounted credentials are about to expire")}},
+		{advance: time.Minute, events: nil},
+		{advance: time.Hour, events: []string{fmt.Sprintf("%s %s Volume %s: %s", v1.EventTypeWarning, util.CredentialsExpiry, provVolumeId, "Mounted credentials have expired")}},
+		{advance: time.Minute, events: nil},
+	}
+
+	for i, step := range steps {
+		fakeClock.Step(step.advance)
+		ns.checkExpiry(ctx)
+
+		var events []string
+		for len(recorder.Events) > 0 {
+			events = append(events, <-recorder.Events)
+		}
+
+		if diff := cmp.Diff(step.events, events); diff != "" {
+			t.Errorf("step %d: -want, +got:\n%s", i, diff)
+		}
+	}
+}
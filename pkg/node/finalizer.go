@@ -0,0 +1,165 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/logging"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+const (
+	// Finalizer prefixes the finalizers the adapter adds to the bucketAccesses of published volumes.
+	Finalizer = "cosi.objectstorage.k8s.io/bucketaccess-protection"
+
+	// finalizerHashLength bounds the length of volume finalizers well below the 63 character limit
+	// on the name segment of a finalizer.
+	finalizerHashLength = 16
+)
+
+// volumeFinalizer returns the finalizer protecting a bucketAccess while the given volume uses it.
+// Each volume gets its own finalizer, so that unpublishing one volume never drops the protection of
+// another volume using the same bucketAccess.
+func volumeFinalizer(volID string) string {
+	return fmt.Sprintf("%s-%s", Finalizer, util.Checksum([]byte(volID))[:finalizerHashLength])
+}
+
+// finalizers returns the finalizer of the volume, recording the node and the pod it is published
+// to as its owner.
+func (n *NodeServer) finalizers(volID string, meta Metadata) map[string]client.FinalizerOwner {
+	return map[string]client.FinalizerOwner{
+		volumeFinalizer(volID): {
+			Node:         n.nodeID,
+			PodNamespace: meta.PodNamespace,
+			PodName:      meta.PodName,
+			PodUID:       meta.PodUID,
+		},
+	}
+}
+
+func podUID(pod *v1.Pod) types.UID {
+	if pod == nil {
+		return ""
+	}
+	return pod.UID
+}
+
+// legacyFinalizer is the per-pod finalizer used by earlier releases. It is only used to migrate
+// existing volumes to volumeFinalizer.
+func (m Metadata) legacyFinalizer() string {
+	return fmt.Sprintf("%s-%s-%s", Finalizer, m.PodNamespace, m.PodName)
+}
+
+// LegacyFinalizerPods returns the pods a legacy finalizer may have been added for. Namespace and
+// pod names may both hold dashes, so every valid split of the finalizer is returned. Finalizers
+// which are not legacy ones return none.
+func LegacyFinalizerPods(f string) []types.NamespacedName {
+	rest := strings.TrimPrefix(f, Finalizer+"-")
+	if rest == f {
+		return nil
+	}
+
+	var pods []types.NamespacedName
+	for i, c := range rest {
+		if c != '-' {
+			continue
+		}
+		ns, pod := rest[:i], rest[i+1:]
+		if len(validation.IsDNS1123Label(ns)) == 0 && len(validation.IsDNS1123Subdomain(pod)) == 0 {
+			pods = append(pods, types.NamespacedName{Namespace: ns, Name: pod})
+		}
+	}
+	return pods
+}
+
+// migrateFinalizers replaces the legacy finalizers of all volumes published on this node with
+// per-volume finalizers, and records the owner of finalizers added before owners were recorded.
+// Finalizers are swapped in a single update, so the bucketAccess stays protected. No finalizer can
+// be added to a bucketAccess being deleted, its legacy finalizers are kept until the volumes are
+// unpublished. The bucketAccesses are looked up in the informer cache, which has to be synced.
+func (n *NodeServer) migrateFinalizers(ctx context.Context) {
+	logger := logging.FromContext(ctx)
+	volIDs, err := n.provisioner.listVolumes()
+	if err != nil {
+		logger.Error(err, "failed to list volumes for finalizer migration")
+		return
+	}
+
+	type change struct {
+		add    map[string]client.FinalizerOwner
+		remove []string
+	}
+	changes := map[string]*change{}
+	for _, volID := range volIDs {
+		meta, err := n.provisioner.readMetadata(volID)
+		if err != nil {
+			logger.Error(err, "skipping finalizer migration of volume", "volumeID", volID)
+			continue
+		}
+		if meta.BrName != "" {
+			continue
+		}
+
+		c, ok := changes[meta.BaName]
+		if !ok {
+			c = &change{add: map[string]client.FinalizerOwner{}}
+			changes[meta.BaName] = c
+		}
+		for f, owner := range n.finalizers(volID, meta) {
+			c.add[f] = owner
+		}
+		c.remove = append(c.remove, meta.legacyFinalizer())
+	}
+
+	for baName, c := range changes {
+		ba, err := n.cosiClient.GetCachedBA(baName)
+		if kerrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			logger.Error(err, "failed to get bucketAccess for finalizer migration", "bucketAccess", baName)
+			continue
+		}
+		if ba.DeletionTimestamp != nil {
+			logger.Info("keeping legacy finalizers of bucketAccess being deleted", "bucketAccess", baName)
+			continue
+		}
+		if _, err := n.cosiClient.UpdateBAFinalizers(ctx, baName, c.add, c.remove); err != nil {
+			logger.Error(err, "failed to migrate finalizers", "bucketAccess", baName)
+		}
+	}
+}
+
+// unpublishFinalizers returns the finalizers to remove from the bucketAccess once the volume is
+// unpublished: the finalizer of the volume, and the legacy finalizer of its pod, unless another
+// volume on this node may still rely on it. Legacy finalizers are left on bucketAccesses being
+// deleted when the finalizers are migrated.
+func (n *NodeServer) unpublishFinalizers(ctx context.Context, volID string, meta Metadata) []string {
+	remove := []string{volumeFinalizer(volID)}
+	volIDs, err := n.provisioner.listVolumes()
+	if err != nil {
+		logging.FromContext(ctx).Error(err, "keeping legacy finalizer, failed to list volumes")
+		return remove
+	}
+	for _, other := range volIDs {
+		if other == volID {
+			continue
+		}
+		m, err := n.provisioner.readMetadata(other)
+		if err != nil {
+			logging.FromContext(ctx).Error(err, "keeping legacy finalizer, failed to read volume", "otherVolumeID", other)
+			return remove
+		}
+		if m.BaName == meta.BaName && m.legacyFinalizer() == meta.legacyFinalizer() {
+			return remove
+		}
+	}
+	return append(remove, meta.legacyFinalizer())
+}
@@ -0,0 +1,194 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client/fake"
+	testutils "sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util/test"
+)
+
+func TestVolumeFinalizer(t *testing.T) {
+	longVolID := "csi-" + strings.Repeat("0123456789abcdef", 8)
+
+	name := strings.TrimPrefix(volumeFinalizer(longVolID), "cosi.objectstorage.k8s.io/")
+	if len(name) > 63 {
+		t.Errorf("finalizer name %q is longer than 63 characters", name)
+	}
+
+	if volumeFinalizer("vol-1") == volumeFinalizer("vol-2") {
+		t.Errorf("volumes share finalizer %q", volumeFinalizer("vol-1"))
+	}
+}
+
+func TestLegacyFinalizerPods(t *testing.T) {
+	cases := map[string]struct {
+		finalizer string
+		want      []types.NamespacedName
+	}{
+		"Legacy": {
+			finalizer: Finalizer + "-test-pod",
+			want:      []types.NamespacedName{{Namespace: "test", Name: "pod"}},
+		},
+		"Ambiguous": {
+			finalizer: Finalizer + "-my-ns-my-pod",
+			want: []types.NamespacedName{
+				{Namespace: "my", Name: "ns-my-pod"},
+				{Namespace: "my-ns", Name: "my-pod"},
+				{Namespace: "my-ns-my", Name: "pod"},
+			},
+		},
+		"Volume": {
+			finalizer: volumeFinalizer("vol-1"),
+		},
+		"Other": {
+			finalizer: "other-test-pod",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, LegacyFinalizerPods(tc.finalizer)); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMigrateFinalizers(t *testing.T) {
+	type update struct {
+		add    map[string]client.FinalizerOwner
+		remove []string
+	}
+
+	legacy := fmt.Sprintf("%s-%s-%s", Finalizer, testutils.Namespace, podName)
+	owner := client.FinalizerOwner{Node: nodeId, PodNamespace: testutils.Namespace, PodName: podName}
+
+	cases := map[string]struct {
+		volumes map[string]string
+		// deleting lists the bucketAccesses being deleted
+		deleting []string
+		want     map[string]update
+	}{
+		"NoVolumes": {
+			volumes: map[string]string{},
+			want:    map[string]update{},
+		},
+		"DeletingBucketAccess": {
+			volumes: map[string]string{
+				"vol-1": "bucketAccessName",
+				"vol-2": "otherBucketAccess",
+			},
+			deleting: []string{"bucketAccessName"},
+			want: map[string]update{
+				"otherBucketAccess": {
+					add:    map[string]client.FinalizerOwner{volumeFinalizer("vol-2"): owner},
+					remove: []string{legacy},
+				},
+			},
+		},
+		"SharedBucketAccess": {
+			volumes: map[string]string{
+				"vol-1": "bucketAccessName",
+				"vol-2": "bucketAccessName",
+				"vol-3": "otherBucketAccess",
+			},
+			want: map[string]update{
+				"bucketAccessName": {
+					add:    map[string]client.FinalizerOwner{volumeFinalizer("vol-1"): owner, volumeFinalizer("vol-2"): owner},
+					remove: []string{legacy, legacy},
+				},
+				"otherBucketAccess": {
+					add:    map[string]client.FinalizerOwner{volumeFinalizer("vol-3"): owner},
+					remove: []string{legacy},
+				},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := map[string]update{}
+			ns := &NodeServer{
+				name:   name,
+				nodeID: nodeId,
+				cosiClient: &fake.FakeNodeClient{
+					MockGetCachedBA: func(baName string) (*v1alpha1.BucketAccess, error) {
+						ba := testutils.GetBA()
+						ba.Name = baName
+						for _, deleting := range tc.deleting {
+							if deleting == baName {
+								ba.DeletionTimestamp = &metav1.Time{Time: testNow}
+							}
+						}
+						return ba, nil
+					},
+					MockUpdateBAFinalizers: func(ctx context.Context, baName string, add map[string]client.FinalizerOwner, remove []string) (*v1alpha1.BucketAccess, error) {
+						got[baName] = update{add: add, remove: remove}
+						return testutils.GetBA(), nil
+					},
+				},
+				provisioner: getTestProvisioner(withVolumes(tc.volumes, &[]string{})),
+				volumeLimit: volLimit,
+			}
+
+			ns.migrateFinalizers(ctx)
+
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(update{}),
+				cmpopts.SortSlices(func(a, b string) bool { return a < b })); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestUnpublishFinalizers(t *testing.T) {
+	legacy := fmt.Sprintf("%s-%s-%s", Finalizer, testutils.Namespace, podName)
+
+	cases := map[string]struct {
+		volumes map[string]string
+		want    []string
+	}{
+		"LastVolumeOfPod": {
+			volumes: map[string]string{
+				"vol-1": "bucketAccessName",
+				"vol-2": "otherBucketAccess",
+			},
+			want: []string{volumeFinalizer("vol-1"), legacy},
+		},
+		"OtherVolumeOfPod": {
+			volumes: map[string]string{
+				"vol-1": "bucketAccessName",
+				"vol-2": "bucketAccessName",
+			},
+			want: []string{volumeFinalizer("vol-1")},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			ns := &NodeServer{
+				name:        name,
+				nodeID:      nodeId,
+				provisioner: getTestProvisioner(withVolumes(tc.volumes, &[]string{})),
+			}
+			meta, err := ns.provisioner.readMetadata("vol-1")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(tc.want, ns.unpublishFinalizers(ctx, "vol-1", meta)); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/identity"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+// ReadinessChecks returns the conditions which must hold before the node server is able to publish volumes.
+func (n *NodeServer) ReadinessChecks() []identity.Check {
+	return []identity.Check{
+		{Name: "apiserver", Check: n.cosiClient.Ping},
+		{Name: "informers", Check: n.checkInformersSynced},
+		{Name: "data-root", Check: n.provisioner.checkDataRootWritable},
+		{Name: "mounter", Check: n.provisioner.checkMounter},
+	}
+}
+
+func (n *NodeServer) checkInformersSynced(ctx context.Context) error {
+	if !n.cosiClient.HasSynced() {
+		return util.ErrorInformersNotSynced
+	}
+	return nil
+}
+
+func (p Provisioner) checkDataRootWritable(ctx context.Context) error {
+	probe := filepath.Join(p.dataPath, fmt.Sprintf(".probe-%d", time.Now().UnixNano()))
+	if err := p.pclient.WriteFile([]byte{}, probe); err != nil {
+		return errors.Wrap(err, util.WrapErrorDataRootNotWritable)
+	}
+	if err := p.pclient.RemoveAll(probe); err != nil {
+		return errors.Wrap(err, util.WrapErrorDataRootNotWritable)
+	}
+	return nil
+}
+
+func (p Provisioner) checkMounter(ctx context.Context) error {
+	if _, err := p.mounter.List(); err != nil {
+		return errors.Wrap(err, util.WrapErrorMounterUnhealthy)
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package node
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client/fake"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/identity"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+func TestReadinessChecks(t *testing.T) {
+	type args struct {
+		nclient     *fake.FakeNodeClient
+		provisioner Provisioner
+	}
+
+	type want struct {
+		reasons []string
+	}
+
+	healthyClient := func() *fake.FakeNodeClient {
+		return &fake.FakeNodeClient{
+			MockPing: func(ctx context.Context) error {
+				return nil
+			},
+			MockHasSynced: func() bool {
+				return true
+			},
+		}
+	}
+
+	cases := map[string]struct {
+		args
+		want
+	}{
+		"Ready": {
+			args: args{
+				nclient: healthyClient(),
+				provisioner: getTestProvisioner(
+					&fake.MockProvisionerClient{
+						MockWriteFile: func(data []byte, filepath string) error {
+							return nil
+						},
+						MockRemoveAll: func(path string) error {
+							return nil
+						},
+					},
+				),
+			},
+			want: want{
+				reasons: nil,
+			},
+		},
+		"APIServerUnreachable": {
+			args: args{
+				nclient: &fake.FakeNodeClient{
+					MockPing: func(ctx context.Context) error {
+						return errBoom
+					},
+					MockHasSynced: func() bool {
+						return true
+					},
+				},
+				provisioner: getTestProvisioner(
+					&fake.MockProvisionerClient{
+						MockWriteFile: func(data []byte, filepath string) error {
+							return nil
+						},
+						MockRemoveAll: func(path string) error {
+							return nil
+						},
+					},
+				),
+			},
+			want: want{
+				reasons: []string{"apiserver: " + errBoom.Error()},
+			},
+		},
+		"InformersNotSynced": {
+			args: args{
+				nclient: &fake.FakeNodeClient{
+					MockPing: func(ctx context.Context) error {
+						return nil
+					},
+					MockHasSynced: func() bool {
+						return false
+					},
+				},
+				provisioner: getTestProvisioner(
+					&fake.MockProvisionerClient{
+						MockWriteFile: func(data []byte, filepath string) error {
+							return nil
+						},
+						MockRemoveAll: func(path string) error {
+							return nil
+						},
+					},
+				),
+			},
+			want: want{
+				reasons: []string{"informers: " + util.ErrorInformersNotSynced.Error()},
+			},
+		},
+		"DataRootReadOnly": {
+			args: args{
+				nclient: healthyClient(),
+				provisioner: getTestProvisioner(
+					&fake.MockProvisionerClient{
+						MockWriteFile: func(data []byte, filepath string) error {
+							return os.ErrPermission
+						},
+					},
+				),
+			},
+			want: want{
+				reasons: []string{"data-root: " + errors.Wrap(os.ErrPermission, util.WrapErrorDataRootNotWritable).Error()},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			ns := &NodeServer{
+				name:        name,
+				nodeID:      nodeId,
+				cosiClient:  tc.nclient,
+				provisioner: tc.provisioner,
+				volumeLimit: volLimit,
+			}
+
+			reasons := identity.NewReadiness(ns.ReadinessChecks()...).Reasons(ctx)
+
+			if diff := cmp.Diff(tc.want.reasons, reasons); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package node
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	v1 "k8s.io/api/core/v1"
+
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/logging"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/protocol"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+// RenderInfoFiles returns the files of a volume holding only the connection information of the
+// bucket, keyed by file name.
+func RenderInfoFiles(bkt *v1alpha1.Bucket) (map[string][]byte, error) {
+	p, err := protocol.Lookup(bkt)
+	if err != nil {
+		return nil, err
+	}
+	protocolConnection, err := p.Connection(bkt)
+	if err != nil {
+		return nil, err
+	}
+	return map[string][]byte{
+		protocolFileName: protocolConnection,
+	}, nil
+}
+
+// validateBucket validates the bucket against its protocol, before anything is written for it, and
+// reports why it is unusable on the pod.
+func (n *NodeServer) validateBucket(ctx context.Context, pod *v1.Pod, bkt *v1alpha1.Bucket) error {
+	if _, err := protocol.Lookup(bkt); err != nil {
+		util.EmitWarningEvent(ctx, n.cosiClient.Recorder(), pod, protocol.Event(err))
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return nil
+}
+
+// publishBucketInfo publishes the connection information of the bucket of a bucketRequest, for pods
+// which need no credentials, like readers of public buckets, or get them elsewhere, e.g. through
+// workload identity, with the service account token of the pod when requested. No bucketAccess is
+// involved, so there is nothing to stage, revoke or protect with a finalizer, and the files are
+// written to the volume itself.
+func (n *NodeServer) publishBucketInfo(ctx context.Context, volID string, request *csi.NodePublishVolumeRequest) (*csi.NodePublishVolumeResponse, error) {
+	brName, podName, podNs, err := client.ParseBucketInfoContext(request.GetVolumeContext())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	audience, _, err := client.ParseWebIdentity(request.GetVolumeContext())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	bkt, pod, err := n.cosiClient.GetBucketInfo(ctx, brName, podName, podNs)
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	if err := n.validateBucket(ctx, pod, bkt); err != nil {
+		return nil, err
+	}
+
+	files, err := RenderInfoFiles(bkt)
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	if audience != "" {
+		tokenFiles, err := webIdentityFiles(request.GetVolumeContext(), audience, bkt.Spec.Protocol.S3 != nil)
+		if err != nil {
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
+		for name, data := range tokenFiles {
+			files[name] = data
+		}
+	}
+
+	cleanup := func(err error, errWrap string) (*csi.NodePublishVolumeResponse, error) {
+		if rmErr := n.provisioner.removeDir(volID); rmErr != nil {
+			logging.FromContext(ctx).Error(rmErr, "failed to remove volume directory after error")
+			return nil, status.Error(codes.Internal, errors.Wrap(errors.Wrap(rmErr, util.WrapErrorFailedRemoveDirectory), errWrap).Error())
+		}
+		return nil, status.Error(codes.Internal, errors.Wrap(err, errWrap).Error())
+	}
+
+	if err := n.provisioner.pclient.MkdirAll(n.provisioner.bucketPath(volID), 0750); err != nil {
+		return cleanup(err, util.WrapErrorFailedToCreateVolumeDir)
+	}
+	checksums := map[string]string{}
+	for name, data := range files {
+		if err := n.provisioner.pclient.WriteFile(data, filepath.Join(n.provisioner.bucketPath(volID), name)); err != nil {
+			return cleanup(errors.Wrap(err, util.WrapErrorFailedToCreateBucketFile), util.WrapErrorFailedToWriteProtocol)
+		}
+		checksums[name] = util.Checksum(data)
+	}
+
+	if err := n.provisioner.mountDir(n.provisioner.bucketPath(volID), request.GetTargetPath()); err != nil {
+		return cleanup(err, util.WrapErrorFailedToMountVolume)
+	}
+
+	now := n.clock.Now()
+	meta := Metadata{
+		Version:       metadataVersion,
+		VolumeID:      volID,
+		BrName:        brName,
+		PodName:       podName,
+		PodNamespace:  podNs,
+		TargetPath:    request.GetTargetPath(),
+		BucketName:    bkt.Name,
+		Format:        protocol.FormatJSON,
+		Files:         checksums,
+		TokenAudience: audience,
+		CreatedAt:     &now,
+		UpdatedAt:     now,
+	}
+	if err := n.provisioner.writeMetadata(volID, meta); err != nil {
+		return cleanup(err, util.WrapErrorFailedToWriteMetadata)
+	}
+
+	util.EmitNormalEvent(ctx, n.cosiClient.Recorder(), pod, util.SuccessfullyPublishedVolume)
+	return &csi.NodePublishVolumeResponse{}, nil
+}
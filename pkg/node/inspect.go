@@ -0,0 +1,73 @@
+package node
+
+import (
+	"context"
+
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/logging"
+)
+
+// VolumeState describes a volume staged under the data root, as shown by `csi-adapter inspect`.
+type VolumeState struct {
+	VolumeID     string `json:"volumeID"`
+	TargetPath   string `json:"targetPath,omitempty"`
+	PodNamespace string `json:"podNamespace,omitempty"`
+	PodName      string `json:"podName,omitempty"`
+	BucketAccess string `json:"bucketAccess,omitempty"`
+	// BucketRequest is only set for volumes holding bucket information without credentials.
+	BucketRequest string `json:"bucketRequest,omitempty"`
+	Mounted       bool   `json:"mounted"`
+	// FinalizerPresent is unset when the bucketAccess could not be looked up.
+	FinalizerPresent *bool `json:"finalizerPresent,omitempty"`
+	// Error describes why the state of the volume is incomplete, if it is.
+	Error string `json:"error,omitempty"`
+}
+
+// InspectVolumes reports the state of every volume under the data root of the provisioner.
+// The finalizers are looked up through the cache of cosiClient, which has to be synced already.
+// If cosiClient is nil, the finalizers are not looked up.
+func InspectVolumes(ctx context.Context, p Provisioner, cosiClient client.NodeClient) ([]VolumeState, error) {
+	volIDs, err := p.listVolumes()
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]VolumeState, 0, len(volIDs))
+	for _, volID := range volIDs {
+		state := VolumeState{VolumeID: volID}
+
+		meta, err := p.readMetadata(volID)
+		if err != nil {
+			state.Error = err.Error()
+			states = append(states, state)
+			continue
+		}
+
+		state.TargetPath = meta.TargetPath
+		state.PodNamespace = meta.PodNamespace
+		state.PodName = meta.PodName
+		state.BucketAccess = meta.BaName
+		state.BucketRequest = meta.BrName
+
+		if meta.TargetPath != "" {
+			if state.Mounted, err = p.isMounted(meta.TargetPath); err != nil {
+				logging.FromContext(ctx).Error(err, "failed to check mount point", "volumeID", volID, "targetPath", meta.TargetPath)
+			}
+		}
+
+		if cosiClient != nil && meta.BrName == "" {
+			ba, err := cosiClient.GetCachedBA(meta.BaName)
+			if err != nil {
+				state.Error = err.Error()
+			} else {
+				present := controllerutil.ContainsFinalizer(ba, volumeFinalizer(volID))
+				state.FinalizerPresent = &present
+			}
+		}
+
+		states = append(states, state)
+	}
+	return states, nil
+}
@@ -0,0 +1,167 @@
+package node
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"k8s.io/mount-utils"
+
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client/fake"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+	testutils "sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util/test"
+)
+
+func TestInspectVolumes(t *testing.T) {
+	// the fake mounter stats the target path, so it has to exist
+	mountedPath := os.TempDir()
+
+	inspectProvisionerClient := func(metadata map[string]*Metadata) *fake.MockProvisionerClient {
+		return &fake.MockProvisionerClient{
+			MockReadDir: func(dirname string) ([]os.FileInfo, error) {
+				var entries []os.FileInfo
+				for _, volID := range []string{"vol-1", "vol-2"} {
+					if _, ok := metadata[volID]; ok {
+						entries = append(entries, fakeDirEntry{name: volID})
+					}
+				}
+				return entries, nil
+			},
+			MockReadFile: func(filename string) ([]byte, error) {
+				meta := metadata[filepath.Base(filepath.Dir(filename))]
+				if meta == nil {
+					return nil, errBoom
+				}
+				return json.Marshal(meta)
+			},
+		}
+	}
+
+	yes, no := true, false
+
+	type args struct {
+		metadata map[string]*Metadata
+		nclient  *fake.FakeNodeClient
+	}
+
+	type want struct {
+		states []VolumeState
+	}
+
+	cases := map[string]struct {
+		args
+		want
+	}{
+		"MountedWithFinalizer": {
+			args: args{
+				metadata: map[string]*Metadata{
+					"vol-1": {BaName: "bucketAccessName", PodName: podName, PodNamespace: testutils.Namespace, TargetPath: mountedPath},
+					"vol-2": {BaName: "bucketAccessName", PodName: podName, PodNamespace: testutils.Namespace},
+				},
+				nclient: &fake.FakeNodeClient{
+					MockGetCachedBA: func(baName string) (*v1alpha1.BucketAccess, error) {
+						ba := testutils.GetBA()
+						ba.Finalizers = []string{volumeFinalizer("vol-1")}
+						return ba, nil
+					},
+				},
+			},
+			want: want{
+				states: []VolumeState{
+					{
+						VolumeID:         "vol-1",
+						TargetPath:       mountedPath,
+						PodNamespace:     testutils.Namespace,
+						PodName:          podName,
+						BucketAccess:     "bucketAccessName",
+						Mounted:          true,
+						FinalizerPresent: &yes,
+					},
+					{
+						VolumeID:         "vol-2",
+						PodNamespace:     testutils.Namespace,
+						PodName:          podName,
+						BucketAccess:     "bucketAccessName",
+						Mounted:          false,
+						FinalizerPresent: &no,
+					},
+				},
+			},
+		},
+		"Offline": {
+			args: args{
+				metadata: map[string]*Metadata{
+					"vol-1": {BaName: "bucketAccessName", PodName: podName, PodNamespace: testutils.Namespace, TargetPath: mountedPath},
+				},
+			},
+			want: want{
+				states: []VolumeState{
+					{
+						VolumeID:     "vol-1",
+						TargetPath:   mountedPath,
+						PodNamespace: testutils.Namespace,
+						PodName:      podName,
+						BucketAccess: "bucketAccessName",
+						Mounted:      true,
+					},
+				},
+			},
+		},
+		"BrokenVolumes": {
+			args: args{
+				metadata: map[string]*Metadata{
+					"vol-1": nil,
+					"vol-2": {BaName: "deletedBucketAccess", PodName: podName, PodNamespace: testutils.Namespace},
+				},
+				nclient: &fake.FakeNodeClient{
+					MockGetCachedBA: func(baName string) (*v1alpha1.BucketAccess, error) {
+						return nil, errBoom
+					},
+				},
+			},
+			want: want{
+				states: []VolumeState{
+					{
+						VolumeID: "vol-1",
+						Error:    errors.Wrap(errBoom, util.WrapErrorFailedToReadMetadataFile).Error(),
+					},
+					{
+						VolumeID:     "vol-2",
+						PodNamespace: testutils.Namespace,
+						PodName:      podName,
+						BucketAccess: "deletedBucketAccess",
+						Error:        errBoom.Error(),
+					},
+				},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			p := getTestProvisioner(
+				inspectProvisionerClient(tc.metadata),
+				withMountPoints([]mount.MountPoint{{Path: mountedPath}}),
+			)
+
+			var states []VolumeState
+			var err error
+			if tc.nclient == nil {
+				states, err = InspectVolumes(ctx, p, nil)
+			} else {
+				states, err = InspectVolumes(ctx, p, tc.nclient)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(tc.want.states, states); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
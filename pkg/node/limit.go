@@ -0,0 +1,90 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/logging"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/metrics"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+// volumeTracker counts the volumes published on the node. The scheduler counts persistent volumes
+// against the volume limit of the node, but not inline ephemeral volumes, so the node enforces the
+// limit on those itself.
+type volumeTracker struct {
+	sync.Mutex
+	volIDs map[string]bool
+}
+
+// load replaces the tracked volumes with the given ones.
+func (v *volumeTracker) load(volIDs []string) {
+	v.Lock()
+	defer v.Unlock()
+	v.volIDs = map[string]bool{}
+	for _, volID := range volIDs {
+		v.volIDs[volID] = true
+	}
+	metrics.PublishedVolumes.Set(float64(len(v.volIDs)))
+}
+
+// reserve tracks the volume, unless it is a new inline volume and limit volumes, if limit is
+// positive, are published already. It returns the number of published volumes and whether the
+// volume was tracked.
+func (v *volumeTracker) reserve(volID string, limit int64) (int, bool) {
+	v.Lock()
+	defer v.Unlock()
+	if v.volIDs == nil {
+		v.volIDs = map[string]bool{}
+	}
+	if v.volIDs[volID] {
+		return len(v.volIDs), true
+	}
+	inline := !strings.HasPrefix(volID, client.PersistentVolumeIDPrefix)
+	if inline && limit > 0 && int64(len(v.volIDs)) >= limit {
+		return len(v.volIDs), false
+	}
+	v.volIDs[volID] = true
+	metrics.PublishedVolumes.Set(float64(len(v.volIDs)))
+	return len(v.volIDs), true
+}
+
+func (v *volumeTracker) release(volID string) {
+	v.Lock()
+	defer v.Unlock()
+	delete(v.volIDs, volID)
+	metrics.PublishedVolumes.Set(float64(len(v.volIDs)))
+}
+
+// trackVolumes starts tracking the volumes already published in the data root.
+func (n *NodeServer) trackVolumes(ctx context.Context) {
+	logger := logging.FromContext(ctx)
+	volIDs, err := n.provisioner.listVolumes()
+	if err != nil {
+		logger.Error(err, "failed to list volumes for the volume limit")
+		return
+	}
+	n.volumes.load(volIDs)
+	logger.Info("tracking published volumes", "count", len(volIDs), "limit", n.volumeLimit)
+}
+
+// reserveVolume tracks a volume about to be published, failing when the volume limit of the node is
+// reached. The pod is told why its volume is not published.
+func (n *NodeServer) reserveVolume(ctx context.Context, volID, podName, podNs string) error {
+	count, ok := n.volumes.reserve(volID, n.volumeLimit)
+	if ok {
+		return nil
+	}
+
+	err := fmt.Errorf(util.ErrorTemplateVolumeLimitReached, n.nodeID, count, n.volumeLimit)
+	pod, getErr := n.cosiClient.GetPod(ctx, podName, podNs)
+	if getErr != nil {
+		logging.FromContext(ctx).Error(getErr, "failed to get pod for volume limit event")
+		return err
+	}
+	util.EmitWarningEvent(ctx, n.cosiClient.Recorder(), pod, util.VolumeLimitExceeded)
+	return err
+}
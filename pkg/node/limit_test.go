@@ -0,0 +1,112 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc/codes"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/mount-utils"
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client/fake"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+	testutils "sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util/test"
+)
+
+func TestVolumeLimit(t *testing.T) {
+	// a volume published before the node server started
+	fs := memFS{"/data/vol-0/metadata.json": []byte("{}")}
+	recorder := record.NewFakeRecorder(10)
+
+	ns := &NodeServer{
+		name:        name,
+		nodeID:      nodeId,
+		volumeLimit: 2,
+		cosiClient: &fake.FakeNodeClient{
+			MockGetResources: func(ctx context.Context, barName, barNs, podName, podNs string) (*v1alpha1.Bucket, *v1alpha1.BucketAccess, *v1.Pod, error) {
+				return testutils.GetB(), testutils.GetBA(), testutils.GetPod(), nil
+			},
+			MockGetSecret: func(ctx context.Context, pod *v1.Pod, ba *v1alpha1.BucketAccess) (*v1.Secret, error) {
+				return testutils.GetSecret(), nil
+			},
+			MockGetPod: func(ctx context.Context, podName, podNs string) (*v1.Pod, error) {
+				return testutils.GetPod(), nil
+			},
+			MockUpdateBAFinalizers: func(ctx context.Context, baName string, add map[string]client.FinalizerOwner, remove []string) (*v1alpha1.BucketAccess, error) {
+				return testutils.GetBA(), nil
+			},
+			MockRecorder: recorder,
+		},
+		provisioner: NewProvisioner("/data", mount.NewFakeMounter(nil), fs.client()),
+		clock:       clock.NewFakeClock(testNow),
+	}
+	ns.trackVolumes(ctx)
+
+	publish := func(volID string) error {
+		_, err := ns.NodePublishVolume(ctx, &csi.NodePublishVolumeRequest{
+			VolumeContext: map[string]string{
+				client.BarNameKey:      testutils.GetBAR().Name,
+				client.PodNameKey:      podName,
+				client.PodNamespaceKey: testutils.Namespace,
+			},
+			VolumeId:   volID,
+			TargetPath: "/pods/" + volID,
+		})
+		return err
+	}
+
+	if err := publish("vol-1"); err != nil {
+		t.Fatal(err)
+	}
+	// drop the events of the successful publish
+	drain(recorder)
+
+	want := genRPCError(codes.ResourceExhausted, fmt.Errorf(util.ErrorTemplateVolumeLimitReached, nodeId, 2, 2))
+	if diff := cmp.Diff(want, publish("vol-2"), util.EquateErrors()); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"Warning VolumeLimitExceeded Volume vol-2: " + util.VolumeLimitExceeded.Message()}, drain(recorder)); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+	if _, ok := fs["/data/vol-2/metadata.json"]; ok {
+		t.Errorf("expected the rejected volume not to be published")
+	}
+
+	// publishing an already published volume again is not a new volume
+	if err := publish("vol-1"); err != nil {
+		t.Errorf("expected the published volume to be republished, got %v", err)
+	}
+
+	// the scheduler counts persistent volumes itself
+	if err := publish(client.PersistentVolumeIDPrefix + "pvc"); err != nil {
+		t.Errorf("expected the persistent volume to be published beyond the limit, got %v", err)
+	}
+
+	for _, volID := range []string{"vol-1", client.PersistentVolumeIDPrefix + "pvc"} {
+		if _, err := ns.NodeUnpublishVolume(ctx, &csi.NodeUnpublishVolumeRequest{VolumeId: volID, TargetPath: "/pods/" + volID}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := publish("vol-2"); err != nil {
+		t.Errorf("expected the volume to be published once others were unpublished, got %v", err)
+	}
+	if diff := cmp.Diff(2, len(ns.volumes.volIDs)); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+}
+
+// drain returns the events recorded so far.
+func drain(recorder *record.FakeRecorder) []string {
+	var events []string
+	for len(recorder.Events) > 0 {
+		events = append(events, <-recorder.Events)
+	}
+	return events
+}
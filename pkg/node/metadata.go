@@ -0,0 +1,162 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/types"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/logging"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/protocol"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+const (
+	// metadataVersion is the version of the metadata schema written by this release. Files written
+	// before the schema was versioned decode as version 0.
+	metadataVersion = 1
+)
+
+// Metadata is stored next to the bucket folder of every volume, it is not mounted to the app pod.
+type Metadata struct {
+	Version  int    `json:"version"`
+	VolumeID string `json:"volumeID,omitempty"`
+
+	BaName string `json:"baName"`
+	// BrName is only set for volumes holding the bucket information of a bucketRequest, which have
+	// no bucketAccess.
+	BrName       string `json:"brName,omitempty"`
+	PodName      string `json:"podName"`
+	PodNamespace string `json:"podNamespace"`
+	TargetPath   string `json:"targetPath,omitempty"`
+	// PodUID tells the pod apart from later pods of the same name, it is unset for volumes published
+	// before it was recorded.
+	PodUID types.UID `json:"podUID,omitempty"`
+	// ServiceAccount is the service account of the pod, unset for volumes published before it was
+	// recorded.
+	ServiceAccount string `json:"serviceAccount,omitempty"`
+	// StageID names the staged credentials the volume is bind mounted from. It is unset for volumes
+	// published before credentials were staged, which hold their own copy.
+	StageID    string `json:"stageID,omitempty"`
+	BucketName string `json:"bucketName,omitempty"`
+	// Format is the format the files in the bucket folder were rendered in.
+	Format string `json:"format,omitempty"`
+	// SecretResourceVersion is the resourceVersion of the minted secret the credentials were read from.
+	SecretResourceVersion string `json:"secretResourceVersion,omitempty"`
+
+	// Files maps the name of every file written to the bucket folder to its checksum.
+	Files map[string]string `json:"files,omitempty"`
+	// ExpiresAt is the expiry of the published credentials, when known.
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	// TokenAudience is the audience of the service account token written to the volume, if any.
+	TokenAudience string `json:"tokenAudience,omitempty"`
+	// Presign is only set for volumes publishing presigned URLs instead of credentials.
+	Presign *Presign `json:"presign,omitempty"`
+	// Revocation is the message of the last revocation of the bucketAccess handled for the volume,
+	// so that it is not handled again when the adapter restarts.
+	Revocation string `json:"revocation,omitempty"`
+
+	// CreatedAt is unknown for volumes published before the schema was versioned.
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+}
+
+// upgrade fills in what is known about volumes written by older versions. Those always rendered
+// JSON, everything else they did not record stays unset.
+func (m Metadata) upgrade(volID string) Metadata {
+	if m.Version < 1 {
+		m.VolumeID = volID
+		m.Format = protocol.FormatJSON
+	}
+	m.Version = metadataVersion
+	return m
+}
+
+func (p Provisioner) readMetadata(volID string) (Metadata, error) {
+	meta, _, err := p.loadMetadata(volID)
+	return meta, err
+}
+
+// loadMetadata reads the metadata of a volume, upgrading it to the current schema in memory. It
+// reports whether the file on disk was written with an older schema.
+func (p Provisioner) loadMetadata(volID string) (Metadata, bool, error) {
+	data, err := p.readFileFromVolume(volID, metadataFilename)
+	if err != nil {
+		return Metadata{}, false, errors.Wrap(err, util.WrapErrorFailedToReadMetadataFile)
+	}
+
+	meta := Metadata{}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return Metadata{}, false, errors.Wrap(err, util.WrapErrorFailedToUnmarshalMetadata)
+	}
+
+	if meta.Version > metadataVersion {
+		return Metadata{}, false, errors.Errorf(util.ErrorTemplateUnsupportedMetadataVersion, meta.Version, metadataVersion)
+	}
+	if meta.Version == metadataVersion {
+		return meta, false, nil
+	}
+	return meta.upgrade(volID), true, nil
+}
+
+// writeMetadata replaces the metadata file of a volume atomically, so that a crash never leaves a
+// partially written file behind.
+func (p Provisioner) writeMetadata(volID string, meta Metadata) error {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return errors.Wrap(err, util.WrapErrorFailedToMarshalMetadata)
+	}
+	return p.writeFileAtomic(p.volPath(volID), metadataFilename, data)
+}
+
+// tempFileName is the name of the temporary file writeFileAtomic writes before renaming it to name.
+func tempFileName(name string) string {
+	return "." + name + ".tmp"
+}
+
+// writeFileAtomic writes a temporary file next to the file and renames it into place.
+func (p Provisioner) writeFileAtomic(dir, name string, data []byte) error {
+	tmp := filepath.Join(dir, tempFileName(name))
+	// a stale temporary file is left behind when the adapter crashed while writing
+	if err := p.pclient.RemoveAll(tmp); err != nil {
+		return errors.Wrap(err, util.WrapErrorFailedToCreateVolumeFile)
+	}
+	if err := p.pclient.WriteFile(data, tmp); err != nil {
+		return errors.Wrap(err, util.WrapErrorFailedToCreateVolumeFile)
+	}
+	if err := p.pclient.Rename(tmp, filepath.Join(dir, name)); err != nil {
+		return errors.Wrap(err, util.WrapErrorFailedToCreateVolumeFile)
+	}
+	return nil
+}
+
+// upgradeMetadata rewrites the metadata files written by older versions with the current schema.
+func (n *NodeServer) upgradeMetadata(ctx context.Context) {
+	logger := logging.FromContext(ctx)
+	volIDs, err := n.provisioner.listVolumes()
+	if err != nil {
+		logger.Error(err, "failed to list volumes for metadata upgrade")
+		return
+	}
+
+	for _, volID := range volIDs {
+		meta, upgraded, err := n.provisioner.loadMetadata(volID)
+		if err != nil {
+			logger.Error(err, "skipping metadata upgrade of volume", "volumeID", volID)
+			continue
+		}
+		if !upgraded {
+			continue
+		}
+
+		meta.UpdatedAt = n.clock.Now()
+		if err := n.provisioner.writeMetadata(volID, meta); err != nil {
+			logger.Error(err, "failed to upgrade metadata of volume", "volumeID", volID)
+			continue
+		}
+		logger.Info("upgraded metadata of volume", "volumeID", volID, "version", metadataVersion)
+	}
+}
@@ -0,0 +1,190 @@
+package node
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/clock"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client/fake"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/protocol"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+	testutils "sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util/test"
+)
+
+func TestLoadMetadata(t *testing.T) {
+	type want struct {
+		meta     Metadata
+		upgraded bool
+		err      error
+	}
+
+	cases := map[string]struct {
+		file string
+		want
+	}{
+		"Legacy": {
+			file: `{"baName":"bucketAccessName","podName":"testPodName","podNamespace":"test"}`,
+			want: want{
+				meta: Metadata{
+					Version:      metadataVersion,
+					VolumeID:     provVolumeId,
+					BaName:       "bucketAccessName",
+					PodName:      podName,
+					PodNamespace: testutils.Namespace,
+					Format:       protocol.FormatJSON,
+				},
+				upgraded: true,
+			},
+		},
+		"Current": {
+			file: `{"version":1,"volumeID":"volId-123456789","baName":"bucketAccessName","podName":"testPodName","podNamespace":"test","bucketName":"bucketName","format":"json","updatedAt":"2021-01-01T12:00:00Z"}`,
+			want: want{
+				meta: Metadata{
+					Version:      metadataVersion,
+					VolumeID:     provVolumeId,
+					BaName:       "bucketAccessName",
+					PodName:      podName,
+					PodNamespace: testutils.Namespace,
+					BucketName:   "bucketName",
+					Format:       protocol.FormatJSON,
+					UpdatedAt:    testNow,
+				},
+			},
+		},
+		"UnsupportedVersion": {
+			file: `{"version":2,"baName":"bucketAccessName"}`,
+			want: want{
+				err: errors.Errorf(util.ErrorTemplateUnsupportedMetadataVersion, 2, metadataVersion),
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			p := getTestProvisioner(&fake.MockProvisionerClient{
+				MockReadFile: func(filename string) ([]byte, error) {
+					return []byte(tc.file), nil
+				},
+			})
+
+			meta, upgraded, err := p.loadMetadata(provVolumeId)
+
+			if diff := cmp.Diff(tc.want.meta, meta); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.upgraded, upgraded); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.err, err, util.EquateErrors()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWriteMetadata(t *testing.T) {
+	type want struct {
+		ops []string
+		err error
+	}
+
+	cases := map[string]struct {
+		renameErr error
+		want
+	}{
+		"Successful": {
+			want: want{
+				ops: []string{
+					"remove " + tempFileName(metadataFilename),
+					"write " + tempFileName(metadataFilename),
+					"rename " + tempFileName(metadataFilename) + " " + metadataFilename,
+				},
+			},
+		},
+		"RenameFailed": {
+			renameErr: errBoom,
+			want: want{
+				ops: []string{
+					"remove " + tempFileName(metadataFilename),
+					"write " + tempFileName(metadataFilename),
+					"rename " + tempFileName(metadataFilename) + " " + metadataFilename,
+				},
+				err: errors.Wrap(errBoom, util.WrapErrorFailedToCreateVolumeFile),
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var ops []string
+			p := getTestProvisioner(&fake.MockProvisionerClient{
+				MockRemoveAll: func(path string) error {
+					ops = append(ops, "remove "+filepath.Base(path))
+					return nil
+				},
+				MockWriteFile: func(data []byte, fp string) error {
+					ops = append(ops, "write "+filepath.Base(fp))
+					return nil
+				},
+				MockRename: func(oldpath, newpath string) error {
+					ops = append(ops, "rename "+filepath.Base(oldpath)+" "+filepath.Base(newpath))
+					return tc.renameErr
+				},
+			})
+
+			err := p.writeMetadata(provVolumeId, Metadata{Version: metadataVersion, VolumeID: provVolumeId})
+
+			if diff := cmp.Diff(tc.want.ops, ops); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.err, err, util.EquateErrors()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestUpgradeMetadata(t *testing.T) {
+	written := map[string]Metadata{}
+	provisionerClient := withVolumes(map[string]string{"vol-1": "bucketAccessName"}, &[]string{})
+	provisionerClient.MockWriteFile = func(data []byte, fp string) error {
+		meta := Metadata{}
+		if err := json.Unmarshal(data, &meta); err != nil {
+			return err
+		}
+		written[filepath.Base(filepath.Dir(fp))] = meta
+		return nil
+	}
+	provisionerClient.MockRename = func(oldpath, newpath string) error {
+		return nil
+	}
+
+	ns := &NodeServer{
+		name:        name,
+		nodeID:      nodeId,
+		provisioner: getTestProvisioner(provisionerClient),
+		volumeLimit: volLimit,
+		clock:       clock.NewFakeClock(testNow),
+	}
+
+	ns.upgradeMetadata(ctx)
+
+	want := map[string]Metadata{
+		"vol-1": {
+			Version:      metadataVersion,
+			VolumeID:     "vol-1",
+			BaName:       "bucketAccessName",
+			PodName:      podName,
+			PodNamespace: testutils.Namespace,
+			Format:       protocol.FormatJSON,
+			UpdatedAt:    testNow,
+		},
+	}
+	if diff := cmp.Diff(want, written); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+}
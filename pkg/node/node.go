@@ -2,15 +2,27 @@ package node
 
 import (
 	"context"
-	"encoding/json"
+	"net/http"
+	"time"
+
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/pkg/errors"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
 	"k8s.io/klog/v2"
 	"k8s.io/mount-utils"
 
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/audit"
 	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/logging"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/protocol"
 	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
 )
 
@@ -22,15 +34,57 @@ const (
 	metadataFilename = "metadata.json"
 )
 
-func NewNodeServerOrDie(driverName, nodeID, dataRoot string, volumeLimit int64) csi.NodeServer {
+// Option configures optional behaviour of the NodeServer.
+type Option func(n *NodeServer)
+
+// WithRevocationPolicy sets how the node server reacts when the BucketAccess behind a published volume is revoked.
+func WithRevocationPolicy(policy RevocationPolicy) Option {
+	return func(n *NodeServer) {
+		n.revocationPolicy = policy
+	}
+}
+
+// WithCredentialsExpiry makes the node server read the expiry of minted credentials from the given
+// secret annotation, and warn pods once their credentials are within warningWindow of expiring.
+func WithCredentialsExpiry(annotation string, warningWindow time.Duration) Option {
+	return func(n *NodeServer) {
+		n.expiryAnnotation = annotation
+		n.expiryWarningWindow = warningWindow
+	}
+}
+
+// WithConnectivityCheck makes the node server check the staged credentials against the bucket
+// before reporting volumes mounted, failing the mount when the call does not succeed within
+// timeout. Protocols which cannot be checked are published unchecked.
+func WithConnectivityCheck(timeout time.Duration) Option {
+	return func(n *NodeServer) {
+		n.checkClient = &http.Client{Timeout: timeout}
+	}
+}
+
+// WithAuditor makes the node server record every publish, unpublish, rotation and revocation of
+// credentials with the auditor.
+func WithAuditor(auditor Auditor) Option {
+	return func(n *NodeServer) {
+		n.auditor = auditor
+	}
+}
+
+func NewNodeServerOrDie(driverName, nodeID, dataRoot string, volumeLimit int64, opts ...Option) *NodeServer {
 	cosiClient := client.NewClientOrDie(driverName, nodeID)
-	return &NodeServer{
-		name:        driverName,
-		nodeID:      nodeID,
-		volumeLimit: volumeLimit,
-		cosiClient:  cosiClient,
-		provisioner: NewProvisioner(dataRoot, mount.New(""), client.NewProvisionerClient()),
+	n := &NodeServer{
+		name:             driverName,
+		nodeID:           nodeID,
+		volumeLimit:      volumeLimit,
+		cosiClient:       cosiClient,
+		provisioner:      NewProvisioner(dataRoot, mount.New(""), client.NewProvisionerClient()),
+		revocationPolicy: RevocationPolicyWarn,
+		clock:            clock.RealClock{},
+	}
+	for _, opt := range opts {
+		opt(n)
 	}
+	return n
 }
 
 // NodeServer implements the NodePublishVolume and NodeUnpublishVolume methods
@@ -42,132 +96,287 @@ type NodeServer struct {
 	volumeLimit int64
 	cosiClient  client.NodeClient
 	provisioner Provisioner
+
+	revocationPolicy RevocationPolicy
+
+	expiryAnnotation    string
+	expiryWarningWindow time.Duration
+	expiry              expiryTracker
+	// presignExpired tracks the volumes whose presigned URLs cannot be refreshed, their
+	// credentials having expired.
+	presignExpired expiryTracker
+
+	// checkClient calls the bucket endpoints, the connectivity check is disabled when nil.
+	checkClient *http.Client
+
+	// auditor records the credentials handed out, auditing is disabled when nil.
+	auditor Auditor
+
+	// stages counts the volumes using each stage and serializes the work on it.
+	stages stageTracker
+
+	// volumeLocks serializes replacing the files of a volume with unpublishing it.
+	volumeLocks keyedMutex
+
+	// volumes counts the published volumes against volumeLimit.
+	volumes volumeTracker
+
+	// revocations queues the revoked bucketAccesses for a single worker.
+	revocations workqueue.RateLimitingInterface
+
+	clock clock.Clock
 }
 
-func (n *NodeServer) NodePublishVolume(ctx context.Context, request *csi.NodePublishVolumeRequest) (*csi.NodePublishVolumeResponse, error) {
-	klog.Infof("NodePublishVolume: volId: %v, targetPath: %v\n", request.GetVolumeId(), request.GetTargetPath())
+// Start runs the background machinery of the node server until stopCh is closed.
+func (n *NodeServer) Start(stopCh <-chan struct{}) {
+	ctx := context.Background()
+	n.upgradeMetadata(ctx)
+	n.trackVolumes(ctx)
+	n.trackStages(ctx)
+
+	n.revocations = workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "revocations")
+	go func() {
+		<-stopCh
+		n.revocations.ShutDown()
+	}()
+	go n.runRevocations(ctx)
+	n.cosiClient.AddBAEventHandler(n.revocationHandler())
+	n.cosiClient.Start(stopCh)
+	if cache.WaitForCacheSync(stopCh, n.cosiClient.HasSynced) {
+		n.migrateFinalizers(ctx)
+	}
+
+	if n.expiryAnnotation != "" {
+		go wait.Until(func() { n.checkExpiry(ctx) }, expiryCheckInterval, stopCh)
+	}
+	go wait.Until(func() { n.refreshPresigned(ctx) }, presignCheckInterval, stopCh)
+}
+
+func (n *NodeServer) NodePublishVolume(ctx context.Context, request *csi.NodePublishVolumeRequest) (_ *csi.NodePublishVolumeResponse, err error) {
+	volID := volumeDirID(request.GetVolumeId(), request.GetTargetPath())
+	ctx = requestContext(ctx, volID, request.GetVolumeContext())
+	logger := logging.FromContext(ctx)
+	logger.Info("NodePublishVolume", "targetPath", request.GetTargetPath())
+	defer func() {
+		if err != nil {
+			logger.Error(err, "failed to publish volume")
+		}
+	}()
+
+	if meta, err := n.provisioner.readMetadata(volID); err == nil && meta.TargetPath == request.GetTargetPath() {
+		return n.republish(ctx, volID, meta, request)
+	}
+
+	volCtx := request.GetVolumeContext()
+	if err := n.reserveVolume(ctx, volID, volCtx[client.PodNameKey], volCtx[client.PodNamespaceKey]); err != nil {
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	}
+	defer func() {
+		if err != nil {
+			n.volumes.release(volID)
+		}
+	}()
+
+	if _, ok := request.GetVolumeContext()[client.BrNameKey]; ok {
+		return n.publishBucketInfo(ctx, volID, request)
+	}
 
 	barName, podName, podNs, err := client.ParseVolumeContext(request.GetVolumeContext())
 	if err != nil {
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
-
-	bkt, ba, secret, pod, err := n.cosiClient.GetResources(ctx, barName, podName, podNs)
+	format, err := ParseFormat(request.GetVolumeContext())
 	if err != nil {
-		return nil, status.Error(codes.FailedPrecondition, err.Error())
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	presign, err := ParsePresign(request.GetVolumeContext())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	audience, _, err := client.ParseWebIdentity(request.GetVolumeContext())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
-	protocolConnection, err := client.GetProtocol(bkt)
+	barNs := client.ParseBARNamespace(request.GetVolumeContext(), podNs)
+	bkt, ba, pod, err := n.cosiClient.GetResources(ctx, barName, barNs, podName, podNs)
 	if err != nil {
 		return nil, status.Error(codes.FailedPrecondition, err.Error())
 	}
 
-	klog.Infof("bucket %q has protocol %q", bkt.Name, bkt.Spec.Protocol)
+	logger.Info("resolved bucket", "bucket", bkt.Name, "protocol", protocolName(bkt))
+	if err := n.validateBucket(ctx, pod, bkt); err != nil {
+		return nil, err
+	}
+	if presign != nil || audience != "" {
+		// the service account token is the pod's own, it cannot be shared through a stage
+		return n.publishUnstaged(ctx, volID, request, pod, bkt, ba, presign, format, audience)
+	}
 
-	if err := n.provisioner.createDir(request.GetVolumeId()); err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
+	stageID, st, err := n.stage(ctx, volID, pod, bkt, ba, format)
+	if err != nil {
+		return nil, err
+	}
+	if err := n.checkBucket(ctx, pod, bkt, n.provisioner.stagedCredentials(stageID)); err != nil {
+		if rmErr := n.releaseStage(ctx, stageID, volID); rmErr != nil {
+			logger.Error(rmErr, "failed to remove staged credentials after failed check", "stageID", stageID)
+		}
+		return nil, err
 	}
 
 	cleanup := func(err error, errWrap string) (*csi.NodePublishVolumeResponse, error) {
-		rmErr := errors.Wrap(n.provisioner.removeDir(request.GetVolumeId()), util.WrapErrorFailedRemoveDirectory)
+		rmErr := errors.Wrap(n.provisioner.removeDir(volID), util.WrapErrorFailedRemoveDirectory)
+		if rmErr == nil {
+			rmErr = errors.Wrap(n.releaseStage(ctx, stageID, volID), util.WrapErrorFailedToRemoveStage)
+		}
 		if rmErr != nil {
 			return nil, status.Error(codes.Internal, errors.Wrap(rmErr, errWrap).Error())
 		}
 		return nil, status.Error(codes.Internal, errors.Wrap(err, errWrap).Error())
 	}
 
-	creds, err := util.ParseData(secret)
-	if err != nil {
-		return cleanup(err, util.WrapErrorFailedToParseSecret)
-	}
-
-	if err := n.provisioner.writeFileToVolumeMount(protocolConnection, request.GetVolumeId(), protocolFileName); err != nil {
-		return cleanup(err, util.WrapErrorFailedToWriteProtocol)
-	}
-
-	if err := n.provisioner.writeFileToVolumeMount(creds, request.GetVolumeId(), credsFileName); err != nil {
-		return cleanup(err, util.WrapErrorFailedToWriteCredentials)
+	if err := n.provisioner.createDir(volID); err != nil {
+		return cleanup(err, util.WrapErrorFailedToCreateVolumeDir)
 	}
 
-	util.EmitNormalEvent(n.cosiClient.Recorder(), pod, util.CredentialsWritten)
-
-	err = n.provisioner.mountDir(request.GetVolumeId(), request.GetTargetPath())
+	err = n.provisioner.mountDir(n.provisioner.stagedBucketPath(stageID), request.GetTargetPath())
 	if err != nil {
 		return cleanup(err, util.WrapErrorFailedToMountVolume)
 	}
 
+	now := n.clock.Now()
 	meta := Metadata{
-		BaName:       ba.Name,
-		PodName:      podName,
-		PodNamespace: podNs,
+		Version:               metadataVersion,
+		VolumeID:              volID,
+		BaName:                ba.Name,
+		PodName:               podName,
+		PodNamespace:          podNs,
+		PodUID:                podUID(pod),
+		ServiceAccount:        serviceAccountName(pod),
+		TargetPath:            request.GetTargetPath(),
+		StageID:               stageID,
+		BucketName:            bkt.Name,
+		Format:                st.Format,
+		SecretResourceVersion: st.SecretResourceVersion,
+		Files:                 st.Files,
+		ExpiresAt:             st.ExpiresAt,
+		CreatedAt:             &now,
+		UpdatedAt:             now,
 	}
 
-	err = n.cosiClient.AddBAFinalizer(ctx, ba, meta.finalizer())
+	_, err = n.cosiClient.UpdateBAFinalizers(ctx, ba.Name, n.finalizers(volID, meta), nil)
 	if err != nil {
 		return cleanup(err, util.WrapErrorFailedToAddFinalizer)
 	}
 
-	data, err := json.Marshal(meta)
-	if err != nil {
-		return cleanup(err, util.WrapErrorFailedToMarshalMetadata)
-	}
-
-	// Write the BA.name to a metadata file in our volume, this is not mounted to the app pod
-	if err := n.provisioner.writeFileToVolume(data, request.GetVolumeId(), metadataFilename); err != nil {
+	if meta, err = n.writeStagedMetadata(volID, meta); err != nil {
 		return cleanup(err, util.WrapErrorFailedToWriteMetadata)
 	}
+	if err := n.auditPublish(volID, meta); err != nil {
+		return cleanup(err, util.WrapErrorFailedToRecordAudit)
+	}
 
-	util.EmitNormalEvent(n.cosiClient.Recorder(), pod, util.SuccessfullyPublishedVolume)
+	util.EmitNormalEvent(ctx, n.cosiClient.Recorder(), pod, util.SuccessfullyPublishedVolume)
+	util.EmitNormalEvent(ctx, n.cosiClient.Recorder(), ba, util.SuccessfullyPublishedVolume)
 
 	return &csi.NodePublishVolumeResponse{}, nil
 }
 
-func (n *NodeServer) NodeUnpublishVolume(ctx context.Context, request *csi.NodeUnpublishVolumeRequest) (*csi.NodeUnpublishVolumeResponse, error) {
-	klog.Infof("NodeUnpublishVolume: volId: %v, targetPath: %v\n", request.GetVolumeId(), request.GetTargetPath())
-
-	data, err := n.provisioner.readFileFromVolume(request.GetVolumeId(), metadataFilename)
-	if err != nil {
-		return nil, status.Error(codes.Internal, errors.Wrap(err, util.WrapErrorFailedToReadMetadataFile).Error())
-	}
+func (n *NodeServer) NodeUnpublishVolume(ctx context.Context, request *csi.NodeUnpublishVolumeRequest) (_ *csi.NodeUnpublishVolumeResponse, err error) {
+	volID := volumeDirID(request.GetVolumeId(), request.GetTargetPath())
+	ctx = logging.NewContext(util.WithVolumeID(ctx, volID), "volumeID", volID)
+	logging.FromContext(ctx).Info("NodeUnpublishVolume", "targetPath", request.GetTargetPath())
+	defer func() {
+		if err != nil {
+			logging.FromContext(ctx).Error(err, "failed to unpublish volume")
+		}
+	}()
 
-	meta := Metadata{}
-	err = json.Unmarshal(data, &meta)
+	meta, err := n.provisioner.readMetadata(volID)
 	if err != nil {
-		return nil, status.Error(codes.Internal, errors.Wrap(err, util.WrapErrorFailedToUnmarshalMetadata).Error())
+		return nil, status.Error(codes.Internal, err.Error())
 	}
-
-	klog.InfoS("read metadata file", "metadata", meta)
+	ctx = volumeContext(ctx, volID, meta)
 
 	pod, err := n.cosiClient.GetPod(ctx, meta.PodName, meta.PodNamespace)
 	if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
-	ba, err := n.cosiClient.GetBA(ctx, pod, meta.BaName)
+	err = n.provisioner.removeMount(ctx, request.GetTargetPath())
 	if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
-	err = n.provisioner.removeMount(request.GetTargetPath())
+	unlock := n.volumeLocks.lock(volID)
+	err = n.provisioner.removeDir(volID)
+	unlock()
+	if err == nil {
+		err = errors.Wrap(n.releaseStage(ctx, meta.StageID, volID), util.WrapErrorFailedToRemoveStage)
+	} else {
+		err = errors.Wrap(err, util.WrapErrorFailedToRemoveDir)
+	}
 	if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
+	n.volumes.release(volID)
 
-	err = n.provisioner.removeDir(request.GetVolumeId())
-	if err != nil {
-		return nil, status.Error(codes.Internal, errors.Wrap(err, util.WrapErrorFailedToRemoveDir).Error())
+	if meta.BrName != "" {
+		// no bucketAccess is involved, there is no finalizer to remove
+		util.EmitNormalEvent(ctx, n.cosiClient.Recorder(), pod, util.SuccessfullyUnpublishedVolume)
+		return &csi.NodeUnpublishVolumeResponse{}, nil
 	}
 
-	err = n.cosiClient.RemoveBAFinalizer(ctx, ba, meta.finalizer())
-	if err != nil {
+	// the bucketAccess may be marked for deletion already, waiting on this very finalizer, or be
+	// gone altogether, leaving no finalizer to remove
+	ba, err := n.cosiClient.UpdateBAFinalizers(ctx, meta.BaName, nil, n.unpublishFinalizers(ctx, volID, meta))
+	if err != nil && !kerrors.IsNotFound(err) {
 		return nil, status.Error(codes.Internal, errors.Wrap(err, util.WrapErrorFailedToRemoveFinalizer).Error())
 	}
 
-	util.EmitNormalEvent(n.cosiClient.Recorder(), pod, util.SuccessfullyUnpublishedVolume)
+	n.forgetExpiry(volID, meta)
+	n.audit(ctx, audit.ActionUnpublish, volID, meta, "")
+
+	util.EmitNormalEvent(ctx, n.cosiClient.Recorder(), pod, util.SuccessfullyUnpublishedVolume)
+	if ba != nil {
+		util.EmitNormalEvent(ctx, n.cosiClient.Recorder(), ba, util.SuccessfullyUnpublishedVolume)
+	}
 
 	return &csi.NodeUnpublishVolumeResponse{}, nil
 }
 
+// requestContext attributes the events and log lines of a publish request to the volume, and its
+// log lines to the pod and the bucketAccessRequest or bucketRequest named in the volume context.
+func requestContext(ctx context.Context, volID string, volCtx map[string]string) context.Context {
+	podNs := volCtx[client.PodNamespaceKey]
+	keysAndValues := []interface{}{"volumeID", volID, "pod", klog.KRef(podNs, volCtx[client.PodNameKey])}
+	if brName, ok := volCtx[client.BrNameKey]; ok {
+		keysAndValues = append(keysAndValues, "br", klog.KRef(podNs, brName))
+	} else {
+		keysAndValues = append(keysAndValues, "bar", klog.KRef(client.ParseBARNamespace(volCtx, podNs), volCtx[client.BarNameKey]))
+	}
+	return logging.NewContext(util.WithVolumeID(ctx, volID), keysAndValues...)
+}
+
+// volumeContext attributes the events and log lines of work on a published volume to the volume,
+// and its log lines to the pod and the bucketAccess it was published for.
+func volumeContext(ctx context.Context, volID string, meta Metadata) context.Context {
+	keysAndValues := []interface{}{"volumeID", volID, "pod", klog.KRef(meta.PodNamespace, meta.PodName)}
+	if meta.BaName != "" {
+		keysAndValues = append(keysAndValues, "bucketAccess", meta.BaName)
+	}
+	return logging.NewContext(util.WithVolumeID(ctx, volID), keysAndValues...)
+}
+
+// protocolName names the protocol of the bucket, for logging without its details.
+func protocolName(bkt *v1alpha1.Bucket) string {
+	p, err := protocol.Lookup(bkt)
+	if err != nil {
+		return "unknown"
+	}
+	return p.Name()
+}
+
 func (n *NodeServer) NodeGetInfo(ctx context.Context, request *csi.NodeGetInfoRequest) (*csi.NodeGetInfoResponse, error) {
 	resp := &csi.NodeGetInfoResponse{
 		NodeId:            n.nodeID,
@@ -177,5 +386,20 @@ func (n *NodeServer) NodeGetInfo(ctx context.Context, request *csi.NodeGetInfoRe
 }
 
 func (n *NodeServer) NodeGetCapabilities(ctx context.Context, req *csi.NodeGetCapabilitiesRequest) (*csi.NodeGetCapabilitiesResponse, error) {
-	return &csi.NodeGetCapabilitiesResponse{}, nil
+	return &csi.NodeGetCapabilitiesResponse{
+		Capabilities: []*csi.NodeServiceCapability{
+			nodeCapability(csi.NodeServiceCapability_RPC_GET_VOLUME_STATS),
+			nodeCapability(csi.NodeServiceCapability_RPC_VOLUME_CONDITION),
+		},
+	}, nil
+}
+
+func nodeCapability(c csi.NodeServiceCapability_RPC_Type) *csi.NodeServiceCapability {
+	return &csi.NodeServiceCapability{
+		Type: &csi.NodeServiceCapability_Rpc{
+			Rpc: &csi.NodeServiceCapability_RPC{
+				Type: c,
+			},
+		},
+	}
 }
@@ -6,12 +6,16 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/pkg/errors"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
 	testutils "sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util/test"
 
@@ -72,6 +76,11 @@ func withMountPoints(mps []mount.MountPoint) ProvisionerModifier {
 	}
 }
 
+// noStage reads files as if nothing had been published or staged yet.
+func noStage(filename string) ([]byte, error) {
+	return nil, os.ErrNotExist
+}
+
 func TestNodePublishVolume(t *testing.T) {
 	type args struct {
 		nclient     *fake.FakeNodeClient
@@ -92,9 +101,16 @@ func TestNodePublishVolume(t *testing.T) {
 			args: args{
 				provisioner: getTestProvisioner(
 					&fake.MockProvisionerClient{
+						MockReadFile: noStage,
+						MockReadDir: func(dirname string) ([]os.FileInfo, error) {
+							return nil, nil
+						},
 						MockMkdirAll: func(path string, perm os.FileMode) error {
 							return nil
 						},
+						MockRename: func(oldpath, newpath string) error {
+							return nil
+						},
 						MockWriteFile: func(data []byte, filepath string) error {
 							return nil
 						},
@@ -104,17 +120,19 @@ func TestNodePublishVolume(t *testing.T) {
 					},
 				),
 				nclient: &fake.FakeNodeClient{
-					MockGetResources: func(ctx context.Context, barName, podName, podNs string) (bkt *v1alpha1.Bucket, ba *v1alpha1.BucketAccess, secret *v1.Secret, pod *v1.Pod, err error) {
+					MockGetResources: func(ctx context.Context, barName, barNs, podName, podNs string) (bkt *v1alpha1.Bucket, ba *v1alpha1.BucketAccess, pod *v1.Pod, err error) {
 						tempBar := testutils.GetBAR()
 						if tempBar.Namespace == podNs && tempBar.Name == barName {
 							ba = testutils.GetBA()
 							bkt = testutils.GetB()
-							secret = testutils.GetSecret()
 						}
-						return bkt, ba, secret, testutils.GetPod(), nil
+						return bkt, ba, testutils.GetPod(), nil
+					},
+					MockGetSecret: func(ctx context.Context, pod *v1.Pod, ba *v1alpha1.BucketAccess) (*v1.Secret, error) {
+						return testutils.GetSecret(), nil
 					},
-					MockAddBAFinalizer: func(ctx context.Context, ba *v1alpha1.BucketAccess, BAFinalizer string) error {
-						return nil
+					MockUpdateBAFinalizers: func(ctx context.Context, baName string, add map[string]client.FinalizerOwner, remove []string) (*v1alpha1.BucketAccess, error) {
+						return testutils.GetBA(), nil
 					},
 				},
 				request: &csi.NodePublishVolumeRequest{
@@ -135,7 +153,9 @@ func TestNodePublishVolume(t *testing.T) {
 		"ErrorFailedToParseVolume": {
 			args: args{
 				provisioner: getTestProvisioner(
-					&fake.MockProvisionerClient{},
+					&fake.MockProvisionerClient{
+						MockReadFile: noStage,
+					},
 				),
 				nclient: &fake.FakeNodeClient{},
 				request: &csi.NodePublishVolumeRequest{
@@ -155,17 +175,21 @@ func TestNodePublishVolume(t *testing.T) {
 		"ErrorInvalidBucketProtocol": {
 			args: args{
 				provisioner: getTestProvisioner(
-					&fake.MockProvisionerClient{},
+					&fake.MockProvisionerClient{
+						MockReadFile: noStage,
+					},
 				),
 				nclient: &fake.FakeNodeClient{
-					MockGetResources: func(ctx context.Context, barName, podName, podNs string) (bkt *v1alpha1.Bucket, ba *v1alpha1.BucketAccess, secret *v1.Secret, pod *v1.Pod, err error) {
+					MockGetResources: func(ctx context.Context, barName, barNs, podName, podNs string) (bkt *v1alpha1.Bucket, ba *v1alpha1.BucketAccess, pod *v1.Pod, err error) {
 						bkt = testutils.GetB(
 							testutils.WithProtocol(v1alpha1.Protocol{}),
 						)
 						ba = testutils.GetBA()
-						secret = testutils.GetSecret()
 						return
 					},
+					MockGetSecret: func(ctx context.Context, pod *v1.Pod, ba *v1alpha1.BucketAccess) (*v1.Secret, error) {
+						return testutils.GetSecret(), nil
+					},
 				},
 				request: &csi.NodePublishVolumeRequest{
 					VolumeContext: map[string]string{
@@ -187,18 +211,27 @@ func TestNodePublishVolume(t *testing.T) {
 			args: args{
 				provisioner: getTestProvisioner(
 					&fake.MockProvisionerClient{
+						MockReadFile: noStage,
+						MockReadDir: func(dirname string) ([]os.FileInfo, error) {
+							return nil, nil
+						},
 						MockMkdirAll: func(path string, perm os.FileMode) error {
 							return errBoom
 						},
+						MockRemoveAll: func(path string) error {
+							return nil
+						},
 					},
 				),
 				nclient: &fake.FakeNodeClient{
-					MockGetResources: func(ctx context.Context, barName, podName, podNs string) (bkt *v1alpha1.Bucket, ba *v1alpha1.BucketAccess, secret *v1.Secret, pod *v1.Pod, err error) {
+					MockGetResources: func(ctx context.Context, barName, barNs, podName, podNs string) (bkt *v1alpha1.Bucket, ba *v1alpha1.BucketAccess, pod *v1.Pod, err error) {
 						bkt = testutils.GetB()
 						ba = testutils.GetBA()
-						secret = testutils.GetSecret()
 						return
 					},
+					MockGetSecret: func(ctx context.Context, pod *v1.Pod, ba *v1alpha1.BucketAccess) (*v1.Secret, error) {
+						return testutils.GetSecret(), nil
+					},
 				},
 				request: &csi.NodePublishVolumeRequest{
 					VolumeContext: map[string]string{
@@ -219,9 +252,16 @@ func TestNodePublishVolume(t *testing.T) {
 			args: args{
 				provisioner: getTestProvisioner(
 					&fake.MockProvisionerClient{
+						MockReadFile: noStage,
+						MockReadDir: func(dirname string) ([]os.FileInfo, error) {
+							return nil, nil
+						},
 						MockMkdirAll: func(path string, perm os.FileMode) error {
 							return nil
 						},
+						MockRename: func(oldpath, newpath string) error {
+							return nil
+						},
 						MockWriteFile: func(data []byte, filepath string) error {
 							return errBoom
 						},
@@ -231,12 +271,14 @@ func TestNodePublishVolume(t *testing.T) {
 					},
 				),
 				nclient: &fake.FakeNodeClient{
-					MockGetResources: func(ctx context.Context, barName, podName, podNs string) (bkt *v1alpha1.Bucket, ba *v1alpha1.BucketAccess, secret *v1.Secret, pod *v1.Pod, err error) {
+					MockGetResources: func(ctx context.Context, barName, barNs, podName, podNs string) (bkt *v1alpha1.Bucket, ba *v1alpha1.BucketAccess, pod *v1.Pod, err error) {
 						bkt = testutils.GetB()
 						ba = testutils.GetBA()
-						secret = testutils.GetSecret()
 						return
 					},
+					MockGetSecret: func(ctx context.Context, pod *v1.Pod, ba *v1alpha1.BucketAccess) (*v1.Secret, error) {
+						return testutils.GetSecret(), nil
+					},
 				},
 				request: &csi.NodePublishVolumeRequest{
 					VolumeContext: map[string]string{
@@ -250,31 +292,45 @@ func TestNodePublishVolume(t *testing.T) {
 			},
 			want: want{
 				response: nil,
-				err:      genRPCError(codes.Internal, testutils.MultipleWrap(errBoom, util.WrapErrorFailedToCreateBucketFile, util.WrapErrorFailedToWriteProtocol)),
+				err:      genRPCError(codes.Internal, testutils.MultipleWrap(errBoom, util.WrapErrorFailedToCreateVolumeFile, util.WrapErrorFailedToWriteProtocol)),
 			},
 		},
-		"ErrorFailedToCreateFileRmFailed": {
+		"ErrorFailedToMountDirRmFailed": {
 			args: args{
 				provisioner: getTestProvisioner(
 					&fake.MockProvisionerClient{
+						MockReadFile: noStage,
+						MockReadDir: func(dirname string) ([]os.FileInfo, error) {
+							return nil, nil
+						},
 						MockMkdirAll: func(path string, perm os.FileMode) error {
 							return nil
 						},
+						MockRename: func(oldpath, newpath string) error {
+							return nil
+						},
 						MockWriteFile: func(data []byte, filepath string) error {
-							return errBoom
+							return nil
 						},
 						MockRemoveAll: func(path string) error {
-							return errBoom
+							if path == provVolumeId {
+								return errBoom
+							}
+							return nil
 						},
-					},
+					}, withErrorMap(map[string]error{
+						provTargetPath: errBoom,
+					}),
 				),
 				nclient: &fake.FakeNodeClient{
-					MockGetResources: func(ctx context.Context, barName, podName, podNs string) (bkt *v1alpha1.Bucket, ba *v1alpha1.BucketAccess, secret *v1.Secret, pod *v1.Pod, err error) {
+					MockGetResources: func(ctx context.Context, barName, barNs, podName, podNs string) (bkt *v1alpha1.Bucket, ba *v1alpha1.BucketAccess, pod *v1.Pod, err error) {
 						bkt = testutils.GetB()
 						ba = testutils.GetBA()
-						secret = testutils.GetSecret()
 						return
 					},
+					MockGetSecret: func(ctx context.Context, pod *v1.Pod, ba *v1alpha1.BucketAccess) (*v1.Secret, error) {
+						return testutils.GetSecret(), nil
+					},
 				},
 				request: &csi.NodePublishVolumeRequest{
 					VolumeContext: map[string]string{
@@ -288,19 +344,26 @@ func TestNodePublishVolume(t *testing.T) {
 			},
 			want: want{
 				response: nil,
-				err:      genRPCError(codes.Internal, testutils.MultipleWrap(errBoom, util.WrapErrorFailedRemoveDirectory, util.WrapErrorFailedToWriteProtocol)),
+				err:      genRPCError(codes.Internal, testutils.MultipleWrap(errBoom, util.WrapErrorFailedRemoveDirectory, util.WrapErrorFailedToMountVolume)),
 			},
 		},
 		"ErrorFailedToMountDirMkdir": {
 			args: args{
 				provisioner: getTestProvisioner(
 					&fake.MockProvisionerClient{
+						MockReadFile: noStage,
+						MockReadDir: func(dirname string) ([]os.FileInfo, error) {
+							return nil, nil
+						},
 						MockMkdirAll: func(path string, perm os.FileMode) error {
 							if path == provTargetPath {
 								return errBoom
 							}
 							return nil
 						},
+						MockRename: func(oldpath, newpath string) error {
+							return nil
+						},
 						MockWriteFile: func(data []byte, filepath string) error {
 							return nil
 						},
@@ -310,12 +373,14 @@ func TestNodePublishVolume(t *testing.T) {
 					},
 				),
 				nclient: &fake.FakeNodeClient{
-					MockGetResources: func(ctx context.Context, barName, podName, podNs string) (bkt *v1alpha1.Bucket, ba *v1alpha1.BucketAccess, secret *v1.Secret, pod *v1.Pod, err error) {
+					MockGetResources: func(ctx context.Context, barName, barNs, podName, podNs string) (bkt *v1alpha1.Bucket, ba *v1alpha1.BucketAccess, pod *v1.Pod, err error) {
 						bkt = testutils.GetB()
 						ba = testutils.GetBA()
-						secret = testutils.GetSecret()
 						return
 					},
+					MockGetSecret: func(ctx context.Context, pod *v1.Pod, ba *v1alpha1.BucketAccess) (*v1.Secret, error) {
+						return testutils.GetSecret(), nil
+					},
 				},
 				request: &csi.NodePublishVolumeRequest{
 					VolumeContext: map[string]string{
@@ -336,9 +401,16 @@ func TestNodePublishVolume(t *testing.T) {
 			args: args{
 				provisioner: getTestProvisioner(
 					&fake.MockProvisionerClient{
+						MockReadFile: noStage,
+						MockReadDir: func(dirname string) ([]os.FileInfo, error) {
+							return nil, nil
+						},
 						MockMkdirAll: func(path string, perm os.FileMode) error {
 							return nil
 						},
+						MockRename: func(oldpath, newpath string) error {
+							return nil
+						},
 						MockWriteFile: func(data []byte, filepath string) error {
 							return nil
 						},
@@ -350,12 +422,14 @@ func TestNodePublishVolume(t *testing.T) {
 					}),
 				),
 				nclient: &fake.FakeNodeClient{
-					MockGetResources: func(ctx context.Context, barName, podName, podNs string) (bkt *v1alpha1.Bucket, ba *v1alpha1.BucketAccess, secret *v1.Secret, pod *v1.Pod, err error) {
+					MockGetResources: func(ctx context.Context, barName, barNs, podName, podNs string) (bkt *v1alpha1.Bucket, ba *v1alpha1.BucketAccess, pod *v1.Pod, err error) {
 						bkt = testutils.GetB()
 						ba = testutils.GetBA()
-						secret = testutils.GetSecret()
 						return
 					},
+					MockGetSecret: func(ctx context.Context, pod *v1.Pod, ba *v1alpha1.BucketAccess) (*v1.Secret, error) {
+						return testutils.GetSecret(), nil
+					},
 				},
 				request: &csi.NodePublishVolumeRequest{
 					VolumeContext: map[string]string{
@@ -376,9 +450,16 @@ func TestNodePublishVolume(t *testing.T) {
 			args: args{
 				provisioner: getTestProvisioner(
 					&fake.MockProvisionerClient{
+						MockReadFile: noStage,
+						MockReadDir: func(dirname string) ([]os.FileInfo, error) {
+							return nil, nil
+						},
 						MockMkdirAll: func(path string, perm os.FileMode) error {
 							return nil
 						},
+						MockRename: func(oldpath, newpath string) error {
+							return nil
+						},
 						MockWriteFile: func(data []byte, filepath string) error {
 							return nil
 						},
@@ -388,14 +469,16 @@ func TestNodePublishVolume(t *testing.T) {
 					},
 				),
 				nclient: &fake.FakeNodeClient{
-					MockGetResources: func(ctx context.Context, barName, podName, podNs string) (bkt *v1alpha1.Bucket, ba *v1alpha1.BucketAccess, secret *v1.Secret, pod *v1.Pod, err error) {
+					MockGetResources: func(ctx context.Context, barName, barNs, podName, podNs string) (bkt *v1alpha1.Bucket, ba *v1alpha1.BucketAccess, pod *v1.Pod, err error) {
 						bkt = testutils.GetB()
 						ba = testutils.GetBA()
-						secret = testutils.GetSecret()
 						return
 					},
-					MockAddBAFinalizer: func(ctx context.Context, ba *v1alpha1.BucketAccess, BAFinalizer string) error {
-						return errBoom
+					MockGetSecret: func(ctx context.Context, pod *v1.Pod, ba *v1alpha1.BucketAccess) (*v1.Secret, error) {
+						return testutils.GetSecret(), nil
+					},
+					MockUpdateBAFinalizers: func(ctx context.Context, baName string, add map[string]client.FinalizerOwner, remove []string) (*v1alpha1.BucketAccess, error) {
+						return nil, errBoom
 					},
 				},
 				request: &csi.NodePublishVolumeRequest{
@@ -417,11 +500,18 @@ func TestNodePublishVolume(t *testing.T) {
 			args: args{
 				provisioner: getTestProvisioner(
 					&fake.MockProvisionerClient{
+						MockReadFile: noStage,
+						MockReadDir: func(dirname string) ([]os.FileInfo, error) {
+							return nil, nil
+						},
 						MockMkdirAll: func(path string, perm os.FileMode) error {
 							return nil
 						},
+						MockRename: func(oldpath, newpath string) error {
+							return nil
+						},
 						MockWriteFile: func(data []byte, fp string) error {
-							if filepath.Base(fp) == metadataFilename {
+							if filepath.Base(fp) == tempFileName(metadataFilename) {
 								return errBoom
 							}
 							return nil
@@ -432,14 +522,16 @@ func TestNodePublishVolume(t *testing.T) {
 					},
 				),
 				nclient: &fake.FakeNodeClient{
-					MockGetResources: func(ctx context.Context, barName, podName, podNs string) (bkt *v1alpha1.Bucket, ba *v1alpha1.BucketAccess, secret *v1.Secret, pod *v1.Pod, err error) {
+					MockGetResources: func(ctx context.Context, barName, barNs, podName, podNs string) (bkt *v1alpha1.Bucket, ba *v1alpha1.BucketAccess, pod *v1.Pod, err error) {
 						bkt = testutils.GetB()
 						ba = testutils.GetBA()
-						secret = testutils.GetSecret()
 						return
 					},
-					MockAddBAFinalizer: func(ctx context.Context, ba *v1alpha1.BucketAccess, BAFinalizer string) error {
-						return nil
+					MockGetSecret: func(ctx context.Context, pod *v1.Pod, ba *v1alpha1.BucketAccess) (*v1.Secret, error) {
+						return testutils.GetSecret(), nil
+					},
+					MockUpdateBAFinalizers: func(ctx context.Context, baName string, add map[string]client.FinalizerOwner, remove []string) (*v1alpha1.BucketAccess, error) {
+						return testutils.GetBA(), nil
 					},
 				},
 				request: &csi.NodePublishVolumeRequest{
@@ -457,6 +549,124 @@ func TestNodePublishVolume(t *testing.T) {
 				err:      genRPCError(codes.Internal, testutils.MultipleWrap(errBoom, util.WrapErrorFailedToCreateVolumeFile, util.WrapErrorFailedToWriteMetadata)),
 			},
 		},
+		"ErrorInvalidBucketEndpoint": {
+			args: args{
+				provisioner: getTestProvisioner(&fake.MockProvisionerClient{MockReadFile: noStage}),
+				nclient: &fake.FakeNodeClient{
+					MockGetResources: func(ctx context.Context, barName, barNs, podName, podNs string) (bkt *v1alpha1.Bucket, ba *v1alpha1.BucketAccess, pod *v1.Pod, err error) {
+						bkt = testutils.GetB(testutils.WithProtocol(v1alpha1.Protocol{
+							S3: &v1alpha1.S3Protocol{Endpoint: "ftp://endpoint", BucketName: "bucketName"},
+						}))
+						return bkt, testutils.GetBA(), testutils.GetPod(), nil
+					},
+				},
+				request: &csi.NodePublishVolumeRequest{
+					VolumeContext: map[string]string{
+						client.BarNameKey:      testutils.GetBAR().Name,
+						client.PodNameKey:      podName,
+						client.PodNamespaceKey: testutils.Namespace,
+					},
+					VolumeId:   provVolumeId,
+					TargetPath: provTargetPath,
+				},
+			},
+			want: want{
+				response: nil,
+				err:      status.Error(codes.FailedPrecondition, `s3 protocol: invalid endpoint "ftp://endpoint": scheme must be http or https`),
+			},
+		},
+		"BucketInfo": {
+			args: args{
+				provisioner: getTestProvisioner(
+					&fake.MockProvisionerClient{
+						MockMkdirAll: func(path string, perm os.FileMode) error {
+							return nil
+						},
+						MockWriteFile: func(data []byte, filepath string) error {
+							// only the protocol file is written, besides the metadata
+							switch name := filepath[strings.LastIndex(filepath, "/")+1:]; name {
+							case protocolFileName, tempFileName(metadataFilename):
+								return nil
+							default:
+								return errors.Errorf("unexpected file %s", name)
+							}
+						},
+						MockReadFile: noStage,
+						MockRename: func(oldpath, newpath string) error {
+							return nil
+						},
+						MockRemoveAll: func(path string) error {
+							return nil
+						},
+					},
+				),
+				nclient: &fake.FakeNodeClient{
+					MockGetBucketInfo: func(ctx context.Context, brName, podName, podNs string) (*v1alpha1.Bucket, *v1.Pod, error) {
+						if brName != testutils.GetBR().Name {
+							return nil, nil, errBoom
+						}
+						return testutils.GetB(), testutils.GetPod(), nil
+					},
+					MockRecorder: &record.FakeRecorder{},
+				},
+				request: &csi.NodePublishVolumeRequest{
+					VolumeContext: map[string]string{
+						client.BrNameKey:       testutils.GetBR().Name,
+						client.PodNameKey:      podName,
+						client.PodNamespaceKey: testutils.Namespace,
+					},
+					VolumeId:   provVolumeId,
+					TargetPath: provTargetPath,
+				},
+			},
+			want: want{
+				response: &csi.NodePublishVolumeResponse{},
+				err:      nil,
+			},
+		},
+		"ErrorBARAndBRSet": {
+			args: args{
+				provisioner: getTestProvisioner(&fake.MockProvisionerClient{MockReadFile: noStage}),
+				nclient:     &fake.FakeNodeClient{},
+				request: &csi.NodePublishVolumeRequest{
+					VolumeContext: map[string]string{
+						client.BarNameKey:      testutils.GetBAR().Name,
+						client.BrNameKey:       testutils.GetBR().Name,
+						client.PodNameKey:      podName,
+						client.PodNamespaceKey: testutils.Namespace,
+					},
+					VolumeId:   provVolumeId,
+					TargetPath: provTargetPath,
+				},
+			},
+			want: want{
+				response: nil,
+				err:      genRPCError(codes.InvalidArgument, util.ErrorBARAndBRSet),
+			},
+		},
+		"ErrorBucketInfoUnavailable": {
+			args: args{
+				provisioner: getTestProvisioner(&fake.MockProvisionerClient{MockReadFile: noStage}),
+				nclient: &fake.FakeNodeClient{
+					MockGetBucketInfo: func(ctx context.Context, brName, podName, podNs string) (*v1alpha1.Bucket, *v1.Pod, error) {
+						return nil, nil, util.ErrorBRNotAvailable
+					},
+				},
+				request: &csi.NodePublishVolumeRequest{
+					VolumeContext: map[string]string{
+						client.BrNameKey:       testutils.GetBR().Name,
+						client.PodNameKey:      podName,
+						client.PodNamespaceKey: testutils.Namespace,
+					},
+					VolumeId:   provVolumeId,
+					TargetPath: provTargetPath,
+				},
+			},
+			want: want{
+				response: nil,
+				err:      genRPCError(codes.FailedPrecondition, util.ErrorBRNotAvailable),
+			},
+		},
 	}
 
 	for name, tc := range cases {
@@ -467,6 +677,7 @@ func TestNodePublishVolume(t *testing.T) {
 				cosiClient:  tc.nclient,
 				provisioner: tc.provisioner,
 				volumeLimit: volLimit,
+				clock:       clock.NewFakeClock(testNow),
 			}
 
 			response, err := ns.NodePublishVolume(ctx, tc.request)
@@ -494,6 +705,8 @@ func TestNodeUnpublishVolume(t *testing.T) {
 		err      error
 	}
 
+	legacy := fmt.Sprintf("%s-%s-%s", Finalizer, testutils.Namespace, podName)
+
 	cases := map[string]struct {
 		args
 		want
@@ -505,6 +718,10 @@ func TestNodeUnpublishVolume(t *testing.T) {
 						MockRemoveAll: func(path string) error {
 							return nil
 						},
+						// no other volume is left
+						MockReadDir: func(dirname string) ([]os.FileInfo, error) {
+							return nil, nil
+						},
 						MockReadFile: func(filename string) ([]byte, error) {
 							meta := Metadata{
 								BaName:       "bucketAccessName",
@@ -520,16 +737,13 @@ func TestNodeUnpublishVolume(t *testing.T) {
 					}),
 				),
 				nclient: &fake.FakeNodeClient{
-					MockGetBA: func(ctx context.Context, pod *v1.Pod, baName string) (*v1alpha1.BucketAccess, error) {
+					MockUpdateBAFinalizers: func(ctx context.Context, baName string, add map[string]client.FinalizerOwner, remove []string) (*v1alpha1.BucketAccess, error) {
 						tempBa := testutils.GetBA()
-						if tempBa.Name == baName {
+						if tempBa.Name == baName && len(add) == 0 && cmp.Equal(remove, []string{volumeFinalizer(provVolumeId), legacy}) {
 							return tempBa, nil
 						}
 						return nil, errBoom
 					},
-					MockRemoveBAFinalizer: func(ctx context.Context, ba *v1alpha1.BucketAccess, BAFinalizer string) error {
-						return nil
-					},
 					MockGetPod: func(ctx context.Context, podName, podNs string) (*v1.Pod, error) {
 						return testutils.GetPod(), nil
 					},
@@ -585,48 +799,50 @@ func TestNodeUnpublishVolume(t *testing.T) {
 				err:      genRPCError(codes.Internal, errors.Wrap(errors.New("unexpected end of JSON input"), util.WrapErrorFailedToUnmarshalMetadata)),
 			},
 		},
-		"FailedToGetBA": {
+		"FailedToRemoveMount": {
 			args: args{
 				provisioner: getTestProvisioner(
 					&fake.MockProvisionerClient{
+						MockRemoveAll: func(path string) error {
+							return nil
+						},
+						// no other volume is left
+						MockReadDir: func(dirname string) ([]os.FileInfo, error) {
+							return nil, nil
+						},
 						MockReadFile: func(filename string) ([]byte, error) {
 							meta := Metadata{
-								BaName:       "wrongName",
+								BaName:       "bucketAccessName",
 								PodName:      podName,
 								PodNamespace: testutils.Namespace,
 							}
 							return json.Marshal(meta)
 						},
-					},
+					}, withErrorMap(map[string]error{
+						"/var/lib": errBoom,
+					}),
 				),
 				nclient: &fake.FakeNodeClient{
-					MockGetBA: func(ctx context.Context, pod *v1.Pod, baName string) (*v1alpha1.BucketAccess, error) {
-						tempBa := testutils.GetBA()
-						if tempBa.Name == baName {
-							return tempBa, nil
-						}
-						return nil, errBoom
-					},
 					MockGetPod: func(ctx context.Context, podName, podNs string) (*v1.Pod, error) {
 						return testutils.GetPod(), nil
 					},
 				},
 				request: &csi.NodeUnpublishVolumeRequest{
 					VolumeId:   provVolumeId,
-					TargetPath: provTargetPath,
+					TargetPath: "/var/lib",
 				},
 			},
 			want: want{
 				response: nil,
-				err:      genRPCError(codes.Internal, errBoom),
+				err:      genRPCError(codes.Internal, errors.Wrap(errBoom, util.WrapErrorFailedToUnmountVolume)),
 			},
 		},
-		"FailedToRemoveMount": {
+		"FailedToRemoveDir": {
 			args: args{
 				provisioner: getTestProvisioner(
 					&fake.MockProvisionerClient{
 						MockRemoveAll: func(path string) error {
-							return nil
+							return errBoom
 						},
 						MockReadFile: func(filename string) ([]byte, error) {
 							meta := Metadata{
@@ -636,34 +852,33 @@ func TestNodeUnpublishVolume(t *testing.T) {
 							}
 							return json.Marshal(meta)
 						},
-					}, withErrorMap(map[string]error{
-						"/var/lib": errBoom,
-					}),
+					},
 				),
 				nclient: &fake.FakeNodeClient{
-					MockGetBA: func(ctx context.Context, pod *v1.Pod, baName string) (*v1alpha1.BucketAccess, error) {
-						return testutils.GetBA(), nil
-					},
 					MockGetPod: func(ctx context.Context, podName, podNs string) (*v1.Pod, error) {
 						return testutils.GetPod(), nil
 					},
 				},
 				request: &csi.NodeUnpublishVolumeRequest{
 					VolumeId:   provVolumeId,
-					TargetPath: "/var/lib",
+					TargetPath: provTargetPath,
 				},
 			},
 			want: want{
 				response: nil,
-				err:      genRPCError(codes.Internal, errors.Wrap(errBoom, util.WrapErrorFailedToUnmountVolume)),
+				err:      genRPCError(codes.Internal, errors.Wrap(errBoom, util.WrapErrorFailedToRemoveDir)),
 			},
 		},
-		"FailedToRemoveDir": {
+		"FailedToRemoveFinalizer": {
 			args: args{
 				provisioner: getTestProvisioner(
 					&fake.MockProvisionerClient{
 						MockRemoveAll: func(path string) error {
-							return errBoom
+							return nil
+						},
+						// no other volume is left
+						MockReadDir: func(dirname string) ([]os.FileInfo, error) {
+							return nil, nil
 						},
 						MockReadFile: func(filename string) ([]byte, error) {
 							meta := Metadata{
@@ -676,8 +891,8 @@ func TestNodeUnpublishVolume(t *testing.T) {
 					},
 				),
 				nclient: &fake.FakeNodeClient{
-					MockGetBA: func(ctx context.Context, pod *v1.Pod, baName string) (*v1alpha1.BucketAccess, error) {
-						return testutils.GetBA(), nil
+					MockUpdateBAFinalizers: func(ctx context.Context, baName string, add map[string]client.FinalizerOwner, remove []string) (*v1alpha1.BucketAccess, error) {
+						return nil, errBoom
 					},
 					MockGetPod: func(ctx context.Context, podName, podNs string) (*v1.Pod, error) {
 						return testutils.GetPod(), nil
@@ -690,16 +905,20 @@ func TestNodeUnpublishVolume(t *testing.T) {
 			},
 			want: want{
 				response: nil,
-				err:      genRPCError(codes.Internal, errors.Wrap(errBoom, util.WrapErrorFailedToRemoveDir)),
+				err:      genRPCError(codes.Internal, errors.Wrap(errBoom, util.WrapErrorFailedToRemoveFinalizer)),
 			},
 		},
-		"FailedToRemoveFinalizer": {
+		"BAGone": {
 			args: args{
 				provisioner: getTestProvisioner(
 					&fake.MockProvisionerClient{
 						MockRemoveAll: func(path string) error {
 							return nil
 						},
+						// no other volume is left
+						MockReadDir: func(dirname string) ([]os.FileInfo, error) {
+							return nil, nil
+						},
 						MockReadFile: func(filename string) ([]byte, error) {
 							meta := Metadata{
 								BaName:       "bucketAccessName",
@@ -711,15 +930,49 @@ func TestNodeUnpublishVolume(t *testing.T) {
 					},
 				),
 				nclient: &fake.FakeNodeClient{
-					MockGetBA: func(ctx context.Context, pod *v1.Pod, baName string) (*v1alpha1.BucketAccess, error) {
-						return testutils.GetBA(), nil
+					MockUpdateBAFinalizers: func(ctx context.Context, baName string, add map[string]client.FinalizerOwner, remove []string) (*v1alpha1.BucketAccess, error) {
+						return nil, kerrors.NewNotFound(v1alpha1.Resource("bucketaccesses"), baName)
 					},
-					MockRemoveBAFinalizer: func(ctx context.Context, ba *v1alpha1.BucketAccess, BAFinalizer string) error {
-						return errBoom
+					MockGetPod: func(ctx context.Context, podName, podNs string) (*v1.Pod, error) {
+						return testutils.GetPod(), nil
 					},
+				},
+				request: &csi.NodeUnpublishVolumeRequest{
+					VolumeId:   provVolumeId,
+					TargetPath: provTargetPath,
+				},
+			},
+			want: want{
+				response: &csi.NodeUnpublishVolumeResponse{},
+			},
+		},
+		"BucketInfo": {
+			args: args{
+				provisioner: getTestProvisioner(
+					&fake.MockProvisionerClient{
+						MockRemoveAll: func(path string) error {
+							return nil
+						},
+						MockReadFile: func(filename string) ([]byte, error) {
+							meta := Metadata{
+								BrName:       testutils.GetBR().Name,
+								PodName:      podName,
+								PodNamespace: testutils.Namespace,
+							}
+							return json.Marshal(meta)
+						},
+					}, withMountPoints([]mount.MountPoint{
+						{
+							Path: provTargetPath,
+						},
+					}),
+				),
+				// no bucketAccess is involved, updating finalizers would panic
+				nclient: &fake.FakeNodeClient{
 					MockGetPod: func(ctx context.Context, podName, podNs string) (*v1.Pod, error) {
 						return testutils.GetPod(), nil
 					},
+					MockRecorder: &record.FakeRecorder{},
 				},
 				request: &csi.NodeUnpublishVolumeRequest{
 					VolumeId:   provVolumeId,
@@ -727,8 +980,8 @@ func TestNodeUnpublishVolume(t *testing.T) {
 				},
 			},
 			want: want{
-				response: nil,
-				err:      genRPCError(codes.Internal, errors.Wrap(errBoom, util.WrapErrorFailedToRemoveFinalizer)),
+				response: &csi.NodeUnpublishVolumeResponse{},
+				err:      nil,
 			},
 		},
 	}
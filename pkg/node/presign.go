@@ -0,0 +1,264 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/audit"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/logging"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/protocol"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+const (
+	presignedURLsFileName = "presigned-urls.json"
+
+	presignCheckInterval = time.Minute
+
+	defaultPresignTTL = time.Hour
+	// minPresignTTL leaves the refresh loop several runs to regenerate the URLs in time.
+	minPresignTTL = 5 * time.Minute
+	// maxPresignTTL is the longest validity of a URL presigned with Signature Version 4.
+	maxPresignTTL = 7 * 24 * time.Hour
+)
+
+// Presign records the objects a volume publishes presigned URLs for, instead of credentials.
+type Presign struct {
+	Objects []string        `json:"objects"`
+	Methods []string        `json:"methods"`
+	TTL     metav1.Duration `json:"ttl"`
+	// SignedAt is when the published URLs were signed.
+	SignedAt time.Time `json:"signedAt"`
+	// ExpiresAt is the expiry of the published URLs.
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// SetExpiry makes URLs signed at now expire after the TTL, or when the credentials signing them
+// expire, if earlier, since the URLs stop working with the credentials.
+func (p *Presign) SetExpiry(now time.Time, credentialsExpireAt *time.Time) {
+	p.SignedAt = now
+	p.ExpiresAt = now.Add(p.TTL.Duration)
+	if credentialsExpireAt != nil && credentialsExpireAt.Before(p.ExpiresAt) {
+		p.ExpiresAt = *credentialsExpireAt
+	}
+}
+
+// refreshAt returns when the URLs are regenerated, once less than a third of their lifetime is left.
+// The lifetime is shorter than the TTL when the credentials expire first.
+func (p Presign) refreshAt() time.Time {
+	lifetime := p.ExpiresAt.Sub(p.SignedAt)
+	if p.SignedAt.IsZero() {
+		// recorded before the signing time was
+		lifetime = p.TTL.Duration
+	}
+	return p.ExpiresAt.Add(-lifetime / 3)
+}
+
+// presignedURLs is the document published as presignedURLsFileName, the URLs keyed by object key
+// and method.
+type presignedURLs struct {
+	ExpiresAt time.Time                    `json:"expiresAt"`
+	Objects   map[string]map[string]string `json:"objects"`
+}
+
+// ParsePresign returns the presigned URLs requested in the volume context, nil when the volume
+// publishes credentials.
+func ParsePresign(volCtx map[string]string) (*Presign, error) {
+	objects, ok := volCtx[client.PresignObjectsKey]
+	if !ok {
+		return nil, nil
+	}
+	if _, ok := volCtx[client.FormatKey]; ok {
+		return nil, util.ErrorPresignWithFormat
+	}
+
+	presign := &Presign{TTL: metav1.Duration{Duration: defaultPresignTTL}}
+	for _, key := range strings.Split(objects, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			presign.Objects = append(presign.Objects, key)
+		}
+	}
+	if len(presign.Objects) == 0 {
+		return nil, util.ErrorPresignObjectsUnset
+	}
+
+	methods := http.MethodGet
+	if m, ok := volCtx[client.PresignMethodsKey]; ok {
+		methods = m
+	}
+	for _, method := range strings.Split(methods, ",") {
+		method = strings.ToUpper(strings.TrimSpace(method))
+		if method != http.MethodGet && method != http.MethodPut {
+			return nil, fmt.Errorf(util.ErrorTemplatePresignMethodInvalid, method)
+		}
+		presign.Methods = append(presign.Methods, method)
+	}
+
+	if ttl, ok := volCtx[client.PresignTTLKey]; ok {
+		d, err := time.ParseDuration(ttl)
+		if err != nil || d < minPresignTTL || d > maxPresignTTL {
+			return nil, fmt.Errorf(util.ErrorTemplatePresignTTLInvalid, ttl, minPresignTTL, maxPresignTTL)
+		}
+		presign.TTL.Duration = d
+	}
+	return presign, nil
+}
+
+// RenderPresignedFiles returns the files of a volume publishing presigned URLs, keyed by file name.
+// The URLs are signed at now with the minted credentials and expire at presign.ExpiresAt, the
+// credentials themselves are not published.
+func RenderPresignedFiles(bkt *v1alpha1.Bucket, secret *v1.Secret, expiresAt *time.Time, presign Presign, now time.Time) (map[string][]byte, error) {
+	p, err := protocol.Lookup(bkt)
+	if err != nil {
+		return nil, err
+	}
+	presigner, ok := p.(protocol.Presigner)
+	if !ok {
+		return nil, fmt.Errorf(util.ErrorTemplatePresignUnsupported, p.Name())
+	}
+	protocolConnection, err := p.Connection(bkt)
+	if err != nil {
+		return nil, err
+	}
+	if expiresAt != nil {
+		if protocolConnection, err = withExpiry(protocolConnection, *expiresAt); err != nil {
+			return nil, err
+		}
+	}
+
+	creds := map[string]string{}
+	for key, value := range secret.Data {
+		creds[key] = string(value)
+	}
+	doc := presignedURLs{ExpiresAt: presign.ExpiresAt.UTC(), Objects: map[string]map[string]string{}}
+	for _, key := range presign.Objects {
+		doc.Objects[key] = map[string]string{}
+		for _, method := range presign.Methods {
+			u, err := presigner.Presign(bkt, creds, method, key, presign.ExpiresAt.Sub(now), now)
+			if err != nil {
+				return nil, errors.Wrap(err, util.WrapErrorFailedToPresign)
+			}
+			doc.Objects[key][method] = u
+		}
+	}
+	urls, err := json.Marshal(doc)
+	if err != nil {
+		return nil, errors.Wrap(err, util.WrapErrorFailedToPresign)
+	}
+
+	return map[string][]byte{
+		protocolFileName:      protocolConnection,
+		presignedURLsFileName: urls,
+	}, nil
+}
+
+// refreshPresigned regenerates the presigned URLs of published volumes before they expire.
+func (n *NodeServer) refreshPresigned(ctx context.Context) {
+	volIDs, err := n.provisioner.listVolumes()
+	if err != nil {
+		logging.FromContext(ctx).Error(err, "failed to list volumes for presigned URL refresh")
+		return
+	}
+
+	for _, volID := range volIDs {
+		meta, err := n.provisioner.readMetadata(volID)
+		if err != nil || meta.Presign == nil || n.clock.Now().Before(meta.Presign.refreshAt()) {
+			continue
+		}
+		ctx := volumeContext(ctx, volID, meta)
+		if err := n.refreshPresignedVolume(ctx, volID, meta); err != nil {
+			logging.FromContext(ctx).Error(err, "failed to refresh presigned URLs")
+		}
+	}
+}
+
+// refreshPresignedVolume signs the URLs of the volume anew with the current minted credentials.
+// Volumes whose bucketAccess no longer grants access are left to the revocation handler, their URLs
+// stop working once they expire.
+func (n *NodeServer) refreshPresignedVolume(ctx context.Context, volID string, meta Metadata) error {
+	ba, err := n.cosiClient.GetCachedBA(meta.BaName)
+	if err != nil {
+		return err
+	}
+	if err := client.ValidateBA(ba); err != nil {
+		logging.FromContext(ctx).Info("not refreshing presigned URLs of revoked bucketAccess", "reason", err.Error())
+		return nil
+	}
+
+	pod, err := n.cosiClient.GetPod(ctx, meta.PodName, meta.PodNamespace)
+	if err != nil {
+		return err
+	}
+	refreshFailed := func(err error) error {
+		util.EmitWarningEvent(ctx, n.cosiClient.Recorder(), pod, util.PresignedURLsRefreshFailed)
+		return err
+	}
+
+	bkt, err := n.cosiClient.GetB(ctx, pod, ba.Spec.BucketName)
+	if err != nil {
+		return refreshFailed(err)
+	}
+	secret, err := n.cosiClient.GetSecret(ctx, pod, ba)
+	if err != nil {
+		return refreshFailed(err)
+	}
+	expiresAt, err := CredentialsExpiry(secret, n.expiryAnnotation)
+	if err != nil {
+		return refreshFailed(err)
+	}
+
+	now := n.clock.Now()
+	if expiresAt != nil && !expiresAt.After(now) {
+		// signing with expired credentials is bound to fail until the secret is rotated
+		if n.presignExpired.transition(volID, expiryStateExpired) {
+			logging.FromContext(ctx).Info("not refreshing presigned URLs, the credentials have expired", "expiresAt", expiresAt)
+			util.EmitWarningEvent(ctx, n.cosiClient.Recorder(), pod, util.PresignedURLsExpired)
+		}
+		return nil
+	}
+	n.presignExpired.forget(volID)
+
+	presign := *meta.Presign
+	presign.SetExpiry(now, expiresAt)
+	files, err := RenderPresignedFiles(bkt, secret, expiresAt, presign, now)
+	if err != nil {
+		return refreshFailed(err)
+	}
+
+	// held against the volume being unpublished while its files are replaced
+	unlock := n.volumeLocks.lock(volID)
+	defer unlock()
+	if meta, err = n.provisioner.readMetadata(volID); err != nil {
+		return err
+	}
+
+	// the service account token of the volume, if any, is left as it is
+	checksums := map[string]string{}
+	for name, checksum := range meta.Files {
+		checksums[name] = checksum
+	}
+	for name, data := range files {
+		if err := n.provisioner.writeFileAtomic(n.provisioner.bucketPath(volID), name, data); err != nil {
+			return refreshFailed(err)
+		}
+		checksums[name] = util.Checksum(data)
+	}
+	meta.SecretResourceVersion, meta.Files, meta.ExpiresAt, meta.Presign, meta.UpdatedAt = secret.ResourceVersion, checksums, expiresAt, &presign, now
+	if err := n.provisioner.writeMetadata(volID, meta); err != nil {
+		return refreshFailed(errors.Wrap(err, util.WrapErrorFailedToWriteMetadata))
+	}
+	n.audit(ctx, audit.ActionRotate, volID, meta, "")
+	logging.FromContext(ctx).Info("refreshed presigned URLs", "expiresAt", presign.ExpiresAt)
+	return nil
+}
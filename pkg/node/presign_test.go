@@ -0,0 +1,391 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/mount-utils"
+
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client/fake"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+	testutils "sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util/test"
+)
+
+func TestParsePresign(t *testing.T) {
+	type want struct {
+		presign *Presign
+		err     error
+	}
+
+	cases := map[string]struct {
+		volCtx map[string]string
+		want   want
+	}{
+		"Credentials": {
+			volCtx: map[string]string{client.BarNameKey: "bar"},
+		},
+		"Defaults": {
+			volCtx: map[string]string{client.PresignObjectsKey: "reports/a.csv, reports/b.csv"},
+			want: want{
+				presign: &Presign{
+					Objects: []string{"reports/a.csv", "reports/b.csv"},
+					Methods: []string{"GET"},
+					TTL:     metav1.Duration{Duration: time.Hour},
+				},
+			},
+		},
+		"MethodsAndTTL": {
+			volCtx: map[string]string{
+				client.PresignObjectsKey: "uploads/result.json",
+				client.PresignMethodsKey: "get,PUT",
+				client.PresignTTLKey:     "15m",
+			},
+			want: want{
+				presign: &Presign{
+					Objects: []string{"uploads/result.json"},
+					Methods: []string{"GET", "PUT"},
+					TTL:     metav1.Duration{Duration: 15 * time.Minute},
+				},
+			},
+		},
+		"NoObjects": {
+			volCtx: map[string]string{client.PresignObjectsKey: " , "},
+			want:   want{err: util.ErrorPresignObjectsUnset},
+		},
+		"InvalidMethod": {
+			volCtx: map[string]string{client.PresignObjectsKey: "a", client.PresignMethodsKey: "DELETE"},
+			want:   want{err: fmt.Errorf(util.ErrorTemplatePresignMethodInvalid, "DELETE")},
+		},
+		"TTLTooLong": {
+			volCtx: map[string]string{client.PresignObjectsKey: "a", client.PresignTTLKey: "720h"},
+			want:   want{err: fmt.Errorf(util.ErrorTemplatePresignTTLInvalid, "720h", minPresignTTL, maxPresignTTL)},
+		},
+		"WithFormat": {
+			volCtx: map[string]string{client.PresignObjectsKey: "a", client.FormatKey: "sdk"},
+			want:   want{err: util.ErrorPresignWithFormat},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			presign, err := ParsePresign(tc.volCtx)
+			if diff := cmp.Diff(tc.want.err, err, util.EquateErrors()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.presign, presign); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPresignSetExpiry(t *testing.T) {
+	soon := testNow.Add(10 * time.Minute)
+	late := testNow.Add(2 * time.Hour)
+
+	cases := map[string]struct {
+		credentialsExpireAt *time.Time
+		want                time.Time
+	}{
+		"NoCredentialsExpiry": {
+			want: testNow.Add(time.Hour),
+		},
+		"CredentialsOutliveTTL": {
+			credentialsExpireAt: &late,
+			want:                testNow.Add(time.Hour),
+		},
+		"CredentialsExpireFirst": {
+			credentialsExpireAt: &soon,
+			want:                soon,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			presign := Presign{TTL: metav1.Duration{Duration: time.Hour}}
+			presign.SetExpiry(testNow, tc.credentialsExpireAt)
+			if diff := cmp.Diff(tc.want, presign.ExpiresAt); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPresignRefreshAt(t *testing.T) {
+	cases := map[string]struct {
+		presign Presign
+		want    time.Time
+	}{
+		"FullTTL": {
+			presign: Presign{
+				TTL:       metav1.Duration{Duration: time.Hour},
+				SignedAt:  testNow,
+				ExpiresAt: testNow.Add(time.Hour),
+			},
+			want: testNow.Add(40 * time.Minute),
+		},
+		"CredentialsExpireFirst": {
+			presign: Presign{
+				TTL:       metav1.Duration{Duration: time.Hour},
+				SignedAt:  testNow,
+				ExpiresAt: testNow.Add(9 * time.Minute),
+			},
+			want: testNow.Add(6 * time.Minute),
+		},
+		"NoSigningTime": {
+			presign: Presign{
+				TTL:       metav1.Duration{Duration: time.Hour},
+				ExpiresAt: testNow.Add(time.Hour),
+			},
+			want: testNow.Add(40 * time.Minute),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, tc.presign.refreshAt()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPresignedVolumeCredentialsExpire(t *testing.T) {
+	bkt := testutils.GetB(testutils.WithProtocol(v1alpha1.Protocol{
+		S3: &v1alpha1.S3Protocol{Endpoint: "https://s3.example.com", BucketName: "bucketName"},
+	}))
+	secretWithExpiry := func(version string, expiresAt time.Time) *v1.Secret {
+		secret := testutils.GetSecret()
+		secret.ResourceVersion = version
+		secret.Annotations = map[string]string{"expiry": expiresAt.Format(time.RFC3339)}
+		secret.Data = map[string][]byte{
+			"accessKeyID":     []byte("AKIAEXAMPLE"),
+			"secretAccessKey": []byte("secret"),
+		}
+		return secret
+	}
+	secret := secretWithExpiry("1", testNow.Add(9*time.Minute))
+
+	fs := memFS{}
+	fakeClock := clock.NewFakeClock(testNow)
+	recorder := record.NewFakeRecorder(20)
+	ns := &NodeServer{
+		name:        name,
+		nodeID:      nodeId,
+		volumeLimit: volLimit,
+		cosiClient: &fake.FakeNodeClient{
+			MockGetResources: func(ctx context.Context, barName, barNs, podName, podNs string) (*v1alpha1.Bucket, *v1alpha1.BucketAccess, *v1.Pod, error) {
+				return bkt, testutils.GetBA(), testutils.GetPod(), nil
+			},
+			MockGetCachedBA: func(baName string) (*v1alpha1.BucketAccess, error) {
+				return testutils.GetBA(), nil
+			},
+			MockGetPod: func(ctx context.Context, podName, podNs string) (*v1.Pod, error) {
+				return testutils.GetPod(), nil
+			},
+			MockGetB: func(ctx context.Context, pod *v1.Pod, bName string) (*v1alpha1.Bucket, error) {
+				return bkt, nil
+			},
+			MockGetSecret: func(ctx context.Context, pod *v1.Pod, ba *v1alpha1.BucketAccess) (*v1.Secret, error) {
+				return secret, nil
+			},
+			MockUpdateBAFinalizers: func(ctx context.Context, baName string, add map[string]client.FinalizerOwner, remove []string) (*v1alpha1.BucketAccess, error) {
+				return testutils.GetBA(), nil
+			},
+			MockRecorder: recorder,
+		},
+		provisioner:      NewProvisioner("/data", mount.NewFakeMounter(nil), fs.client()),
+		clock:            fakeClock,
+		expiryAnnotation: "expiry",
+	}
+
+	_, err := ns.NodePublishVolume(ctx, &csi.NodePublishVolumeRequest{
+		VolumeContext: map[string]string{
+			client.BarNameKey:        testutils.GetBAR().Name,
+			client.PodNameKey:        podName,
+			client.PodNamespaceKey:   testutils.Namespace,
+			client.PresignObjectsKey: "reports/daily.csv",
+		},
+		VolumeId:   provVolumeId,
+		TargetPath: provTargetPath,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	drain(recorder)
+
+	signedAt := func() time.Time {
+		meta, err := ns.provisioner.readMetadata(provVolumeId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return meta.Presign.SignedAt
+	}
+	warnings := func() int {
+		count := 0
+		for _, e := range drain(recorder) {
+			if strings.HasPrefix(e, "Warning "+util.PresignedURLs+" ") {
+				count++
+			}
+		}
+		return count
+	}
+
+	// the lifetime capped by the credentials is not yet two thirds through
+	fakeClock.Step(5 * time.Minute)
+	ns.refreshPresigned(ctx)
+	if diff := cmp.Diff(testNow, signedAt()); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+
+	// the credentials have expired, the URLs are left as they are and the pod is warned once
+	fakeClock.Step(5 * time.Minute)
+	ns.refreshPresigned(ctx)
+	fakeClock.Step(time.Minute)
+	ns.refreshPresigned(ctx)
+	if diff := cmp.Diff(testNow, signedAt()); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+	if diff := cmp.Diff(1, warnings()); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+
+	// the rotated credentials sign the URLs anew
+	secret = secretWithExpiry("2", testNow.Add(2*time.Hour))
+	ns.refreshPresigned(ctx)
+	if diff := cmp.Diff(fakeClock.Now(), signedAt()); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+	if diff := cmp.Diff(0, warnings()); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+}
+
+func TestPresignedVolume(t *testing.T) {
+	bkt := testutils.GetB(testutils.WithProtocol(v1alpha1.Protocol{
+		S3: &v1alpha1.S3Protocol{Endpoint: "https://s3.example.com", BucketName: "bucketName"},
+	}))
+	secret := testutils.GetSecret()
+	secret.Data = map[string][]byte{
+		"accessKeyID":     []byte("AKIAEXAMPLE"),
+		"secretAccessKey": []byte("secret"),
+	}
+
+	fs := memFS{}
+	fakeClock := clock.NewFakeClock(testNow)
+	ns := &NodeServer{
+		name:        name,
+		nodeID:      nodeId,
+		volumeLimit: volLimit,
+		cosiClient: &fake.FakeNodeClient{
+			MockGetResources: func(ctx context.Context, barName, barNs, podName, podNs string) (*v1alpha1.Bucket, *v1alpha1.BucketAccess, *v1.Pod, error) {
+				return bkt, testutils.GetBA(), testutils.GetPod(), nil
+			},
+			MockGetCachedBA: func(baName string) (*v1alpha1.BucketAccess, error) {
+				return testutils.GetBA(), nil
+			},
+			MockGetPod: func(ctx context.Context, podName, podNs string) (*v1.Pod, error) {
+				return testutils.GetPod(), nil
+			},
+			MockGetB: func(ctx context.Context, pod *v1.Pod, bName string) (*v1alpha1.Bucket, error) {
+				return bkt, nil
+			},
+			MockGetSecret: func(ctx context.Context, pod *v1.Pod, ba *v1alpha1.BucketAccess) (*v1.Secret, error) {
+				return secret, nil
+			},
+			MockUpdateBAFinalizers: func(ctx context.Context, baName string, add map[string]client.FinalizerOwner, remove []string) (*v1alpha1.BucketAccess, error) {
+				return testutils.GetBA(), nil
+			},
+			MockRecorder: &record.FakeRecorder{},
+		},
+		provisioner: NewProvisioner("/data", mount.NewFakeMounter(nil), fs.client()),
+		clock:       fakeClock,
+	}
+
+	_, err := ns.NodePublishVolume(ctx, &csi.NodePublishVolumeRequest{
+		VolumeContext: map[string]string{
+			client.BarNameKey:        testutils.GetBAR().Name,
+			client.PodNameKey:        podName,
+			client.PodNamespaceKey:   testutils.Namespace,
+			client.PresignObjectsKey: "reports/daily.csv",
+			client.PresignMethodsKey: "GET,PUT",
+			client.PresignTTLKey:     "30m",
+		},
+		VolumeId:   provVolumeId,
+		TargetPath: provTargetPath,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the credentials are neither staged nor published
+	var files []string
+	for path := range fs {
+		files = append(files, path)
+	}
+	wantFiles := []string{
+		"/data/" + provVolumeId + "/bucket/presigned-urls.json",
+		"/data/" + provVolumeId + "/bucket/protocolConn.json",
+		"/data/" + provVolumeId + "/metadata.json",
+	}
+	if diff := cmp.Diff(wantFiles, files, cmpopts.SortSlices(func(a, b string) bool { return a < b })); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+
+	readURLs := func() presignedURLs {
+		urls := presignedURLs{}
+		if err := json.Unmarshal(fs[filepath.Join("/data", provVolumeId, "bucket", presignedURLsFileName)], &urls); err != nil {
+			t.Fatal(err)
+		}
+		return urls
+	}
+	urls := readURLs()
+	if diff := cmp.Diff(testNow.Add(30*time.Minute), urls.ExpiresAt); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+	for _, method := range []string{"GET", "PUT"} {
+		if u := urls.Objects["reports/daily.csv"][method]; !strings.HasPrefix(u, "https://s3.example.com/bucketName/reports/daily.csv?") {
+			t.Errorf("expected a presigned %s URL of the object, got %q", method, u)
+		}
+	}
+
+	// not yet due
+	fakeClock.Step(15 * time.Minute)
+	ns.refreshPresigned(ctx)
+	if diff := cmp.Diff(testNow.Add(30*time.Minute), readURLs().ExpiresAt); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+
+	// less than a third of the lifetime left
+	fakeClock.Step(5 * time.Minute)
+	ns.refreshPresigned(ctx)
+	if diff := cmp.Diff(testNow.Add(50*time.Minute), readURLs().ExpiresAt); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+
+	meta, err := ns.provisioner.readMetadata(provVolumeId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(testNow.Add(50*time.Minute), meta.Presign.ExpiresAt); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+	if tampered := ns.provisioner.tamperedFiles(ns.provisioner.bucketPath(provVolumeId), meta.Files); len(tampered) > 0 {
+		t.Errorf("expected the metadata to record the refreshed files, got %v tampered", tampered)
+	}
+}
@@ -1,20 +1,20 @@
 package node
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+
 	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
 
 	"github.com/pkg/errors"
-	"k8s.io/klog/v2"
 	"k8s.io/mount-utils"
 
 	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client"
-)
-
-const (
-	finalizer = "cosi.objectstorage.k8s.io/bucketaccess-protection"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/logging"
 )
 
 type Provisioner struct {
@@ -31,6 +31,16 @@ func NewProvisioner(dataPath string, p mount.Interface, pc client.ProvisionerCli
 	}
 }
 
+// volumeDirID returns the ID the volume is stored under in the data root. Persistent volumes can be
+// published to several pods on the same node, so each publication gets its own directory, keyed by
+// the target path which is unique per pod.
+func volumeDirID(volID, targetPath string) string {
+	if !strings.HasPrefix(volID, client.PersistentVolumeIDPrefix) {
+		return volID
+	}
+	return fmt.Sprintf("%s-%s", volID, util.Checksum([]byte(targetPath))[:finalizerHashLength])
+}
+
 func (p Provisioner) volPath(volID string) string {
 	return filepath.Join(p.dataPath, volID)
 }
@@ -40,7 +50,7 @@ func (p Provisioner) bucketPath(volID string) string {
 }
 
 func (p Provisioner) createDir(volID string) error {
-	if err := p.pclient.MkdirAll(p.bucketPath(volID), 0750); err != nil {
+	if err := p.pclient.MkdirAll(p.volPath(volID), 0750); err != nil {
 		return errors.Wrap(err, util.WrapErrorMkdirFailed)
 	}
 	return nil
@@ -53,11 +63,11 @@ func (p Provisioner) removeDir(volID string) error {
 	return nil
 }
 
-func (p Provisioner) mountDir(volID, targetPath string) error {
+// mountDir bind mounts the folder holding the files of a volume at targetPath.
+func (p Provisioner) mountDir(source, targetPath string) error {
 	// Check if the target path is already mounted. Prevent remounting.
 	notMnt, err := mount.IsNotMountPoint(p.mounter, targetPath)
 	if err != nil {
-		klog.Error(err)
 		if os.IsNotExist(err) {
 			if err = p.pclient.MkdirAll(targetPath, 0750); err != nil {
 				return errors.Wrap(err, util.WrapErrorFailedToMkdirForMount)
@@ -72,47 +82,90 @@ func (p Provisioner) mountDir(volID, targetPath string) error {
 		return fmt.Errorf(util.ErrorTemplateVolumeAlreadyMounted, targetPath)
 	}
 
-	if err := p.mounter.Mount(p.bucketPath(volID), targetPath, "", []string{"bind"}); err != nil {
-		return errors.Wrap(err, fmt.Sprintf(util.ErrorTemplateMountFailed, p.bucketPath(volID), targetPath))
+	if err := p.mounter.Mount(source, targetPath, "", []string{"bind"}); err != nil {
+		return errors.Wrap(err, fmt.Sprintf(util.ErrorTemplateMountFailed, source, targetPath))
 	}
 	return nil
 }
 
-func (p Provisioner) writeFileToVolumeMount(data []byte, volID, fileName string) error {
-	err := p.pclient.WriteFile(data, filepath.Join(p.bucketPath(volID), fileName))
-	if err != nil {
-		return errors.Wrap(err, util.WrapErrorFailedToCreateBucketFile)
-	}
-	return nil
+func (p Provisioner) readFileFromVolume(volID, fileName string) ([]byte, error) {
+	return p.pclient.ReadFile(filepath.Join(p.volPath(volID), fileName))
 }
 
-func (p Provisioner) writeFileToVolume(data []byte, volID, fileName string) error {
-	err := p.pclient.WriteFile(data, filepath.Join(p.volPath(volID), fileName))
+// listVolumes returns the IDs of all volumes which have a directory in the data root. Hidden
+// directories, like the staged credentials, are not volumes.
+func (p Provisioner) listVolumes() ([]string, error) {
+	entries, err := p.pclient.ReadDir(p.dataPath)
 	if err != nil {
-		return errors.Wrap(err, util.WrapErrorFailedToCreateVolumeFile)
+		return nil, errors.Wrap(err, util.WrapErrorFailedToListVolumes)
 	}
-	return nil
+
+	var volIDs []string
+	for _, e := range entries {
+		if e.IsDir() && !strings.HasPrefix(e.Name(), ".") {
+			volIDs = append(volIDs, e.Name())
+		}
+	}
+	return volIDs, nil
 }
 
-func (p Provisioner) readFileFromVolume(volID, fileName string) ([]byte, error) {
-	return p.pclient.ReadFile(filepath.Join(p.volPath(volID), fileName))
+// wipeCredentials removes the credentials from the files folder, leaving the connection information in place.
+// Every file recorded in files but the connection information holds credentials, volumes which
+// recorded none only have the credentials file.
+func (p Provisioner) wipeCredentials(dir string, files map[string]string) error {
+	names := []string{}
+	for name := range files {
+		if name != protocolFileName {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		names = append(names, credsFileName)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := p.pclient.RemoveAll(filepath.Join(dir, name)); err != nil {
+			return errors.Wrap(err, util.WrapErrorFailedToWipeVolume)
+		}
+	}
+	return nil
 }
 
-func (p Provisioner) removeMount(path string) error {
+func (p Provisioner) removeMount(ctx context.Context, path string) error {
 	err := mount.CleanupMountPoint(path, p.mounter, true)
 	if err != nil && !os.IsNotExist(err) {
-		klog.ErrorS(err, "failed to clean and unmount target path", "targetPath", path)
+		logging.FromContext(ctx).Error(err, "failed to clean and unmount target path", "targetPath", path)
 		return errors.Wrap(err, util.WrapErrorFailedToUnmountVolume)
 	}
 	return nil
 }
 
-type Metadata struct {
-	BaName       string `json:"baName"`
-	PodName      string `json:"podName"`
-	PodNamespace string `json:"podNamespace"`
+func (p Provisioner) isMounted(targetPath string) (bool, error) {
+	notMnt, err := mount.IsNotMountPoint(p.mounter, targetPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return !notMnt, nil
 }
 
-func (m Metadata) finalizer() string {
-	return fmt.Sprintf("%s-%s-%s", finalizer, m.PodNamespace, m.PodName)
+// tamperedFiles compares the files in dir against the checksums recorded at publish and describes
+// each one that is missing or has been modified.
+func (p Provisioner) tamperedFiles(dir string, checksums map[string]string) []string {
+	var problems []string
+	for name, sum := range checksums {
+		data, err := p.pclient.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			problems = append(problems, fmt.Sprintf(util.ConditionTemplateFileMissing, name))
+			continue
+		}
+		if util.Checksum(data) != sum {
+			problems = append(problems, fmt.Sprintf(util.ConditionTemplateFileModified, name))
+		}
+	}
+	sort.Strings(problems)
+	return problems
 }
@@ -127,7 +127,7 @@ func TestMountDir(t *testing.T) {
 				pclient:  tc.rclient,
 			}
 
-			err := p.mountDir(tc.volId, tc.targetPath)
+			err := p.mountDir(p.bucketPath(tc.volId), tc.targetPath)
 
 			if diff := cmp.Diff(tc.want.err, err, util.EquateErrors()); diff != "" {
 				t.Errorf("r: -want, +got:\n%s", diff)
@@ -135,3 +135,20 @@ func TestMountDir(t *testing.T) {
 		})
 	}
 }
+
+func TestVolumeDirID(t *testing.T) {
+	ephemeral := volumeDirID(volumeId, targetPath)
+	if ephemeral != volumeId {
+		t.Errorf("expected ephemeral volume to be stored under its ID, got %q", ephemeral)
+	}
+
+	persistentID := client.PersistentVolumeIDPrefix + "pvc-1234"
+	first := volumeDirID(persistentID, "/var/lib/kubelet/pods/pod-1/volumes/kubernetes.io~csi/pvc-1234/mount")
+	second := volumeDirID(persistentID, "/var/lib/kubelet/pods/pod-2/volumes/kubernetes.io~csi/pvc-1234/mount")
+	if first == second {
+		t.Errorf("expected publications to different pods to be stored apart, both got %q", first)
+	}
+	if diff := cmp.Diff(first, volumeDirID(persistentID, "/var/lib/kubelet/pods/pod-1/volumes/kubernetes.io~csi/pvc-1234/mount")); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+}
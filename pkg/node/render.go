@@ -0,0 +1,73 @@
+package node
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/protocol"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+// ParseFormat returns the format requested in the volume context, protocol.FormatJSON when none is.
+// Any format a registered protocol renders is accepted.
+func ParseFormat(volCtx map[string]string) (string, error) {
+	format, ok := volCtx[client.FormatKey]
+	if !ok {
+		return protocol.FormatJSON, nil
+	}
+	formats := protocol.Formats()
+	for _, f := range formats {
+		if f == format {
+			return format, nil
+		}
+	}
+	return "", fmt.Errorf(util.ErrorTemplateInvalidFormat, format, strings.Join(formats, ", "))
+}
+
+// RenderFiles returns the files NodePublishVolume writes to the bucket folder of a volume, keyed by
+// file name. It only depends on its arguments, so that volumes can be previewed offline.
+func RenderFiles(bkt *v1alpha1.Bucket, secret *v1.Secret, expiresAt *time.Time, format string) (map[string][]byte, error) {
+	p, err := protocol.Lookup(bkt)
+	if err != nil {
+		return nil, err
+	}
+	protocolConnection, err := p.Connection(bkt)
+	if err != nil {
+		return nil, err
+	}
+
+	if expiresAt != nil {
+		if protocolConnection, err = withExpiry(protocolConnection, *expiresAt); err != nil {
+			return nil, err
+		}
+	}
+
+	creds, err := util.ParseData(secret)
+	if err != nil {
+		return nil, errors.Wrap(err, util.WrapErrorFailedToParseSecret)
+	}
+
+	files := map[string][]byte{
+		protocolFileName: protocolConnection,
+		credsFileName:    creds,
+	}
+	// protocols without a renderer for the format publish no more files
+	render, ok := p.Renderers()[format]
+	if !ok {
+		return files, nil
+	}
+	rendered, err := render(bkt, secret)
+	if err != nil {
+		return nil, err
+	}
+	for name, data := range rendered {
+		files[name] = data
+	}
+	return files, nil
+}
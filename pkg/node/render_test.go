@@ -0,0 +1,102 @@
+package node
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/protocol"
+)
+
+var update = flag.Bool("update", false, "update the golden files of the render tests")
+
+// TestRenderFiles renders every case under testdata/render and compares the result with the files
+// in its golden folder. The format is read from the optional format file of the case. Run with
+// -update to regenerate them.
+func TestRenderFiles(t *testing.T) {
+	cases, err := ioutil.ReadDir(filepath.Join("testdata", "render"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range cases {
+		dir := filepath.Join("testdata", "render", c.Name())
+		t.Run(c.Name(), func(t *testing.T) {
+			bkt := &v1alpha1.Bucket{}
+			readTestObject(t, filepath.Join(dir, "bucket.yaml"), bkt)
+			secret := &v1.Secret{}
+			readTestObject(t, filepath.Join(dir, "secret.yaml"), secret)
+
+			expiresAt, err := CredentialsExpiry(secret, expiryAnnotation)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			format := protocol.FormatJSON
+			if data, err := ioutil.ReadFile(filepath.Join(dir, "format")); err == nil {
+				format = strings.TrimSpace(string(data))
+			}
+
+			files, err := RenderFiles(bkt, secret, expiresAt, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			goldenDir := filepath.Join(dir, "golden")
+			if *update {
+				if err := os.RemoveAll(goldenDir); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.MkdirAll(goldenDir, 0755); err != nil {
+					t.Fatal(err)
+				}
+				for name, data := range files {
+					if err := ioutil.WriteFile(filepath.Join(goldenDir, name), data, 0644); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+
+			entries, err := ioutil.ReadDir(goldenDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			golden := map[string]string{}
+			for _, e := range entries {
+				data, err := ioutil.ReadFile(filepath.Join(goldenDir, e.Name()))
+				if err != nil {
+					t.Fatal(err)
+				}
+				golden[e.Name()] = string(data)
+			}
+
+			rendered := map[string]string{}
+			for name, data := range files {
+				rendered[name] = string(data)
+			}
+
+			if diff := cmp.Diff(golden, rendered); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func readTestObject(t *testing.T, path string, obj interface{}) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := yaml.Unmarshal(data, obj); err != nil {
+		t.Fatal(err)
+	}
+}
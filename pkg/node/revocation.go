@@ -0,0 +1,177 @@
+package node
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/audit"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/logging"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+// RevocationPolicy decides what happens to mounted credentials once their BucketAccess is revoked or deleted.
+type RevocationPolicy string
+
+const (
+	// RevocationPolicyWarn only emits a warning event on the pods using the credentials.
+	RevocationPolicyWarn RevocationPolicy = "Warn"
+	// RevocationPolicyWipe additionally removes the credentials file from the mounted volumes.
+	RevocationPolicyWipe RevocationPolicy = "Wipe"
+)
+
+func (r RevocationPolicy) Validate() error {
+	switch r {
+	case RevocationPolicyWarn, RevocationPolicyWipe:
+		return nil
+	}
+	return util.ErrorInvalidRevocationPolicy
+}
+
+// revocation is a revoked bucketAccess queued for the pods using it to be told, and the reason.
+type revocation struct {
+	baName string
+	reason util.EventResource
+	// listed is set for bucketAccesses found revoked when the informer lists them. Their volumes may
+	// have been told before the adapter restarted.
+	listed bool
+}
+
+// revocationHandler queues the bucketAccesses seen revoked or deleted by the informer. Every
+// bucketAccess already revoked is seen on startup, the queue runs them one at a time.
+func (n *NodeServer) revocationHandler() cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			ba, ok := obj.(*v1alpha1.BucketAccess)
+			if !ok {
+				return
+			}
+			switch {
+			case ba.DeletionTimestamp != nil:
+				n.revocations.Add(revocation{baName: ba.Name, reason: util.BADeleting, listed: true})
+			case !ba.Status.AccessGranted:
+				n.revocations.Add(revocation{baName: ba.Name, reason: util.BAAccessRevoked, listed: true})
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			old, ok := oldObj.(*v1alpha1.BucketAccess)
+			if !ok {
+				return
+			}
+			ba, ok := newObj.(*v1alpha1.BucketAccess)
+			if !ok {
+				return
+			}
+			switch {
+			case old.DeletionTimestamp == nil && ba.DeletionTimestamp != nil:
+				n.revocations.Add(revocation{baName: ba.Name, reason: util.BADeleting})
+			case old.Status.AccessGranted && !ba.Status.AccessGranted:
+				n.revocations.Add(revocation{baName: ba.Name, reason: util.BAAccessRevoked})
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			ba, ok := obj.(*v1alpha1.BucketAccess)
+			if !ok {
+				return
+			}
+			n.revocations.Add(revocation{baName: ba.Name, reason: util.BADeleted})
+		},
+	}
+}
+
+// runRevocations handles the queued revocations until the queue is shut down.
+func (n *NodeServer) runRevocations(ctx context.Context) {
+	for n.processNextRevocation(ctx) {
+	}
+}
+
+func (n *NodeServer) processNextRevocation(ctx context.Context) bool {
+	item, shutdown := n.revocations.Get()
+	if shutdown {
+		return false
+	}
+	defer n.revocations.Done(item)
+
+	r := item.(revocation)
+	if err := n.revoke(ctx, r.baName, r.reason, r.listed); err != nil {
+		logging.FromContext(ctx).Error(err, "failed to revoke volumes, retrying", "bucketAccess", r.baName)
+		n.revocations.AddRateLimited(item)
+		return true
+	}
+	n.revocations.Forget(item)
+	return true
+}
+
+// revoke warns every pod with a volume published from the given BucketAccess and, depending on the
+// revocation policy, removes the credentials from those volumes. Volumes are marked once handled,
+// bucketAccesses listed on startup skip the volumes already marked for the same reason. It fails
+// when the volumes cannot be listed, failures of single volumes are logged.
+func (n *NodeServer) revoke(ctx context.Context, baName string, reason util.EventResource, listed bool) error {
+	volIDs, err := n.provisioner.listVolumes()
+	if err != nil {
+		return err
+	}
+
+	for _, volID := range volIDs {
+		meta, err := n.provisioner.readMetadata(volID)
+		if err != nil {
+			logging.FromContext(ctx).Error(err, "skipping volume", "volumeID", volID)
+			continue
+		}
+		if meta.BaName != baName || listed && meta.Revocation == reason.Message() {
+			continue
+		}
+
+		ctx := volumeContext(ctx, volID, meta)
+		logger := logging.FromContext(ctx)
+		logger.Info("bucketAccess revoked for published volume", "policy", n.revocationPolicy)
+
+		var pod *v1.Pod
+		if pod, err = n.cosiClient.GetPod(ctx, meta.PodName, meta.PodNamespace); err != nil {
+			logger.Error(err, "failed to get pod for revoked volume")
+		} else {
+			util.EmitWarningEvent(ctx, n.cosiClient.Recorder(), pod, reason)
+		}
+		n.audit(ctx, audit.ActionRevoke, volID, meta, reason.Message())
+
+		if n.revocationPolicy == RevocationPolicyWipe {
+			if meta.StageID == "" {
+				err = n.provisioner.wipeCredentials(n.provisioner.bucketPath(volID), meta.Files)
+			} else {
+				err = n.wipeStage(meta.StageID, meta.Files)
+			}
+			if err != nil {
+				logger.Error(err, "failed to wipe credentials")
+				continue
+			}
+			if pod != nil {
+				util.EmitWarningEvent(ctx, n.cosiClient.Recorder(), pod, util.CredentialsWiped)
+			}
+		}
+
+		if err := n.markRevoked(volID, reason); err != nil {
+			logger.Error(err, "failed to mark volume revoked")
+		}
+	}
+	return nil
+}
+
+// markRevoked records in the metadata of the volume that the revocation was handled.
+func (n *NodeServer) markRevoked(volID string, reason util.EventResource) error {
+	unlock := n.volumeLocks.lock(volID)
+	defer unlock()
+
+	meta, err := n.provisioner.readMetadata(volID)
+	if err != nil {
+		return err
+	}
+	meta.Revocation = reason.Message()
+	return errors.Wrap(n.provisioner.writeMetadata(volID, meta), util.WrapErrorFailedToWriteMetadata)
+}
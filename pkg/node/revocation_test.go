@@ -0,0 +1,221 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client/fake"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+	testutils "sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util/test"
+)
+
+type fakeDirEntry struct {
+	name string
+}
+
+func (f fakeDirEntry) Name() string       { return f.name }
+func (f fakeDirEntry) Size() int64        { return 0 }
+func (f fakeDirEntry) Mode() os.FileMode  { return os.ModeDir }
+func (f fakeDirEntry) ModTime() time.Time { return time.Time{} }
+func (f fakeDirEntry) IsDir() bool        { return true }
+func (f fakeDirEntry) Sys() interface{}   { return nil }
+
+// withVolumes returns a provisioner client whose data root holds one volume per entry of volumes,
+// each published from the mapped bucketAccess. Metadata written is read back.
+func withVolumes(volumes map[string]string, removed *[]string) *fake.MockProvisionerClient {
+	written := map[string][]byte{}
+	return &fake.MockProvisionerClient{
+		MockReadDir: func(dirname string) ([]os.FileInfo, error) {
+			var entries []os.FileInfo
+			for volID := range volumes {
+				entries = append(entries, fakeDirEntry{name: volID})
+			}
+			return entries, nil
+		},
+		MockReadFile: func(filename string) ([]byte, error) {
+			if data, ok := written[filename]; ok {
+				return data, nil
+			}
+			volID := filepath.Base(filepath.Dir(filename))
+			return json.Marshal(Metadata{
+				BaName:       volumes[volID],
+				PodName:      podName,
+				PodNamespace: testutils.Namespace,
+			})
+		},
+		MockWriteFile: func(data []byte, filepath string) error {
+			written[filepath] = data
+			return nil
+		},
+		MockRename: func(oldpath, newpath string) error {
+			written[newpath] = written[oldpath]
+			delete(written, oldpath)
+			return nil
+		},
+		MockRemoveAll: func(path string) error {
+			// the temporary files of atomic writes are of no interest
+			if filepath.Base(path) != tempFileName(metadataFilename) {
+				*removed = append(*removed, path)
+			}
+			return nil
+		},
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	type args struct {
+		policy  RevocationPolicy
+		volumes map[string]string
+		reason  util.EventResource
+	}
+
+	type want struct {
+		removed []string
+		events  int
+	}
+
+	cases := map[string]struct {
+		args
+		want
+	}{
+		"WarnOnly": {
+			args: args{
+				policy: RevocationPolicyWarn,
+				volumes: map[string]string{
+					"vol-1": "bucketAccessName",
+					"vol-2": "otherBucketAccess",
+				},
+				reason: util.BAAccessRevoked,
+			},
+			want: want{
+				removed: nil,
+				events:  1,
+			},
+		},
+		"Wipe": {
+			args: args{
+				policy: RevocationPolicyWipe,
+				volumes: map[string]string{
+					"vol-1": "bucketAccessName",
+					"vol-2": "bucketAccessName",
+					"vol-3": "otherBucketAccess",
+				},
+				reason: util.BADeleted,
+			},
+			want: want{
+				removed: []string{
+					filepath.Join("vol-1", "bucket", credsFileName),
+					filepath.Join("vol-2", "bucket", credsFileName),
+				},
+				events: 4,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var removed []string
+			recorder := record.NewFakeRecorder(10)
+			ns := &NodeServer{
+				name:        name,
+				nodeID:      nodeId,
+				volumeLimit: volLimit,
+				cosiClient: &fake.FakeNodeClient{
+					MockGetPod: func(ctx context.Context, podName, podNs string) (*v1.Pod, error) {
+						return testutils.GetPod(), nil
+					},
+					MockRecorder: recorder,
+				},
+				provisioner:      getTestProvisioner(withVolumes(tc.volumes, &removed)),
+				revocationPolicy: tc.policy,
+			}
+
+			if err := ns.revoke(ctx, "bucketAccessName", tc.reason, false); err != nil {
+				t.Fatal(err)
+			}
+
+			sort.Strings(removed)
+			if diff := cmp.Diff(tc.want.removed, removed); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+
+			if diff := cmp.Diff(tc.want.events, len(recorder.Events)); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRevocationHandler(t *testing.T) {
+	var removed []string
+	recorder := record.NewFakeRecorder(10)
+	ns := &NodeServer{
+		name:   name,
+		nodeID: nodeId,
+		cosiClient: &fake.FakeNodeClient{
+			MockGetPod: func(ctx context.Context, podName, podNs string) (*v1.Pod, error) {
+				return testutils.GetPod(), nil
+			},
+			MockRecorder: recorder,
+		},
+		provisioner:      getTestProvisioner(withVolumes(map[string]string{"vol-1": "bucketAccessName"}, &removed)),
+		revocationPolicy: RevocationPolicyWarn,
+		revocations:      workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+	}
+	handler := ns.revocationHandler()
+
+	// the informer lists every revoked bucketAccess on startup, and may see one repeatedly
+	revoked := testutils.GetBA()
+	revoked.Status.AccessGranted = false
+	granted := testutils.GetBA()
+	granted.Name = "granted"
+	handler.OnAdd(revoked)
+	handler.OnAdd(revoked)
+	handler.OnAdd(granted)
+
+	if diff := cmp.Diff(1, ns.revocations.Len()); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+	if !ns.processNextRevocation(ctx) {
+		t.Fatal("expected the queue to run")
+	}
+	if diff := cmp.Diff(0, ns.revocations.Len()); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+	if diff := cmp.Diff(1, len(drain(recorder))); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+
+	// listed again after a restart, the volume already told is skipped
+	handler.OnAdd(revoked)
+	if !ns.processNextRevocation(ctx) {
+		t.Fatal("expected the queue to run")
+	}
+	if diff := cmp.Diff(0, len(drain(recorder))); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+
+	// revoked again once granted again, the volume is told again
+	handler.OnUpdate(testutils.GetBA(), revoked)
+	if !ns.processNextRevocation(ctx) {
+		t.Fatal("expected the queue to run")
+	}
+	if diff := cmp.Diff(1, len(drain(recorder))); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+
+	ns.revocations.ShutDown()
+	if ns.processNextRevocation(ctx) {
+		t.Error("expected the queue to stop once shut down")
+	}
+}
@@ -0,0 +1,555 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	v1 "k8s.io/api/core/v1"
+
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/audit"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/logging"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/protocol"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+const (
+	// stagedDirName holds the staged credentials under the data root. It starts with a dot, so it
+	// is never mistaken for a volume.
+	stagedDirName = ".staged"
+	stageFilename = "stage.json"
+)
+
+// Stage is stored next to the staged bucket folder. The credentials of a bucketAccess are staged
+// once per node and bind mounted into every volume using it. A stage is only a cache, one which
+// cannot be read is staged again.
+type Stage struct {
+	Version    int    `json:"version"`
+	BaName     string `json:"baName"`
+	BucketName string `json:"bucketName"`
+	Format     string `json:"format"`
+	// Secret is the namespace/name of the minted secret the credentials were read from.
+	Secret                string `json:"secret"`
+	SecretResourceVersion string `json:"secretResourceVersion,omitempty"`
+	// BaGeneration is the generation of the bucketAccess when the credentials were staged.
+	BaGeneration int64 `json:"baGeneration,omitempty"`
+
+	Files     map[string]string `json:"files,omitempty"`
+	ExpiresAt *time.Time        `json:"expiresAt,omitempty"`
+	UpdatedAt time.Time         `json:"updatedAt"`
+}
+
+// stageID names the stage of the bucketAccess for the format. Volumes only share a stage when they
+// render the same files. The separator cannot appear in object names.
+func stageID(ba *v1alpha1.BucketAccess, format string) string {
+	if format == protocol.FormatJSON {
+		return ba.Name
+	}
+	return ba.Name + "_" + format
+}
+
+func mintedSecret(ba *v1alpha1.BucketAccess) string {
+	return fmt.Sprintf("%s/%s", ba.Status.MintedSecret.Namespace, ba.Status.MintedSecret.Name)
+}
+
+// current reports whether the stage still holds the credentials granted by the bucketAccess,
+// without reading the minted secret. Credentials are staged again when the bucketAccess names
+// another secret or changed since, and once they expire, in case the secret has been rotated in
+// place.
+func (s Stage) current(ba *v1alpha1.BucketAccess, now time.Time) bool {
+	if s.Version != metadataVersion || s.Secret != mintedSecret(ba) || s.BaGeneration != ba.Generation {
+		return false
+	}
+	return s.ExpiresAt == nil || now.Before(*s.ExpiresAt)
+}
+
+// keyedMutex holds a mutex per key, so that work on one key never waits on another. A mutex only
+// exists while it is held or waited for.
+type keyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*refMutex
+}
+
+type refMutex struct {
+	sync.Mutex
+	// refs counts the holder and the waiters of the mutex.
+	refs int
+}
+
+// lock locks the mutex of the key and returns the function unlocking it.
+func (k *keyedMutex) lock(key string) func() {
+	k.mu.Lock()
+	if k.locks == nil {
+		k.locks = map[string]*refMutex{}
+	}
+	l, ok := k.locks[key]
+	if !ok {
+		l = &refMutex{}
+		k.locks[key] = l
+	}
+	l.refs++
+	k.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		k.mu.Lock()
+		defer k.mu.Unlock()
+		if l.refs--; l.refs == 0 {
+			delete(k.locks, key)
+		}
+	}
+}
+
+// stageTracker counts the volumes using each stage, so that a stage is removed along with its last
+// volume. Volumes are counted from the moment they are staged, before they are mounted, and the
+// count of a stage only changes while its lock is held.
+type stageTracker struct {
+	locks keyedMutex
+
+	sync.Mutex
+	consumers map[string]map[string]bool
+}
+
+// load replaces the tracked consumers with the given volume IDs by stage ID.
+func (s *stageTracker) load(consumers map[string][]string) {
+	s.Lock()
+	defer s.Unlock()
+	s.consumers = map[string]map[string]bool{}
+	for id, volIDs := range consumers {
+		for _, volID := range volIDs {
+			s.add(id, volID)
+		}
+	}
+}
+
+func (s *stageTracker) add(id, volID string) {
+	if s.consumers == nil {
+		s.consumers = map[string]map[string]bool{}
+	}
+	if s.consumers[id] == nil {
+		s.consumers[id] = map[string]bool{}
+	}
+	s.consumers[id][volID] = true
+}
+
+func (s *stageTracker) acquire(id, volID string) {
+	s.Lock()
+	defer s.Unlock()
+	s.add(id, volID)
+}
+
+func (s *stageTracker) release(id, volID string) {
+	s.Lock()
+	defer s.Unlock()
+	delete(s.consumers[id], volID)
+	if len(s.consumers[id]) == 0 {
+		delete(s.consumers, id)
+	}
+}
+
+// volumes returns the IDs of the volumes using the stage.
+func (s *stageTracker) volumes(id string) []string {
+	s.Lock()
+	defer s.Unlock()
+	volIDs := make([]string, 0, len(s.consumers[id]))
+	for volID := range s.consumers[id] {
+		volIDs = append(volIDs, volID)
+	}
+	sort.Strings(volIDs)
+	return volIDs
+}
+
+func (p Provisioner) stagePath(id string) string {
+	return filepath.Join(p.dataPath, stagedDirName, id)
+}
+
+func (p Provisioner) stagedBucketPath(id string) string {
+	return filepath.Join(p.stagePath(id), "bucket")
+}
+
+// filesPath returns the folder holding the files of a volume. Volumes published before credentials
+// were staged keep their own copy.
+func (p Provisioner) filesPath(volID string, meta Metadata) string {
+	if meta.StageID == "" {
+		return p.bucketPath(volID)
+	}
+	return p.stagedBucketPath(meta.StageID)
+}
+
+func (p Provisioner) readStage(id string) (Stage, error) {
+	data, err := p.pclient.ReadFile(filepath.Join(p.stagePath(id), stageFilename))
+	if err != nil {
+		return Stage{}, err
+	}
+	st := Stage{}
+	if err := json.Unmarshal(data, &st); err != nil {
+		return Stage{}, err
+	}
+	return st, nil
+}
+
+func (p Provisioner) writeStage(id string, st Stage) error {
+	data, err := json.Marshal(st)
+	if err != nil {
+		return errors.Wrap(err, util.WrapErrorFailedToMarshalMetadata)
+	}
+	return p.writeFileAtomic(p.stagePath(id), stageFilename, data)
+}
+
+func (p Provisioner) writeStagedFiles(id string, files map[string][]byte) error {
+	if err := p.pclient.MkdirAll(p.stagedBucketPath(id), 0750); err != nil {
+		return errors.Wrap(err, util.WrapErrorMkdirFailed)
+	}
+	// the folder is bind mounted into running pods, which must never read a partial file
+	if err := p.writeFileAtomic(p.stagedBucketPath(id), protocolFileName, files[protocolFileName]); err != nil {
+		return errors.Wrap(err, util.WrapErrorFailedToWriteProtocol)
+	}
+
+	// every other file holds credentials
+	names := make([]string, 0, len(files))
+	for name := range files {
+		if name != protocolFileName {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := p.writeFileAtomic(p.stagedBucketPath(id), name, files[name]); err != nil {
+			return errors.Wrap(err, util.WrapErrorFailedToWriteCredentials)
+		}
+	}
+	return nil
+}
+
+// listStages returns the IDs of the stages under the data root.
+func (p Provisioner) listStages() ([]string, error) {
+	entries, err := p.pclient.ReadDir(filepath.Join(p.dataPath, stagedDirName))
+	if os.IsNotExist(errors.Cause(err)) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, e := range entries {
+		if e.IsDir() {
+			ids = append(ids, e.Name())
+		}
+	}
+	return ids, nil
+}
+
+// trackStages starts counting the volumes already using each stage, and removes the stages no
+// volume uses, left behind when the adapter stopped while publishing a volume.
+func (n *NodeServer) trackStages(ctx context.Context) {
+	logger := logging.FromContext(ctx)
+	volIDs, err := n.provisioner.listVolumes()
+	if err != nil {
+		logger.Error(err, "failed to list volumes for staged credentials")
+		return
+	}
+
+	consumers := map[string][]string{}
+	for _, volID := range volIDs {
+		meta, err := n.provisioner.readMetadata(volID)
+		if err != nil {
+			logger.Error(err, "skipping volume while counting stage consumers", "volumeID", volID)
+			continue
+		}
+		if meta.StageID != "" {
+			consumers[meta.StageID] = append(consumers[meta.StageID], volID)
+		}
+	}
+	n.stages.load(consumers)
+	logger.Info("tracking staged credentials", "count", len(consumers))
+
+	ids, err := n.provisioner.listStages()
+	if err != nil {
+		logger.Error(err, "failed to list staged credentials")
+		return
+	}
+	for _, id := range ids {
+		unlock := n.stages.locks.lock(id)
+		if err := n.removeUnusedStage(ctx, id); err != nil {
+			logger.Error(err, "failed to remove unused staged credentials", "stageID", id)
+		}
+		unlock()
+	}
+}
+
+// stage returns the staged credentials of the bucketAccess in the format, staging them first unless
+// the current stage can be reused, and counts the volume as a user of the stage. Only staging reads
+// the minted secret. Restaging updates the metadata of the volumes already using the stage. The
+// stage lock is held while the stage is checked and written, so that concurrent publishes of the
+// same stage read the secret once.
+func (n *NodeServer) stage(ctx context.Context, volID string, pod *v1.Pod, bkt *v1alpha1.Bucket, ba *v1alpha1.BucketAccess, format string) (string, Stage, error) {
+	logger := logging.FromContext(ctx)
+	id := stageID(ba, format)
+	now := n.clock.Now()
+
+	unlock := n.stages.locks.lock(id)
+	defer unlock()
+
+	st, err := n.provisioner.readStage(id)
+	if err == nil && st.current(ba, now) {
+		logger.Info("reusing staged credentials", "bucketAccess", ba.Name)
+		n.stages.acquire(id, volID)
+		return id, st, nil
+	}
+	restage := err == nil
+
+	secret, err := n.cosiClient.GetSecret(ctx, pod, ba)
+	if err != nil {
+		return "", Stage{}, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	expiresAt, err := n.secretExpiry(ctx, pod, secret, now)
+	if err != nil {
+		return "", Stage{}, err
+	}
+
+	files, err := RenderFiles(bkt, secret, expiresAt, format)
+	if err != nil {
+		return "", Stage{}, status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	st = Stage{
+		Version:               metadataVersion,
+		BaName:                ba.Name,
+		BucketName:            bkt.Name,
+		Format:                format,
+		Secret:                mintedSecret(ba),
+		SecretResourceVersion: secret.ResourceVersion,
+		BaGeneration:          ba.Generation,
+		Files:                 map[string]string{},
+		ExpiresAt:             expiresAt,
+		UpdatedAt:             now,
+	}
+	for name, data := range files {
+		st.Files[name] = util.Checksum(data)
+	}
+
+	if err := n.provisioner.writeStagedFiles(id, files); err != nil {
+		return "", Stage{}, n.removeUnusedStageAfterError(ctx, id, err)
+	}
+	if err := n.provisioner.writeStage(id, st); err != nil {
+		return "", Stage{}, n.removeUnusedStageAfterError(ctx, id, errors.Wrap(err, util.WrapErrorFailedToWriteStage))
+	}
+	util.EmitNormalEvent(ctx, n.cosiClient.Recorder(), pod, util.CredentialsWritten)
+
+	if restage {
+		// volumes still being published record the stage once they are mounted
+		for _, consumerID := range n.stages.volumes(id) {
+			meta, err := n.provisioner.readMetadata(consumerID)
+			if err != nil {
+				continue
+			}
+			meta.SecretResourceVersion, meta.Files, meta.ExpiresAt, meta.UpdatedAt = st.SecretResourceVersion, st.Files, st.ExpiresAt, now
+			if err := n.provisioner.writeMetadata(consumerID, meta); err != nil {
+				logger.Error(err, "failed to update metadata of volume using restaged credentials", "consumerVolumeID", consumerID)
+				continue
+			}
+			n.audit(logging.NewContext(ctx, "consumerVolumeID", consumerID), audit.ActionRotate, consumerID, meta, "")
+		}
+	}
+
+	n.stages.acquire(id, volID)
+	return id, st, nil
+}
+
+// secretExpiry returns when the credentials of the minted secret expire, failing when they have
+// expired already. The pod is told about expired credentials.
+func (n *NodeServer) secretExpiry(ctx context.Context, pod *v1.Pod, secret *v1.Secret, now time.Time) (*time.Time, error) {
+	expiresAt, err := CredentialsExpiry(secret, n.expiryAnnotation)
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	if expiresAt != nil && !now.Before(*expiresAt) {
+		util.EmitWarningEvent(ctx, n.cosiClient.Recorder(), pod, util.CredentialsExpired)
+		return nil, status.Error(codes.FailedPrecondition, fmt.Sprintf(util.ErrorTemplateCredentialsExpired, expiresAt.Format(time.RFC3339)))
+	}
+	return expiresAt, nil
+}
+
+// publishUnstaged publishes a volume whose files are specific to it, so they are written to the
+// volume itself rather than staged: presigned URLs for the objects listed in the volume context,
+// which only give access to those objects for as long as the volume stays mounted, and the service
+// account token of the pod for volumes with workload identity. presign is nil for volumes of
+// credentials, which are rendered in the format.
+func (n *NodeServer) publishUnstaged(ctx context.Context, volID string, request *csi.NodePublishVolumeRequest, pod *v1.Pod, bkt *v1alpha1.Bucket, ba *v1alpha1.BucketAccess, presign *Presign, format, audience string) (*csi.NodePublishVolumeResponse, error) {
+	if presign != nil {
+		p, err := protocol.Lookup(bkt)
+		if err != nil {
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
+		if _, ok := p.(protocol.Presigner); !ok {
+			util.EmitWarningEvent(ctx, n.cosiClient.Recorder(), pod, util.BPresignUnsupported)
+			return nil, status.Error(codes.FailedPrecondition, fmt.Sprintf(util.ErrorTemplatePresignUnsupported, p.Name()))
+		}
+		format = protocol.FormatJSON
+	}
+
+	secret, err := n.cosiClient.GetSecret(ctx, pod, ba)
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	now := n.clock.Now()
+	expiresAt, err := n.secretExpiry(ctx, pod, secret, now)
+	if err != nil {
+		return nil, err
+	}
+
+	var files map[string][]byte
+	if presign != nil {
+		presign.SetExpiry(now, expiresAt)
+		files, err = RenderPresignedFiles(bkt, secret, expiresAt, *presign, now)
+	} else {
+		files, err = RenderFiles(bkt, secret, expiresAt, format)
+	}
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	if audience != "" {
+		tokenFiles, err := webIdentityFiles(request.GetVolumeContext(), audience, bkt.Spec.Protocol.S3 != nil)
+		if err != nil {
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
+		for name, data := range tokenFiles {
+			files[name] = data
+		}
+	}
+	if err := n.checkBucket(ctx, pod, bkt, secretCredentials(secret)); err != nil {
+		return nil, err
+	}
+
+	cleanup := func(err error, errWrap string) (*csi.NodePublishVolumeResponse, error) {
+		if rmErr := n.provisioner.removeDir(volID); rmErr != nil {
+			logging.FromContext(ctx).Error(rmErr, "failed to remove volume directory after error")
+			return nil, status.Error(codes.Internal, errors.Wrap(errors.Wrap(rmErr, util.WrapErrorFailedRemoveDirectory), errWrap).Error())
+		}
+		return nil, status.Error(codes.Internal, errors.Wrap(err, errWrap).Error())
+	}
+
+	if err := n.provisioner.pclient.MkdirAll(n.provisioner.bucketPath(volID), 0750); err != nil {
+		return cleanup(err, util.WrapErrorFailedToCreateVolumeDir)
+	}
+	checksums := map[string]string{}
+	for name, data := range files {
+		if err := n.provisioner.pclient.WriteFile(data, filepath.Join(n.provisioner.bucketPath(volID), name)); err != nil {
+			return cleanup(errors.Wrap(err, util.WrapErrorFailedToCreateBucketFile), util.WrapErrorFailedToWriteCredentials)
+		}
+		checksums[name] = util.Checksum(data)
+	}
+
+	if err := n.provisioner.mountDir(n.provisioner.bucketPath(volID), request.GetTargetPath()); err != nil {
+		return cleanup(err, util.WrapErrorFailedToMountVolume)
+	}
+
+	meta := Metadata{
+		Version:               metadataVersion,
+		VolumeID:              volID,
+		BaName:                ba.Name,
+		PodName:               request.GetVolumeContext()[client.PodNameKey],
+		PodNamespace:          request.GetVolumeContext()[client.PodNamespaceKey],
+		PodUID:                podUID(pod),
+		ServiceAccount:        serviceAccountName(pod),
+		TargetPath:            request.GetTargetPath(),
+		BucketName:            bkt.Name,
+		Format:                format,
+		SecretResourceVersion: secret.ResourceVersion,
+		Files:                 checksums,
+		ExpiresAt:             expiresAt,
+		Presign:               presign,
+		TokenAudience:         audience,
+		CreatedAt:             &now,
+		UpdatedAt:             now,
+	}
+
+	if _, err := n.cosiClient.UpdateBAFinalizers(ctx, ba.Name, n.finalizers(volID, meta), nil); err != nil {
+		return cleanup(err, util.WrapErrorFailedToAddFinalizer)
+	}
+	if err := n.provisioner.writeMetadata(volID, meta); err != nil {
+		return cleanup(err, util.WrapErrorFailedToWriteMetadata)
+	}
+	if err := n.auditPublish(volID, meta); err != nil {
+		return cleanup(err, util.WrapErrorFailedToRecordAudit)
+	}
+
+	util.EmitNormalEvent(ctx, n.cosiClient.Recorder(), pod, util.SuccessfullyPublishedVolume)
+	util.EmitNormalEvent(ctx, n.cosiClient.Recorder(), ba, util.SuccessfullyPublishedVolume)
+	return &csi.NodePublishVolumeResponse{}, nil
+}
+
+// writeStagedMetadata writes the metadata of a volume using the stage, recording the files the stage
+// holds by now. The stage may have been restaged since the volume was counted as its user.
+func (n *NodeServer) writeStagedMetadata(volID string, meta Metadata) (Metadata, error) {
+	unlock := n.stages.locks.lock(meta.StageID)
+	defer unlock()
+
+	if st, err := n.provisioner.readStage(meta.StageID); err == nil {
+		meta.SecretResourceVersion, meta.Files, meta.ExpiresAt = st.SecretResourceVersion, st.Files, st.ExpiresAt
+	}
+	return meta, n.provisioner.writeMetadata(volID, meta)
+}
+
+// wipeStage removes the credentials from the stage. The stage is invalidated along with them, so
+// that once access is granted again the credentials are staged again rather than volumes mounting a
+// stage without them.
+func (n *NodeServer) wipeStage(id string, files map[string]string) error {
+	unlock := n.stages.locks.lock(id)
+	defer unlock()
+
+	if err := n.provisioner.wipeCredentials(n.provisioner.stagedBucketPath(id), files); err != nil {
+		return err
+	}
+	st, err := n.provisioner.readStage(id)
+	if err != nil {
+		// a stage which cannot be read is staged again anyway
+		return nil
+	}
+	st.Version = 0
+	return errors.Wrap(n.provisioner.writeStage(id, st), util.WrapErrorFailedToWriteStage)
+}
+
+// releaseStage stops counting the volume as a user of the stage and removes the stage once no
+// volume uses it anymore.
+func (n *NodeServer) releaseStage(ctx context.Context, id, volID string) error {
+	if id == "" {
+		return nil
+	}
+
+	unlock := n.stages.locks.lock(id)
+	defer unlock()
+	n.stages.release(id, volID)
+	return n.removeUnusedStage(ctx, id)
+}
+
+// removeUnusedStage removes the stage unless a volume uses it. The caller holds the stage lock.
+func (n *NodeServer) removeUnusedStage(ctx context.Context, id string) error {
+	if len(n.stages.volumes(id)) > 0 {
+		return nil
+	}
+
+	logging.FromContext(ctx).Info("removing staged credentials, the last volume using them is gone", "stageID", id)
+	return n.provisioner.pclient.RemoveAll(n.provisioner.stagePath(id))
+}
+
+func (n *NodeServer) removeUnusedStageAfterError(ctx context.Context, id string, err error) error {
+	if rmErr := n.removeUnusedStage(ctx, id); rmErr != nil {
+		logging.FromContext(ctx).Error(rmErr, "failed to remove staged credentials after error", "stageID", id)
+	}
+	return status.Error(codes.Internal, err.Error())
+}
@@ -0,0 +1,334 @@
+package node
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/google/go-cmp/cmp"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/mount-utils"
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client/fake"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/protocol"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+	testutils "sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util/test"
+)
+
+// memFS is an in-memory data root, holding files by path. Directories exist implicitly.
+type memFS map[string][]byte
+
+func (m memFS) client() *fake.MockProvisionerClient {
+	return &fake.MockProvisionerClient{
+		MockMkdirAll: func(path string, perm os.FileMode) error {
+			return nil
+		},
+		MockReadFile: func(filename string) ([]byte, error) {
+			data, ok := m[filename]
+			if !ok {
+				return nil, os.ErrNotExist
+			}
+			return data, nil
+		},
+		MockWriteFile: func(data []byte, filepath string) error {
+			m[filepath] = data
+			return nil
+		},
+		MockRename: func(oldpath, newpath string) error {
+			m[newpath] = m[oldpath]
+			delete(m, oldpath)
+			return nil
+		},
+		MockRemoveAll: func(path string) error {
+			for name := range m {
+				if name == path || strings.HasPrefix(name, path+"/") {
+					delete(m, name)
+				}
+			}
+			return nil
+		},
+		MockReadDir: func(dirname string) ([]os.FileInfo, error) {
+			seen := map[string]bool{}
+			var entries []os.FileInfo
+			for name := range m {
+				rel, err := filepath.Rel(dirname, name)
+				if err != nil || !strings.Contains(rel, "/") || strings.HasPrefix(rel, "../") {
+					continue
+				}
+				dir := strings.SplitN(rel, "/", 2)[0]
+				if !seen[dir] {
+					seen[dir] = true
+					entries = append(entries, fakeDirEntry{name: dir})
+				}
+			}
+			return entries, nil
+		},
+	}
+}
+
+// stageFiles returns the files under the stage directory, relative to it.
+func (m memFS) stageFiles() []string {
+	var files []string
+	for name := range m {
+		if strings.HasPrefix(name, filepath.Join("/data", stagedDirName)+"/") {
+			files = append(files, strings.TrimPrefix(name, filepath.Join("/data", stagedDirName)+"/"))
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+func TestSharedStage(t *testing.T) {
+	fs := memFS{}
+	mounter := mount.NewFakeMounter([]mount.MountPoint{})
+	recorder := record.NewFakeRecorder(20)
+	generation, secretVersion, secretReads := int64(1), "1", 0
+
+	ns := &NodeServer{
+		name:        name,
+		nodeID:      nodeId,
+		volumeLimit: volLimit,
+		cosiClient: &fake.FakeNodeClient{
+			MockGetResources: func(ctx context.Context, barName, barNs, podName, podNs string) (*v1alpha1.Bucket, *v1alpha1.BucketAccess, *v1.Pod, error) {
+				ba := testutils.GetBA()
+				ba.Generation = generation
+				return testutils.GetB(), ba, testutils.GetPod(), nil
+			},
+			MockGetSecret: func(ctx context.Context, pod *v1.Pod, ba *v1alpha1.BucketAccess) (*v1.Secret, error) {
+				secretReads++
+				secret := testutils.GetSecret()
+				secret.ResourceVersion = secretVersion
+				return secret, nil
+			},
+			MockGetPod: func(ctx context.Context, podName, podNs string) (*v1.Pod, error) {
+				return testutils.GetPod(), nil
+			},
+			MockUpdateBAFinalizers: func(ctx context.Context, baName string, add map[string]client.FinalizerOwner, remove []string) (*v1alpha1.BucketAccess, error) {
+				return testutils.GetBA(), nil
+			},
+			MockRecorder: recorder,
+		},
+		provisioner: NewProvisioner("/data", mounter, fs.client()),
+		clock:       clock.NewFakeClock(testNow),
+	}
+
+	publish := func(volID string) {
+		_, err := ns.NodePublishVolume(ctx, &csi.NodePublishVolumeRequest{
+			VolumeContext: map[string]string{
+				client.BarNameKey:      testutils.GetBAR().Name,
+				client.PodNameKey:      podName,
+				client.PodNamespaceKey: testutils.Namespace,
+			},
+			VolumeId:   volID,
+			TargetPath: "/pods/" + volID,
+		})
+		if err != nil {
+			t.Fatalf("failed to publish %s: %v", volID, err)
+		}
+	}
+	unpublish := func(volID string) {
+		_, err := ns.NodeUnpublishVolume(ctx, &csi.NodeUnpublishVolumeRequest{
+			VolumeId:   volID,
+			TargetPath: "/pods/" + volID,
+		})
+		if err != nil {
+			t.Fatalf("failed to unpublish %s: %v", volID, err)
+		}
+	}
+
+	// stagings returns the number of times credentials were staged since the last call.
+	stagings := func() int {
+		count := 0
+		for _, e := range drain(recorder) {
+			if strings.HasPrefix(e, "Normal "+util.WritingCredentials+" ") {
+				count++
+			}
+		}
+		return count
+	}
+
+	publish("vol-1")
+	publish("vol-2")
+
+	if diff := cmp.Diff(1, stagings()); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+	// the secret is only read to stage the credentials
+	if diff := cmp.Diff(1, secretReads); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+	for _, mp := range mounter.MountPoints {
+		if diff := cmp.Diff(ns.provisioner.stagedBucketPath(testutils.GetBA().Name), mp.Device); diff != "" {
+			t.Errorf("r: -want, +got:\n%s", diff)
+		}
+	}
+	want := []string{
+		"bucketAccessName/bucket/credentials",
+		"bucketAccessName/bucket/protocolConn.json",
+		"bucketAccessName/stage.json",
+	}
+	if diff := cmp.Diff(want, fs.stageFiles()); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+
+	// the updated bucket access is staged again for every volume using the stage
+	generation, secretVersion = 2, "2"
+	publish("vol-3")
+	if diff := cmp.Diff(1, stagings()); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+	meta, err := ns.provisioner.readMetadata("vol-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(secretVersion, meta.SecretResourceVersion); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+
+	unpublish("vol-3")
+	unpublish("vol-1")
+	if diff := cmp.Diff(want, fs.stageFiles()); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+
+	unpublish("vol-2")
+	if diff := cmp.Diff([]string(nil), fs.stageFiles()); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+}
+
+func TestStageID(t *testing.T) {
+	cases := map[string]struct {
+		format string
+		want   string
+	}{
+		"JSON": {
+			format: protocol.FormatJSON,
+			want:   "bucketAccessName",
+		},
+		"SDK": {
+			format: protocol.FormatSDK,
+			want:   "bucketAccessName_sdk",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, stageID(testutils.GetBA(), tc.format)); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWipedStage(t *testing.T) {
+	fs := memFS{}
+	ns := &NodeServer{
+		name:        name,
+		nodeID:      nodeId,
+		volumeLimit: volLimit,
+		cosiClient: &fake.FakeNodeClient{
+			MockGetResources: func(ctx context.Context, barName, barNs, podName, podNs string) (*v1alpha1.Bucket, *v1alpha1.BucketAccess, *v1.Pod, error) {
+				return testutils.GetB(), testutils.GetBA(), testutils.GetPod(), nil
+			},
+			MockGetSecret: func(ctx context.Context, pod *v1.Pod, ba *v1alpha1.BucketAccess) (*v1.Secret, error) {
+				return testutils.GetSecret(), nil
+			},
+			MockGetPod: func(ctx context.Context, podName, podNs string) (*v1.Pod, error) {
+				return testutils.GetPod(), nil
+			},
+			MockUpdateBAFinalizers: func(ctx context.Context, baName string, add map[string]client.FinalizerOwner, remove []string) (*v1alpha1.BucketAccess, error) {
+				return testutils.GetBA(), nil
+			},
+			// discards events
+			MockRecorder: &record.FakeRecorder{},
+		},
+		provisioner:      NewProvisioner("/data", mount.NewFakeMounter([]mount.MountPoint{}), fs.client()),
+		revocationPolicy: RevocationPolicyWipe,
+		clock:            clock.NewFakeClock(testNow),
+	}
+
+	publish := func(volID string) {
+		_, err := ns.NodePublishVolume(ctx, &csi.NodePublishVolumeRequest{
+			VolumeContext: map[string]string{
+				client.BarNameKey:      testutils.GetBAR().Name,
+				client.PodNameKey:      podName,
+				client.PodNamespaceKey: testutils.Namespace,
+			},
+			VolumeId:   volID,
+			TargetPath: "/pods/" + volID,
+		})
+		if err != nil {
+			t.Fatalf("failed to publish %s: %v", volID, err)
+		}
+	}
+	credentials := filepath.Join(ns.provisioner.stagedBucketPath(testutils.GetBA().Name), credsFileName)
+
+	publish("vol-1")
+	if err := ns.revoke(ctx, testutils.GetBA().Name, util.BAAccessRevoked, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fs[credentials]; ok {
+		t.Errorf("expected the staged credentials to be wiped")
+	}
+
+	// access is granted again, the next volume must not mount the wiped stage
+	publish("vol-2")
+	if _, ok := fs[credentials]; !ok {
+		t.Fatalf("expected the credentials to be staged again")
+	}
+	meta, err := ns.provisioner.readMetadata("vol-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(util.Checksum(fs[credentials]), meta.Files[credsFileName]); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+}
+
+func TestTrackStages(t *testing.T) {
+	fs := memFS{}
+	ns := &NodeServer{
+		provisioner: NewProvisioner("/data", mount.NewFakeMounter([]mount.MountPoint{}), fs.client()),
+	}
+	if err := ns.provisioner.writeMetadata("vol-1", Metadata{StageID: "used"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"used", "orphan"} {
+		if err := ns.provisioner.writeStage(id, Stage{Version: metadataVersion}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ns.trackStages(ctx)
+
+	if diff := cmp.Diff([]string{"used/stage.json"}, fs.stageFiles()); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"vol-1"}, ns.stages.volumes("used")); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+}
+
+func TestKeyedMutex(t *testing.T) {
+	var k keyedMutex
+	unlockA := k.lock("a")
+	unlockB := k.lock("b")
+	if diff := cmp.Diff(2, len(k.locks)); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+
+	unlockA()
+	unlockB()
+	if diff := cmp.Diff(0, len(k.locks)); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+}
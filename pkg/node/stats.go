@@ -0,0 +1,117 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/logging"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+func (n *NodeServer) NodeGetVolumeStats(ctx context.Context, request *csi.NodeGetVolumeStatsRequest) (*csi.NodeGetVolumeStatsResponse, error) {
+	logging.FromContext(ctx).V(4).Info("NodeGetVolumeStats", "volumeID", request.GetVolumeId(), "volumePath", request.GetVolumePath())
+
+	if request.GetVolumeId() == "" {
+		return nil, status.Error(codes.InvalidArgument, util.ErrorVolumeIDUnset.Error())
+	}
+	if request.GetVolumePath() == "" {
+		return nil, status.Error(codes.InvalidArgument, util.ErrorVolumePathUnset.Error())
+	}
+
+	volID := volumeDirID(request.GetVolumeId(), request.GetVolumePath())
+	meta, err := n.provisioner.readMetadata(volID)
+	if err != nil {
+		if os.IsNotExist(errors.Cause(err)) {
+			return nil, status.Errorf(codes.NotFound, util.ErrorTemplateVolumeNotFound, request.GetVolumeId())
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	usage, err := n.provisioner.volumeUsage(n.provisioner.filesPath(volID, meta))
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &csi.NodeGetVolumeStatsResponse{
+		Usage:           usage,
+		VolumeCondition: n.volumeCondition(ctx, volID, request.GetVolumePath(), meta),
+	}, nil
+}
+
+// volumeCondition reports the volume as abnormal when the bind mount has disappeared, the published
+// files no longer match what was written, or the BucketAccess backing the credentials is gone.
+func (n *NodeServer) volumeCondition(ctx context.Context, volID, volumePath string, meta Metadata) *csi.VolumeCondition {
+	logger := logging.FromContext(ctx)
+	var problems []string
+
+	mounted, err := n.provisioner.isMounted(volumePath)
+	if err != nil {
+		logger.Error(err, "failed to check mount point", "volumePath", volumePath)
+	}
+	if err == nil && !mounted {
+		problems = append(problems, fmt.Sprintf(util.ConditionTemplateMountMissing, volumePath))
+	}
+
+	problems = append(problems, n.provisioner.tamperedFiles(n.provisioner.filesPath(volID, meta), meta.Files)...)
+
+	if meta.BrName != "" {
+		// bucket information is not granted through a bucketAccess
+		return condition(problems)
+	}
+
+	ba, err := n.cosiClient.GetCachedBA(meta.BaName)
+	switch {
+	case kerrors.IsNotFound(err):
+		problems = append(problems, fmt.Sprintf(util.ConditionTemplateBADeleted, meta.BaName))
+	case err != nil:
+		logger.Error(err, "failed to look up bucketAccess", "bucketAccess", meta.BaName)
+	case ba.DeletionTimestamp != nil:
+		problems = append(problems, fmt.Sprintf(util.ConditionTemplateBADeleting, meta.BaName))
+	case !ba.Status.AccessGranted:
+		problems = append(problems, fmt.Sprintf(util.ConditionTemplateBARevoked, meta.BaName))
+	}
+
+	return condition(problems)
+}
+
+func condition(problems []string) *csi.VolumeCondition {
+	if len(problems) == 0 {
+		return &csi.VolumeCondition{Abnormal: false, Message: "volume is healthy"}
+	}
+	return &csi.VolumeCondition{Abnormal: true, Message: strings.Join(problems, "; ")}
+}
+
+func (p Provisioner) volumeUsage(dir string) ([]*csi.VolumeUsage, error) {
+	used, inodesUsed, err := p.pclient.DirUsage(dir)
+	if err != nil {
+		return nil, errors.Wrap(err, util.WrapErrorFailedToGetVolumeUsage)
+	}
+
+	fs, err := p.pclient.FsStats(dir)
+	if err != nil {
+		return nil, errors.Wrap(err, util.WrapErrorFailedToGetFsStats)
+	}
+
+	return []*csi.VolumeUsage{
+		{
+			Unit:      csi.VolumeUsage_BYTES,
+			Total:     fs.CapacityBytes,
+			Available: fs.AvailableBytes,
+			Used:      used,
+		},
+		{
+			Unit:      csi.VolumeUsage_INODES,
+			Total:     fs.Inodes,
+			Available: fs.InodesFree,
+			Used:      inodesUsed,
+		},
+	}, nil
+}
@@ -0,0 +1,234 @@
+package node
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc/codes"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/mount-utils"
+
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client/fake"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+	testutils "sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util/test"
+)
+
+func TestNodeGetVolumeStats(t *testing.T) {
+	// the fake mounter stats the volume path, so it has to exist
+	mountedPath := os.TempDir()
+	creds := []byte("credentials")
+
+	statsProvisionerClient := func(files map[string][]byte) *fake.MockProvisionerClient {
+		return &fake.MockProvisionerClient{
+			MockReadFile: func(filename string) ([]byte, error) {
+				if filepath.Base(filename) == metadataFilename {
+					return json.Marshal(Metadata{
+						BaName:       testutils.GetBA().Name,
+						PodName:      podName,
+						PodNamespace: testutils.Namespace,
+						Files: map[string]string{
+							credsFileName: util.Checksum(creds),
+						},
+					})
+				}
+				if data, ok := files[filepath.Base(filename)]; ok {
+					return data, nil
+				}
+				return nil, os.ErrNotExist
+			},
+			MockDirUsage: func(path string) (bytes, inodes int64, err error) {
+				return 11, 3, nil
+			},
+			MockFsStats: func(path string) (client.FsStats, error) {
+				return client.FsStats{CapacityBytes: 100, AvailableBytes: 50, Inodes: 10, InodesFree: 5}, nil
+			},
+		}
+	}
+
+	usage := []*csi.VolumeUsage{
+		{Unit: csi.VolumeUsage_BYTES, Total: 100, Available: 50, Used: 11},
+		{Unit: csi.VolumeUsage_INODES, Total: 10, Available: 5, Used: 3},
+	}
+
+	type args struct {
+		nclient     *fake.FakeNodeClient
+		provisioner Provisioner
+		request     *csi.NodeGetVolumeStatsRequest
+	}
+
+	type want struct {
+		response *csi.NodeGetVolumeStatsResponse
+		err      error
+	}
+
+	cases := map[string]struct {
+		args
+		want
+	}{
+		"Healthy": {
+			args: args{
+				provisioner: getTestProvisioner(
+					statsProvisionerClient(map[string][]byte{credsFileName: creds}),
+					withMountPoints([]mount.MountPoint{{Path: mountedPath}}),
+				),
+				nclient: &fake.FakeNodeClient{
+					MockGetCachedBA: func(baName string) (*v1alpha1.BucketAccess, error) {
+						return testutils.GetBA(), nil
+					},
+				},
+				request: &csi.NodeGetVolumeStatsRequest{
+					VolumeId:   provVolumeId,
+					VolumePath: mountedPath,
+				},
+			},
+			want: want{
+				response: &csi.NodeGetVolumeStatsResponse{
+					Usage:           usage,
+					VolumeCondition: &csi.VolumeCondition{Abnormal: false, Message: "volume is healthy"},
+				},
+			},
+		},
+		"MountMissingAndTampered": {
+			args: args{
+				provisioner: getTestProvisioner(
+					statsProvisionerClient(map[string][]byte{credsFileName: []byte("changed")}),
+				),
+				nclient: &fake.FakeNodeClient{
+					MockGetCachedBA: func(baName string) (*v1alpha1.BucketAccess, error) {
+						return testutils.GetBA(), nil
+					},
+				},
+				request: &csi.NodeGetVolumeStatsRequest{
+					VolumeId:   provVolumeId,
+					VolumePath: provTargetPath,
+				},
+			},
+			want: want{
+				response: &csi.NodeGetVolumeStatsResponse{
+					Usage: usage,
+					VolumeCondition: &csi.VolumeCondition{
+						Abnormal: true,
+						Message: fmt.Sprintf(util.ConditionTemplateMountMissing, provTargetPath) + "; " +
+							fmt.Sprintf(util.ConditionTemplateFileModified, credsFileName),
+					},
+				},
+			},
+		},
+		"BARevoked": {
+			args: args{
+				provisioner: getTestProvisioner(
+					statsProvisionerClient(map[string][]byte{credsFileName: creds}),
+					withMountPoints([]mount.MountPoint{{Path: mountedPath}}),
+				),
+				nclient: &fake.FakeNodeClient{
+					MockGetCachedBA: func(baName string) (*v1alpha1.BucketAccess, error) {
+						ba := testutils.GetBA()
+						ba.Status.AccessGranted = false
+						return ba, nil
+					},
+				},
+				request: &csi.NodeGetVolumeStatsRequest{
+					VolumeId:   provVolumeId,
+					VolumePath: mountedPath,
+				},
+			},
+			want: want{
+				response: &csi.NodeGetVolumeStatsResponse{
+					Usage: usage,
+					VolumeCondition: &csi.VolumeCondition{
+						Abnormal: true,
+						Message:  fmt.Sprintf(util.ConditionTemplateBARevoked, testutils.GetBA().Name),
+					},
+				},
+			},
+		},
+		"BADeleted": {
+			args: args{
+				provisioner: getTestProvisioner(
+					statsProvisionerClient(map[string][]byte{}),
+					withMountPoints([]mount.MountPoint{{Path: mountedPath}}),
+				),
+				nclient: &fake.FakeNodeClient{
+					MockGetCachedBA: func(baName string) (*v1alpha1.BucketAccess, error) {
+						return nil, kerrors.NewNotFound(schema.GroupResource{Resource: "bucketaccesses"}, baName)
+					},
+				},
+				request: &csi.NodeGetVolumeStatsRequest{
+					VolumeId:   provVolumeId,
+					VolumePath: mountedPath,
+				},
+			},
+			want: want{
+				response: &csi.NodeGetVolumeStatsResponse{
+					Usage: usage,
+					VolumeCondition: &csi.VolumeCondition{
+						Abnormal: true,
+						Message: fmt.Sprintf(util.ConditionTemplateFileMissing, credsFileName) + "; " +
+							fmt.Sprintf(util.ConditionTemplateBADeleted, testutils.GetBA().Name),
+					},
+				},
+			},
+		},
+		"VolumeNotFound": {
+			args: args{
+				provisioner: getTestProvisioner(
+					&fake.MockProvisionerClient{
+						MockReadFile: func(filename string) ([]byte, error) {
+							return nil, os.ErrNotExist
+						},
+					},
+				),
+				nclient: &fake.FakeNodeClient{},
+				request: &csi.NodeGetVolumeStatsRequest{
+					VolumeId:   provVolumeId,
+					VolumePath: mountedPath,
+				},
+			},
+			want: want{
+				err: genRPCError(codes.NotFound, fmt.Errorf(util.ErrorTemplateVolumeNotFound, provVolumeId)),
+			},
+		},
+		"VolumePathUnset": {
+			args: args{
+				provisioner: getTestProvisioner(&fake.MockProvisionerClient{}),
+				nclient:     &fake.FakeNodeClient{},
+				request: &csi.NodeGetVolumeStatsRequest{
+					VolumeId: provVolumeId,
+				},
+			},
+			want: want{
+				err: genRPCError(codes.InvalidArgument, util.ErrorVolumePathUnset),
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			ns := &NodeServer{
+				name:        name,
+				nodeID:      nodeId,
+				cosiClient:  tc.nclient,
+				provisioner: tc.provisioner,
+				volumeLimit: volLimit,
+			}
+
+			response, err := ns.NodeGetVolumeStats(ctx, tc.request)
+
+			if diff := cmp.Diff(tc.want.response, response); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+
+			if diff := cmp.Diff(tc.want.err, err, util.EquateErrors()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/logging"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+const (
+	// ServiceAccountTokenFileName holds the service account token of the pod in volumes requesting
+	// one, for workload identity federation.
+	ServiceAccountTokenFileName = "token"
+	// AWSConfigFileName holds the AWS config profile exchanging the token for the role of the
+	// volume, for apps pointing AWS_CONFIG_FILE at it.
+	AWSConfigFileName = "aws-config"
+)
+
+// webIdentityFiles returns the files of a volume with workload identity, keyed by file name: the
+// token of the pod for the audience and, when withConfig is set and the volume names a role and the
+// path it is mounted at, the AWS config naming both.
+func webIdentityFiles(volCtx map[string]string, audience string, withConfig bool) (map[string][]byte, error) {
+	_, roleARN, err := client.ParseWebIdentity(volCtx)
+	if err != nil {
+		return nil, err
+	}
+	token, err := client.ServiceAccountToken(volCtx, audience)
+	if err != nil {
+		return nil, err
+	}
+
+	files := map[string][]byte{ServiceAccountTokenFileName: []byte(token)}
+	mountPath := volCtx[client.MountPathKey]
+	if withConfig && roleARN != "" && mountPath != "" {
+		files[AWSConfigFileName] = []byte(fmt.Sprintf("[default]\nrole_arn = %s\nweb_identity_token_file = %s\n",
+			roleARN, path.Join(mountPath, ServiceAccountTokenFileName)))
+	}
+	return files, nil
+}
+
+// republish handles NodePublishVolume for a volume which is published already. The kubelet
+// publishes mounted volumes again when the CSIDriver requires republishing, to pass fresh service
+// account tokens, and after the node restarted. Nothing but the token is written again.
+func (n *NodeServer) republish(ctx context.Context, volID string, meta Metadata, request *csi.NodePublishVolumeRequest) (*csi.NodePublishVolumeResponse, error) {
+	mounted, err := n.provisioner.isMounted(request.GetTargetPath())
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if !mounted {
+		logging.FromContext(ctx).Info("mounting published volume again", "targetPath", request.GetTargetPath())
+		if err := n.provisioner.mountDir(n.provisioner.filesPath(volID, meta), request.GetTargetPath()); err != nil {
+			return nil, status.Error(codes.Internal, errors.Wrap(err, util.WrapErrorFailedToMountVolume).Error())
+		}
+	}
+
+	if meta.TokenAudience == "" {
+		return &csi.NodePublishVolumeResponse{}, nil
+	}
+	// only volumes written with an AWS config get it again, their bucket is known to use S3
+	_, withConfig := meta.Files[AWSConfigFileName]
+	files, err := webIdentityFiles(request.GetVolumeContext(), meta.TokenAudience, withConfig)
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	// held against the volume being unpublished or its presigned URLs refreshed meanwhile
+	unlock := n.volumeLocks.lock(volID)
+	defer unlock()
+	if meta, err = n.provisioner.readMetadata(volID); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	changed := false
+	for name, data := range files {
+		checksum := util.Checksum(data)
+		if meta.Files[name] == checksum {
+			continue
+		}
+		if err := n.provisioner.writeFileAtomic(n.provisioner.bucketPath(volID), name, data); err != nil {
+			return nil, status.Error(codes.Internal, errors.Wrap(err, util.WrapErrorFailedToWriteToken).Error())
+		}
+		meta.Files[name] = checksum
+		changed = true
+	}
+	if !changed {
+		return &csi.NodePublishVolumeResponse{}, nil
+	}
+
+	meta.UpdatedAt = n.clock.Now()
+	if err := n.provisioner.writeMetadata(volID, meta); err != nil {
+		return nil, status.Error(codes.Internal, errors.Wrap(err, util.WrapErrorFailedToWriteMetadata).Error())
+	}
+	logging.FromContext(ctx).Info("refreshed service account token", "audience", meta.TokenAudience)
+	return &csi.NodePublishVolumeResponse{}, nil
+}
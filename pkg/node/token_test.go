@@ -0,0 +1,174 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc/codes"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/mount-utils"
+
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client/fake"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+	testutils "sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util/test"
+)
+
+func TestServiceAccountTokenRepublish(t *testing.T) {
+	fs := memFS{}
+	mounter := mount.NewFakeMounter(nil)
+	bucketInfoReads := 0
+	ns := &NodeServer{
+		name:        name,
+		nodeID:      nodeId,
+		volumeLimit: volLimit,
+		cosiClient: &fake.FakeNodeClient{
+			MockGetBucketInfo: func(ctx context.Context, brName, podName, podNs string) (*v1alpha1.Bucket, *v1.Pod, error) {
+				bucketInfoReads++
+				return testutils.GetB(), testutils.GetPod(), nil
+			},
+			MockRecorder: &record.FakeRecorder{},
+		},
+		provisioner: NewProvisioner("/data", mounter, fs.client()),
+		clock:       clock.NewFakeClock(testNow),
+	}
+
+	targetPath := t.TempDir()
+	publish := func(tokens string) error {
+		volCtx := map[string]string{
+			client.BrNameKey:        testutils.GetBR().Name,
+			client.PodNameKey:       podName,
+			client.PodNamespaceKey:  testutils.Namespace,
+			client.TokenAudienceKey: "sts.amazonaws.com",
+		}
+		if tokens != "" {
+			volCtx[client.ServiceAccountTokensKey] = tokens
+		}
+		_, err := ns.NodePublishVolume(ctx, &csi.NodePublishVolumeRequest{
+			VolumeContext: volCtx,
+			VolumeId:      provVolumeId,
+			TargetPath:    targetPath,
+		})
+		return err
+	}
+	tokenFile := filepath.Join("/data", provVolumeId, "bucket", ServiceAccountTokenFileName)
+
+	if err := publish(`{"sts.amazonaws.com":{"token":"first","expirationTimestamp":"2021-01-01T13:00:00Z"}}`); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff("first", string(fs[tokenFile])); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+
+	// the kubelet republishes with a fresh token
+	if err := publish(`{"sts.amazonaws.com":{"token":"second","expirationTimestamp":"2021-01-01T14:00:00Z"}}`); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff("second", string(fs[tokenFile])); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+	meta, err := ns.provisioner.readMetadata(provVolumeId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(util.Checksum([]byte("second")), meta.Files[ServiceAccountTokenFileName]); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+
+	// nothing but the token is refreshed
+	if bucketInfoReads != 1 {
+		t.Errorf("expected the bucket information to be read once, got %d reads", bucketInfoReads)
+	}
+	if len(mounter.MountPoints) != 1 {
+		t.Errorf("expected the volume to be mounted once, got %v", mounter.MountPoints)
+	}
+
+	err = publish("")
+	want := genRPCError(codes.FailedPrecondition, fmt.Errorf(util.ErrorTemplateTokenNotRequested, "sts.amazonaws.com"))
+	if diff := cmp.Diff(want, err, util.EquateErrors()); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+}
+
+func TestWebIdentityBucketAccess(t *testing.T) {
+	fs := memFS{}
+	ns := &NodeServer{
+		name:        name,
+		nodeID:      nodeId,
+		volumeLimit: volLimit,
+		cosiClient: &fake.FakeNodeClient{
+			MockGetResources: func(ctx context.Context, barName, barNs, podName, podNs string) (*v1alpha1.Bucket, *v1alpha1.BucketAccess, *v1.Pod, error) {
+				return testutils.GetB(), testutils.GetBA(), testutils.GetPod(), nil
+			},
+			MockGetSecret: func(ctx context.Context, pod *v1.Pod, ba *v1alpha1.BucketAccess) (*v1.Secret, error) {
+				return testutils.GetSecret(), nil
+			},
+			MockUpdateBAFinalizers: func(ctx context.Context, baName string, add map[string]client.FinalizerOwner, remove []string) (*v1alpha1.BucketAccess, error) {
+				return testutils.GetBA(), nil
+			},
+			MockRecorder: &record.FakeRecorder{},
+		},
+		provisioner: NewProvisioner("/data", mount.NewFakeMounter(nil), fs.client()),
+		clock:       clock.NewFakeClock(testNow),
+	}
+
+	targetPath := t.TempDir()
+	publish := func(token string) {
+		_, err := ns.NodePublishVolume(ctx, &csi.NodePublishVolumeRequest{
+			VolumeContext: map[string]string{
+				client.BarNameKey:              testutils.GetBAR().Name,
+				client.PodNameKey:              podName,
+				client.PodNamespaceKey:         testutils.Namespace,
+				client.TokenAudienceKey:        "sts.amazonaws.com",
+				client.RoleARNKey:              "arn:aws:iam::123456789012:role/reader",
+				client.MountPathKey:            "/cosi",
+				client.ServiceAccountTokensKey: `{"sts.amazonaws.com":{"token":"` + token + `"}}`,
+			},
+			VolumeId:   provVolumeId,
+			TargetPath: targetPath,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	bucketPath := filepath.Join("/data", provVolumeId, "bucket")
+
+	publish("first")
+	// the token is the pod's own, the credentials are written to the volume rather than staged
+	if diff := cmp.Diff([]string(nil), fs.stageFiles()); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+	if _, ok := fs[filepath.Join(bucketPath, credsFileName)]; !ok {
+		t.Errorf("expected the credentials to be written to the volume")
+	}
+	wantConfig := "[default]\nrole_arn = arn:aws:iam::123456789012:role/reader\nweb_identity_token_file = /cosi/token\n"
+	if diff := cmp.Diff(wantConfig, string(fs[filepath.Join(bucketPath, AWSConfigFileName)])); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+
+	publish("second")
+	if diff := cmp.Diff("second", string(fs[filepath.Join(bucketPath, ServiceAccountTokenFileName)])); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+	meta, err := ns.provisioner.readMetadata(provVolumeId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		ServiceAccountTokenFileName: util.Checksum([]byte("second")),
+		AWSConfigFileName:           util.Checksum([]byte(wantConfig)),
+	}
+	for name, checksum := range want {
+		if diff := cmp.Diff(checksum, meta.Files[name]); diff != "" {
+			t.Errorf("%s: -want, +got:\n%s", name, diff)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package protocol
+
+import (
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+// AzureBlob is the protocol of Azure Blob Storage containers.
+type AzureBlob struct{}
+
+func (AzureBlob) Name() string {
+	return "azureBlob"
+}
+
+func (AzureBlob) Matches(bkt *v1alpha1.Bucket) bool {
+	return bkt.Spec.Protocol.AzureBlob != nil
+}
+
+func (p AzureBlob) Validate(bkt *v1alpha1.Bucket) error {
+	azure := bkt.Spec.Protocol.AzureBlob
+	if err := required(p.Name(), "containerName", azure.ContainerName); err != nil {
+		return err
+	}
+	if err := required(p.Name(), "storageAccount", azure.StorageAccount); err != nil {
+		return err
+	}
+	if !storageAccountRe.MatchString(azure.StorageAccount) {
+		return invalid(util.BProtocolFieldInvalid, util.ErrorTemplateProtocolFieldInvalid, p.Name(), "storageAccount", azure.StorageAccount)
+	}
+	return nil
+}
+
+func (AzureBlob) Connection(bkt *v1alpha1.Bucket) ([]byte, error) {
+	return marshal(bkt.Spec.Protocol.AzureBlob)
+}
+
+// Renderers returns no renderers, the Azure SDKs read no credentials file.
+func (AzureBlob) Renderers() map[string]Renderer {
+	return nil
+}
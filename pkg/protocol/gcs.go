@@ -0,0 +1,71 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package protocol
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+const (
+	// GCSCredentialsFileName is a service account key file, rendered for GCS buckets in the sdk
+	// format.
+	GCSCredentialsFileName = "service-account.json"
+
+	// key of the minted secret
+	gcsServiceAccountKey = "serviceAccountKey"
+)
+
+// GCS is the protocol of Google Cloud Storage buckets.
+type GCS struct{}
+
+func (GCS) Name() string {
+	return "gcs"
+}
+
+func (GCS) Matches(bkt *v1alpha1.Bucket) bool {
+	return bkt.Spec.Protocol.GCS != nil
+}
+
+func (p GCS) Validate(bkt *v1alpha1.Bucket) error {
+	gcs := bkt.Spec.Protocol.GCS
+	if err := required(p.Name(), "bucketName", gcs.BucketName); err != nil {
+		return err
+	}
+	return required(p.Name(), "projectID", gcs.ProjectID)
+}
+
+func (GCS) Connection(bkt *v1alpha1.Bucket) ([]byte, error) {
+	return marshal(bkt.Spec.Protocol.GCS)
+}
+
+func (GCS) Renderers() map[string]Renderer {
+	return map[string]Renderer{
+		FormatSDK: func(bkt *v1alpha1.Bucket, secret *v1.Secret) (map[string][]byte, error) {
+			key, ok := secret.Data[gcsServiceAccountKey]
+			if !ok {
+				return nil, fmt.Errorf(util.ErrorTemplateSecretKeyUnset, gcsServiceAccountKey)
+			}
+			return map[string][]byte{GCSCredentialsFileName: key}, nil
+		},
+	}
+}
@@ -0,0 +1,168 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package protocol renders the files of buckets by protocol. Every protocol contributes its
+// validation, the connection document published as protocolConn.json and the renderers of the
+// formats it supports. Supporting another protocol means registering one more implementation.
+package protocol
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+const (
+	// FormatJSON renders protocolConn.json and credentials as JSON documents. Every protocol
+	// supports it.
+	FormatJSON = "json"
+	// FormatSDK additionally renders the credentials into the files the cloud SDKs read natively.
+	FormatSDK = "sdk"
+)
+
+// Protocol is a bucket protocol the adapter can publish.
+type Protocol interface {
+	// Name names the protocol in errors and events.
+	Name() string
+	// Matches reports whether the bucket uses the protocol.
+	Matches(bkt *v1alpha1.Bucket) bool
+	// Validate fails unless the bucket describes a usable bucket of the protocol.
+	Validate(bkt *v1alpha1.Bucket) error
+	// Connection returns the connection document of the bucket, published as protocolConn.json.
+	Connection(bkt *v1alpha1.Bucket) ([]byte, error)
+	// Renderers returns the renderers of the formats the protocol supports besides FormatJSON,
+	// keyed by format.
+	Renderers() map[string]Renderer
+}
+
+// Checker is implemented by protocols which can verify the credentials of a volume against the
+// bucket, with a cheap authenticated call to its endpoint.
+type Checker interface {
+	// Check fails unless the bucket grants access to the credentials, the keys of the minted
+	// secret. It reports unreachable endpoints, denied access and missing buckets apart.
+	Check(ctx context.Context, client *http.Client, bkt *v1alpha1.Bucket, creds map[string]string) error
+}
+
+// Presigner is implemented by protocols which can grant access to single objects of a bucket
+// through presigned URLs, computed locally from the credentials.
+type Presigner interface {
+	// Presign returns a URL allowing requests with the method to the object key, until expires
+	// has passed from now. The credentials are the keys of the minted secret.
+	Presign(bkt *v1alpha1.Bucket, creds map[string]string, method, key string, expires time.Duration, now time.Time) (string, error)
+}
+
+// Renderer renders the files of a format from the minted secret, keyed by file name. They are
+// published next to protocolConn.json and credentials.
+type Renderer func(bkt *v1alpha1.Bucket, secret *v1.Secret) (map[string][]byte, error)
+
+// Registry holds the protocols buckets are matched against, in registration order.
+type Registry struct {
+	mu        sync.RWMutex
+	protocols []Protocol
+}
+
+// NewRegistry returns a registry of the protocols.
+func NewRegistry(protocols ...Protocol) *Registry {
+	return &Registry{protocols: protocols}
+}
+
+// Register adds a protocol, failing if one of the same name is registered already.
+func (r *Registry) Register(p Protocol) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, registered := range r.protocols {
+		if registered.Name() == p.Name() {
+			return fmt.Errorf(util.ErrorTemplateProtocolRegistered, p.Name())
+		}
+	}
+	r.protocols = append(r.protocols, p)
+	return nil
+}
+
+// Lookup returns the first registered protocol matching the bucket, validated against it.
+func (r *Registry) Lookup(bkt *v1alpha1.Bucket) (Protocol, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, p := range r.protocols {
+		if !p.Matches(bkt) {
+			continue
+		}
+		if err := p.Validate(bkt); err != nil {
+			return nil, err
+		}
+		return p, nil
+	}
+	return nil, util.ErrorInvalidProtocol
+}
+
+// Formats returns the sorted formats of the registered protocols, FormatJSON included.
+func (r *Registry) Formats() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	set := map[string]bool{FormatJSON: true}
+	for _, p := range r.protocols {
+		for format := range p.Renderers() {
+			set[format] = true
+		}
+	}
+	formats := make([]string, 0, len(set))
+	for format := range set {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+var defaultRegistry = NewRegistry(S3{}, AzureBlob{}, GCS{})
+
+// Register adds a protocol to the default registry, which holds the S3, AzureBlob and GCS
+// protocols. It is meant to be called from init functions.
+func Register(p Protocol) error {
+	return defaultRegistry.Register(p)
+}
+
+// Lookup returns the protocol of the bucket from the default registry.
+func Lookup(bkt *v1alpha1.Bucket) (Protocol, error) {
+	return defaultRegistry.Lookup(bkt)
+}
+
+// Formats returns the formats of the default registry.
+func Formats() []string {
+	return defaultRegistry.Formats()
+}
+
+// marshal renders a connection document.
+func marshal(connection interface{}) ([]byte, error) {
+	data, err := json.Marshal(connection)
+	if err != nil {
+		return nil, errors.Wrap(err, util.WrapErrorMarshalProtocolFailed)
+	}
+	return data, nil
+}
@@ -0,0 +1,198 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package protocol
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+const (
+	// AWSCredentialsFileName is an AWS shared credentials file, rendered for S3 buckets in the sdk
+	// format.
+	AWSCredentialsFileName = "aws-credentials"
+
+	// defaultS3Region signs requests to buckets without region, S3 compatible stores ignore it.
+	defaultS3Region = "us-east-1"
+
+	// keys of the minted secret
+	s3AccessKeyIDKey     = "accessKeyID"
+	s3SecretAccessKeyKey = "secretAccessKey"
+)
+
+// S3 is the protocol of Amazon S3 and compatible buckets.
+type S3 struct{}
+
+func (S3) Name() string {
+	return "s3"
+}
+
+func (S3) Matches(bkt *v1alpha1.Bucket) bool {
+	return bkt.Spec.Protocol.S3 != nil
+}
+
+func (p S3) Validate(bkt *v1alpha1.Bucket) error {
+	s3 := bkt.Spec.Protocol.S3
+	if err := required(p.Name(), "bucketName", s3.BucketName); err != nil {
+		return err
+	}
+	if err := validateEndpoint(p.Name(), s3.Endpoint); err != nil {
+		return err
+	}
+	if err := validateRegion(p.Name(), s3.Region); err != nil {
+		return err
+	}
+	switch s3.SignatureVersion {
+	case "", v1alpha1.S3SignatureVersionV2, v1alpha1.S3SignatureVersionV4:
+		return nil
+	default:
+		return invalid(util.BSignatureVersionInvalid, util.ErrorTemplateSignatureVersionInvalid, p.Name(), s3.SignatureVersion)
+	}
+}
+
+func (S3) Connection(bkt *v1alpha1.Bucket) ([]byte, error) {
+	return marshal(bkt.Spec.Protocol.S3)
+}
+
+func (S3) Renderers() map[string]Renderer {
+	return map[string]Renderer{
+		FormatSDK: func(bkt *v1alpha1.Bucket, secret *v1.Secret) (map[string][]byte, error) {
+			creds, err := awsCredentials(secret)
+			if err != nil {
+				return nil, err
+			}
+			return map[string][]byte{AWSCredentialsFileName: creds}, nil
+		},
+	}
+}
+
+// Check makes a HEAD bucket request signed with Signature Version 4, which S3 compatible stores
+// accept regardless of the signature version of the bucket.
+func (p S3) Check(ctx context.Context, client *http.Client, bkt *v1alpha1.Bucket, creds map[string]string) error {
+	s3 := bkt.Spec.Protocol.S3
+	if err := s3Keys(creds); err != nil {
+		return err
+	}
+	u, err := p.bucketURL(s3)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, u.String(), nil)
+	if err != nil {
+		return invalid(util.BEndpointInvalid, util.ErrorTemplateEndpointInvalid, p.Name(), s3.Endpoint, err.Error())
+	}
+	req.Header.Set(amzContentHash, emptyPayloadHash)
+	signV4(req, "s3", s3Region(s3), creds[s3AccessKeyIDKey], creds[s3SecretAccessKeyKey], time.Now())
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return invalid(util.BUnreachable, util.ErrorTemplateBucketUnreachable, s3.BucketName, s3.Endpoint, err.Error())
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return invalid(util.BAccessDenied, util.ErrorTemplateBucketAccessDenied, s3.BucketName, resp.StatusCode)
+	case resp.StatusCode == http.StatusNotFound:
+		return invalid(util.BNotFoundAtEndpoint, util.ErrorTemplateBucketNotFoundAtEndpoint, s3.BucketName, s3.Endpoint)
+	default:
+		return invalid(util.BCheckUnexpected, util.ErrorTemplateBucketCheckFailed, s3.BucketName, resp.StatusCode)
+	}
+}
+
+// Presign returns a path-style object URL presigned with Signature Version 4, like Check
+// regardless of the signature version of the bucket.
+func (p S3) Presign(bkt *v1alpha1.Bucket, creds map[string]string, method, key string, expires time.Duration, now time.Time) (string, error) {
+	s3 := bkt.Spec.Protocol.S3
+	if err := s3Keys(creds); err != nil {
+		return "", err
+	}
+	if expires < time.Second || expires > maxPresignExpiry {
+		return "", fmt.Errorf(util.ErrorTemplatePresignExpiryInvalid, expires, maxPresignExpiry)
+	}
+	u, err := p.bucketURL(s3)
+	if err != nil {
+		return "", err
+	}
+	u.Path += "/" + key
+
+	return presignV4(method, u, "s3", s3Region(s3), creds[s3AccessKeyIDKey], creds[s3SecretAccessKeyKey], expires, now), nil
+}
+
+// bucketURL returns the path-style URL of the bucket, which every S3 compatible store serves.
+func (p S3) bucketURL(s3 *v1alpha1.S3Protocol) (*url.URL, error) {
+	endpoint := s3.Endpoint
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, invalid(util.BEndpointInvalid, util.ErrorTemplateEndpointInvalid, p.Name(), s3.Endpoint, err.Error())
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s3.BucketName
+	return u, nil
+}
+
+func s3Region(s3 *v1alpha1.S3Protocol) string {
+	if s3.Region == "" {
+		return defaultS3Region
+	}
+	return s3.Region
+}
+
+// s3Keys fails unless the credentials hold the access key pair.
+func s3Keys(creds map[string]string) error {
+	for _, key := range []string{s3AccessKeyIDKey, s3SecretAccessKeyKey} {
+		if _, ok := creds[key]; !ok {
+			return invalid(util.MintedSecretKeyUnset, util.ErrorTemplateSecretKeyUnset, key)
+		}
+	}
+	return nil
+}
+
+// awsCredentials renders an AWS shared credentials file holding the access key pair as the default
+// profile.
+func awsCredentials(secret *v1.Secret) ([]byte, error) {
+	for _, key := range []string{s3AccessKeyIDKey, s3SecretAccessKeyKey} {
+		if _, ok := secret.Data[key]; !ok {
+			return nil, fmt.Errorf(util.ErrorTemplateSecretKeyUnset, key)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteString("[default]\n")
+	fmt.Fprintf(buf, "aws_access_key_id = %s\n", secret.Data[s3AccessKeyIDKey])
+	fmt.Fprintf(buf, "aws_secret_access_key = %s\n", secret.Data[s3SecretAccessKeyKey])
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,216 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package protocol
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+	testutils "sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util/test"
+)
+
+const (
+	standInAccessKeyID     = "AKIAEXAMPLE"
+	standInSecretAccessKey = "secret"
+)
+
+// s3StandIn serves HEAD bucket requests for the buckets, checking their signature the way S3 does.
+func s3StandIn(t *testing.T, buckets ...string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		signedAt, err := time.Parse(amzDateFormat, r.Header.Get(amzDate))
+		if err != nil {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		want, err := http.NewRequest(r.Method, "http://"+r.Host+r.URL.Path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want.Header.Set(amzContentHash, r.Header.Get(amzContentHash))
+		signV4(want, "s3", "us-east-1", standInAccessKeyID, standInSecretAccessKey, signedAt)
+		if want.Header.Get("Authorization") != r.Header.Get("Authorization") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		for _, bucket := range buckets {
+			if r.URL.Path == "/"+bucket {
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+}
+
+func TestS3Check(t *testing.T) {
+	server := s3StandIn(t, "bucketName")
+	defer server.Close()
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	creds := map[string]string{
+		s3AccessKeyIDKey:     standInAccessKeyID,
+		s3SecretAccessKeyKey: standInSecretAccessKey,
+	}
+
+	type args struct {
+		endpoint string
+		bucket   string
+		creds    map[string]string
+	}
+	type want struct {
+		err   string
+		event util.EventResource
+	}
+
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"Successful": {
+			args: args{endpoint: server.URL, bucket: "bucketName", creds: creds},
+		},
+		"AccessDenied": {
+			args: args{
+				endpoint: server.URL,
+				bucket:   "bucketName",
+				creds:    map[string]string{s3AccessKeyIDKey: standInAccessKeyID, s3SecretAccessKeyKey: "wrong"},
+			},
+			want: want{
+				err:   "bucket bucketName denied access to the mounted credentials with status 403",
+				event: util.BAccessDenied,
+			},
+		},
+		"BucketNotFound": {
+			args: args{endpoint: server.URL, bucket: "missing", creds: creds},
+			want: want{
+				err:   fmt.Sprintf("bucket missing not found at %s", server.URL),
+				event: util.BNotFoundAtEndpoint,
+			},
+		},
+		"Unreachable": {
+			args: args{endpoint: closed.URL, bucket: "bucketName", creds: creds},
+			want: want{
+				err:   fmt.Sprintf("bucket bucketName unreachable at %s", closed.URL),
+				event: util.BUnreachable,
+			},
+		},
+		"KeyUnset": {
+			args: args{endpoint: server.URL, bucket: "bucketName", creds: map[string]string{s3AccessKeyIDKey: standInAccessKeyID}},
+			want: want{
+				err:   fmt.Sprintf(util.ErrorTemplateSecretKeyUnset, s3SecretAccessKeyKey),
+				event: util.MintedSecretKeyUnset,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			bkt := testutils.GetB(testutils.WithProtocol(v1alpha1.Protocol{
+				S3: &v1alpha1.S3Protocol{Endpoint: tc.args.endpoint, BucketName: tc.args.bucket},
+			}))
+
+			err := S3{}.Check(context.Background(), server.Client(), bkt, tc.args.creds)
+
+			got := want{}
+			if err != nil {
+				// the error of the transport depends on the platform
+				got = want{err: strings.SplitN(err.Error(), ": ", 2)[0], event: Event(err)}
+			}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{}, util.EventResource{})); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestS3Presign(t *testing.T) {
+	now := time.Date(2021, time.January, 1, 12, 0, 0, 0, time.UTC)
+	creds := map[string]string{
+		s3AccessKeyIDKey:     standInAccessKeyID,
+		s3SecretAccessKeyKey: standInSecretAccessKey,
+	}
+
+	type args struct {
+		creds   map[string]string
+		key     string
+		expires time.Duration
+	}
+	type want struct {
+		prefix string
+		err    error
+	}
+
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"PathStyle": {
+			args: args{creds: creds, key: "reports/2021 Q1.csv", expires: time.Hour},
+			want: want{
+				prefix: "https://s3.example.com/bucketName/reports/2021%20Q1.csv?X-Amz-Algorithm=AWS4-HMAC-SHA256" +
+					"&X-Amz-Credential=AKIAEXAMPLE%2F20210101%2Feu-west-1%2Fs3%2Faws4_request" +
+					"&X-Amz-Date=20210101T120000Z&X-Amz-Expires=3600&X-Amz-SignedHeaders=host&X-Amz-Signature=",
+			},
+		},
+		"ExpiryTooLong": {
+			args: args{creds: creds, key: "reports/daily.csv", expires: 8 * 24 * time.Hour},
+			want: want{
+				err: fmt.Errorf(util.ErrorTemplatePresignExpiryInvalid, 8*24*time.Hour, maxPresignExpiry),
+			},
+		},
+		"KeyUnset": {
+			args: args{creds: map[string]string{s3AccessKeyIDKey: standInAccessKeyID}, key: "reports/daily.csv", expires: time.Hour},
+			want: want{
+				err: invalid(util.MintedSecretKeyUnset, util.ErrorTemplateSecretKeyUnset, s3SecretAccessKeyKey),
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			bkt := testutils.GetB(testutils.WithProtocol(v1alpha1.Protocol{
+				S3: &v1alpha1.S3Protocol{Endpoint: "s3.example.com", BucketName: "bucketName", Region: "eu-west-1"},
+			}))
+
+			got, err := S3{}.Presign(bkt, tc.args.creds, http.MethodGet, tc.args.key, tc.args.expires, now)
+			if diff := cmp.Diff(tc.want.err, err, util.EquateErrors()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			if !strings.HasPrefix(got, tc.want.prefix) {
+				t.Errorf("expected URL with prefix %q, got %q", tc.want.prefix, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package protocol
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	amzDateFormat  = "20060102T150405Z"
+	amzDate        = "X-Amz-Date"
+	amzContentHash = "X-Amz-Content-Sha256"
+	amzAlgorithm   = "AWS4-HMAC-SHA256"
+	// emptyPayloadHash is the hex encoded sha256 digest of an empty payload.
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	// unsignedPayload replaces the payload hash of presigned requests, whose payload is unknown
+	// when signing.
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+
+	// maxPresignExpiry is the longest validity of a presigned URL Signature Version 4 allows.
+	maxPresignExpiry = 7 * 24 * time.Hour
+)
+
+// signV4 signs a request without payload with AWS Signature Version 4. The host and all x-amz-*
+// headers set on the request are signed.
+func signV4(req *http.Request, service, region, accessKeyID, secretAccessKey string, now time.Time) {
+	now = now.UTC()
+	req.Header.Set(amzDate, now.Format(amzDateFormat))
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		if lower := strings.ToLower(name); strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	canonicalHeaders := &strings.Builder{}
+	for _, name := range names {
+		fmt.Fprintf(canonicalHeaders, "%s:%s\n", name, headers[name])
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		emptyPayloadHash,
+	}, "\n")
+
+	scope := credentialScope(now, region, service)
+	sig := signature(canonicalRequest, secretAccessKey, region, service, now)
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		amzAlgorithm, accessKeyID, scope, signedHeaders, sig))
+}
+
+// presignV4 returns u presigned with AWS Signature Version 4 for requests with the method, valid for
+// expires from now. The path of u is taken unescaped, only the host header is signed.
+func presignV4(method string, u *url.URL, service, region, accessKeyID, secretAccessKey string, expires time.Duration, now time.Time) string {
+	now = now.UTC()
+	path := uriEncode(u.Path, false)
+	if path == "" {
+		path = "/"
+	}
+
+	query := u.Query()
+	query.Set("X-Amz-Algorithm", amzAlgorithm)
+	query.Set("X-Amz-Credential", accessKeyID+"/"+credentialScope(now, region, service))
+	query.Set(amzDate, now.Format(amzDateFormat))
+	query.Set("X-Amz-Expires", strconv.FormatInt(int64(expires/time.Second), 10))
+	query.Set("X-Amz-SignedHeaders", "host")
+
+	canonicalRequest := strings.Join([]string{
+		method,
+		path,
+		canonicalQuery(query),
+		"host:" + u.Host + "\n",
+		"host",
+		unsignedPayload,
+	}, "\n")
+
+	sig := signature(canonicalRequest, secretAccessKey, region, service, now)
+	return fmt.Sprintf("%s://%s%s?%s&X-Amz-Signature=%s", u.Scheme, u.Host, path, canonicalQuery(query), sig)
+}
+
+func credentialScope(now time.Time, region, service string) string {
+	return strings.Join([]string{now.Format("20060102"), region, service, "aws4_request"}, "/")
+}
+
+// signature signs the canonical request with the key derived from the secret access key for the
+// day, region and service.
+func signature(canonicalRequest, secretAccessKey, region, service string, now time.Time) string {
+	stringToSign := strings.Join([]string{
+		amzAlgorithm,
+		now.Format(amzDateFormat),
+		credentialScope(now, region, service),
+		hexSHA256(canonicalRequest),
+	}, "\n")
+
+	key := []byte("AWS4" + secretAccessKey)
+	for _, part := range []string{now.Format("20060102"), region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+// canonicalQuery encodes the query sorted by key, with every character but the unreserved ones
+// percent-encoded.
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var pairs []string
+	for _, key := range keys {
+		values := append([]string{}, query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, uriEncode(key, true)+"="+uriEncode(value, true))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode percent-encodes every byte of s but the unreserved characters, and slashes unless
+// encodeSlash is set.
+func uriEncode(s string, encodeSlash bool) string {
+	b := &strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func hexSHA256(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
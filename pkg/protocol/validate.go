@@ -0,0 +1,118 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package protocol
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+var (
+	// regionRe matches region names like us-east-1, and those of S3 compatible stores.
+	regionRe = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+	// storageAccountRe matches Azure storage account names.
+	storageAccountRe = regexp.MustCompile(`^[a-z0-9]{3,24}$`)
+)
+
+// Error is a bucket failing the validation or the connectivity check of its protocol.
+type Error struct {
+	err   error
+	event util.EventResource
+}
+
+func (e *Error) Error() string {
+	return e.err.Error()
+}
+
+// invalid returns an Error, emitting event on the pods mounting the bucket.
+func invalid(event util.EventResource, format string, args ...interface{}) error {
+	return &Error{err: fmt.Errorf(format, args...), event: event}
+}
+
+// Event returns the event to emit on the pods mounting a bucket which failed validation or its
+// connectivity check.
+func Event(err error) util.EventResource {
+	var perr *Error
+	if errors.As(err, &perr) {
+		return perr.event
+	}
+	if err == util.ErrorInvalidProtocol {
+		return util.BProtocolUnrecognized
+	}
+	return util.BProtocolFieldInvalid
+}
+
+// required fails if the field of the protocol is unset.
+func required(protocol, field, value string) error {
+	if value == "" {
+		return invalid(util.BProtocolFieldUnset, util.ErrorTemplateProtocolFieldUnset, protocol, field)
+	}
+	return nil
+}
+
+// validateEndpoint fails unless the endpoint is an http or https URL, or a bare host with an
+// optional port, which the SDKs read as an https URL.
+func validateEndpoint(protocol, endpoint string) error {
+	if err := required(protocol, "endpoint", endpoint); err != nil {
+		return err
+	}
+
+	bare := !strings.Contains(endpoint, "://")
+	raw := endpoint
+	if bare {
+		raw = "https://" + endpoint
+	}
+	u, err := url.Parse(raw)
+	if uerr, ok := err.(*url.Error); ok {
+		err = uerr.Err
+	}
+	if err != nil {
+		return invalid(util.BEndpointInvalid, util.ErrorTemplateEndpointInvalid, protocol, endpoint, err.Error())
+	}
+
+	var problem string
+	switch {
+	case u.Scheme != "http" && u.Scheme != "https":
+		problem = "scheme must be http or https"
+	case u.Hostname() == "":
+		problem = "host unset"
+	case u.User != nil:
+		problem = "credentials are not allowed"
+	case u.RawQuery != "" || u.Fragment != "":
+		problem = "query and fragment are not allowed"
+	case bare && u.Path != "":
+		problem = "endpoints without scheme must be a host and port only"
+	}
+	if problem != "" {
+		return invalid(util.BEndpointInvalid, util.ErrorTemplateEndpointInvalid, protocol, endpoint, problem)
+	}
+	return nil
+}
+
+// validateRegion fails if the region is set but not a region name.
+func validateRegion(protocol, region string) error {
+	if region != "" && !regionRe.MatchString(region) {
+		return invalid(util.BRegionInvalid, util.ErrorTemplateProtocolFieldInvalid, protocol, "region", region)
+	}
+	return nil
+}
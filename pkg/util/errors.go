@@ -8,11 +8,13 @@ const (
 	WrapErrorGetBRFailed  = "get bucketRequest failed"
 	WrapErrorGetBFailed   = "get bucket failed"
 
-	WrapErrorGetSecretFailed = "failed to get minted secret from bucketAccess"
+	WrapErrorGetSecretFailed    = "failed to get minted secret from bucketAccess"
+	WrapErrorGetBARGrantsFailed = "failed to get bucketAccessRequest grants"
 
 	WrapErrorMarshalProtocolFailed = "failed to marshal bucket protocol"
 
 	WrapErrorMkdirFailed              = "failed to mkdir for bucketPath on publish"
+	WrapErrorFailedToCreateVolumeDir  = "failed to create volume directory"
 	WrapErrorFailedToCreateVolumeFile = "failed to create file in ephemeral volume"
 	WrapErrorFailedToCreateBucketFile = "failed to create file in bucket mount folder"
 
@@ -25,9 +27,12 @@ const (
 	WrapErrorFailedToAddFinalizer    = "failed to add finalizer to bucketAccess"
 	WrapErrorFailedToMarshalMetadata = "failed to marshal Metadata struct"
 	WrapErrorFailedToWriteMetadata   = "failed to write metadata to disk"
+	WrapErrorFailedToWriteStage      = "failed to write staged credentials metadata to disk"
+	WrapErrorFailedToRemoveStage     = "failed to remove staged credentials"
 	WrapErrorFailedToMkdirForMount   = "failed to mkdir when mounting bucket"
 
 	WrapErrorFailedToReadMetadataFile  = "failed to read metadata file from volume"
+	WrapErrorFailedToReadCredentials   = "failed to read staged credentials"
 	WrapErrorFailedToUnmarshalMetadata = "failed unable to unmarshal metadata from volume"
 	WrapErrorFailedToRemoveFinalizer   = "failed to remove finalizer from bucketAccess"
 	WrapErrorFailedToUnmountVolume     = "failed to unmount and clean volume"
@@ -35,6 +40,26 @@ const (
 
 	WrapErrorCreatingFile  = "error when creating file"
 	WrapErrorWritingToFile = "error when writing file"
+
+	WrapErrorAPIServerUnreachable = "failed to reach the API server"
+	WrapErrorDataRootNotWritable  = "data root is not writable"
+	WrapErrorMounterUnhealthy     = "failed to list mount points"
+
+	WrapErrorFailedToGetVolumeUsage = "failed to get volume usage"
+	WrapErrorFailedToGetFsStats     = "failed to get filesystem stats"
+
+	WrapErrorFailedToListVolumes = "failed to list volumes in data root"
+	WrapErrorFailedToWipeVolume  = "failed to wipe credentials from volume"
+
+	WrapErrorInvalidExpiryAnnotation = "failed to parse credentials expiry annotation"
+	WrapErrorFailedToAddExpiry       = "failed to add expiry to protocolConnection"
+
+	WrapErrorFailedToPresign = "failed to presign object URLs"
+
+	WrapErrorFailedToParseTokens = "failed to parse service account tokens"
+	WrapErrorFailedToWriteToken  = "failed to write service account token"
+
+	WrapErrorFailedToRecordAudit = "failed to record audit entry"
 )
 
 var (
@@ -44,6 +69,7 @@ var (
 
 	ErrorBANoAccess       = errors.New("bucketAccess does not grant access")
 	ErrorBANoMintedSecret = errors.New("bucketAccess.Status.MintedSecretName unset")
+	ErrorBADeleting       = errors.New("bucketAccess is being deleted")
 
 	ErrorBRNotAvailable    = errors.New("bucketRequest is not available yet")
 	ErrorBRUnsetBucketName = errors.New("bucketRequest.Status.BucketInstanceName unset")
@@ -51,10 +77,62 @@ var (
 	ErrorBNotAvailable = errors.New("bucket is not available yet")
 
 	ErrorInvalidProtocol = errors.New("unrecognized protocol, unable to extract connection data")
+
+	ErrorInformersNotSynced = errors.New("informer caches have not synced yet")
+
+	ErrorVolumeIDUnset   = errors.New("volume ID unset")
+	ErrorVolumePathUnset = errors.New("volume path unset")
+
+	ErrorInvalidRevocationPolicy = errors.New("revocation policy must be one of Warn, Wipe")
+	ErrorInvalidWebhookMode      = errors.New("webhook mode must be one of warn, deny")
+	ErrorBARAndBRSet             = errors.New("only one of the bar-name and br-name volume attributes can be set")
+
+	ErrorVolumeNameUnset         = errors.New("volume name unset")
+	ErrorVolumeCapabilitiesUnset = errors.New("volume capabilities unset")
+	ErrorBlockVolumeUnsupported  = errors.New("block volumes are not supported, only mount")
+	ErrorVolumeSourceUnsupported = errors.New("creating volumes from a snapshot or volume is not supported")
+	ErrorBARReferenceUnset       = errors.New("bucketAccessRequest unset, set the bar-name parameter of the storageClass or run the external-provisioner with --extra-create-metadata")
+
+	ErrorPresignObjectsUnset = errors.New("presign-objects lists no object key")
+	ErrorPresignWithFormat   = errors.New("the format volume attribute cannot be combined with presign-objects")
+
+	ErrorRoleARNWithoutAudience = errors.New("the role-arn volume attribute requires token-audience")
 )
 
 var (
-	ErrorTemplateVolCtxUnset          = "required volume context key unset: %v"
-	ErrorTemplateVolumeAlreadyMounted = "%s is already mounted"
-	ErrorTemplateMountFailed          = "failed to mount device: %s at %s"
+	ErrorTemplateVolCtxUnset                = "required volume context key unset: %v"
+	ErrorTemplateVolumeAlreadyMounted       = "%s is already mounted"
+	ErrorTemplateMountFailed                = "failed to mount device: %s at %s"
+	ErrorTemplateVolumeNotFound             = "volume %s not found"
+	ErrorTemplateCredentialsExpired         = "credentials expired at %s"
+	ErrorTemplateUnsupportedMetadataVersion = "metadata version %d is newer than the supported version %d"
+	ErrorTemplateBARNotFoundInNamespace     = "bucketAccessRequest %s not found in namespace %s"
+	ErrorTemplateBRNotFoundInNamespace      = "bucketRequest %s not found in namespace %s"
+	ErrorTemplateInvalidFormat              = "format %q must be one of %s"
+	ErrorTemplateSecretKeyUnset             = "minted secret has no %s key"
+	ErrorTemplateBARNotGranted              = "namespace %s does not grant access to bucketAccessRequest %s to namespace %s"
+	ErrorTemplateProtocolRegistered         = "protocol %s is already registered"
+	ErrorTemplateProtocolFieldUnset         = "%s protocol: %s unset"
+	ErrorTemplateProtocolFieldInvalid       = "%s protocol: invalid %s %q"
+	ErrorTemplateEndpointInvalid            = "%s protocol: invalid endpoint %q: %s"
+	ErrorTemplateSignatureVersionInvalid    = "%s protocol: signature version %q must be one of S3V2, S3V4"
+	ErrorTemplateBucketUnreachable          = "bucket %s unreachable at %s: %s"
+	ErrorTemplateBucketAccessDenied         = "bucket %s denied access to the mounted credentials with status %d"
+	ErrorTemplateBucketNotFoundAtEndpoint   = "bucket %s not found at %s"
+	ErrorTemplateBucketCheckFailed          = "bucket %s check failed with status %d"
+	ErrorTemplatePresignUnsupported         = "%s protocol does not support presigned URLs"
+	ErrorTemplatePresignExpiryInvalid       = "presigned URL expiry %s must be between 1s and %s"
+	ErrorTemplatePresignTTLInvalid          = "presign-ttl %q must be a duration between %s and %s"
+	ErrorTemplatePresignMethodInvalid       = "presign method %q must be one of GET, PUT"
+	ErrorTemplateRoleARNInvalid             = "role-arn %q is not an ARN"
+	ErrorTemplateMountPathInvalid           = "mount-path %q is not an absolute path"
+	ErrorTemplateTokenNotRequested          = "no service account token for audience %q, add it to the tokenRequests of the CSIDriver"
+	ErrorTemplateVolumeLimitReached         = "node %s publishes %d volumes, reaching its limit of %d"
+
+	ConditionTemplateMountMissing = "%s is not mounted"
+	ConditionTemplateFileMissing  = "%s is missing"
+	ConditionTemplateFileModified = "%s has been modified"
+	ConditionTemplateBADeleted    = "bucketAccess %s has been deleted"
+	ConditionTemplateBADeleting   = "bucketAccess %s is being deleted"
+	ConditionTemplateBARevoked    = "bucketAccess %s no longer grants access"
 )
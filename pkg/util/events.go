@@ -1,20 +1,39 @@
 package util
 
 import (
+	"context"
+	"fmt"
+	"strings"
+
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/tools/record"
 )
 
+// VolumeIDAnnotation is set on every event emitted while handling a volume, so that repeats can be
+// aggregated per volume.
+const VolumeIDAnnotation = "objectstorage.k8s.io/volume-id"
+
 const (
 	BARNotReady = "BARNotReady"
 	BANotReady  = "BANotReady"
 	BRNotReady  = "BRNotReady"
 	BNotReady   = "BNotReady"
 
-	ResourcesReady     = "ResourceReady"
-	WritingCredentials = "WritingCredentials"
-	SuccessfulPublish  = "Success"
+	BProtocolInvalid = "BucketProtocolInvalid"
+	BCheckFailed     = "BucketCheckFailed"
+
+	VolumeLimitReached = "VolumeLimitExceeded"
+
+	CredentialsRevoked = "CredentialsRevoked"
+	CredentialsExpiry  = "CredentialsExpiry"
+	PresignedURLs      = "PresignedURLs"
+	FinalizerCollected = "StaleFinalizerRemoved"
+
+	ResourcesReady      = "ResourcesReady"
+	WritingCredentials  = "CredentialsWritten"
+	SuccessfulPublish   = "VolumePublished"
+	SuccessfulUnpublish = "VolumeUnpublished"
 )
 
 var (
@@ -30,11 +49,19 @@ var (
 		reason:  BARNotReady,
 		message: "Bucket Access Request status field bucketAccessName is not set",
 	}
+	BARReferenceNotGranted = EventResource{
+		reason:  BARNotReady,
+		message: "Bucket Access Request namespace does not grant access to the namespace of the pod",
+	}
 
 	BAAccessNotGranted = EventResource{
 		reason:  BANotReady,
 		message: "Bucket Access has not been granted access yet",
 	}
+	BAMarkedForDeletion = EventResource{
+		reason:  BANotReady,
+		message: "Bucket Access is being deleted",
+	}
 	BAMintedSecretNotSet = EventResource{
 		reason:  BANotReady,
 		message: "Bucket Access does not have reference to minted secret",
@@ -54,10 +81,104 @@ var (
 		message: "Bucket is not available yet",
 	}
 
+	BProtocolUnrecognized = EventResource{
+		reason:  BProtocolInvalid,
+		message: "Bucket protocol is not supported by the driver",
+	}
+	BProtocolFieldUnset = EventResource{
+		reason:  BProtocolInvalid,
+		message: "Bucket protocol is missing a required field",
+	}
+	BProtocolFieldInvalid = EventResource{
+		reason:  BProtocolInvalid,
+		message: "Bucket protocol has an invalid field",
+	}
+	BEndpointInvalid = EventResource{
+		reason:  BProtocolInvalid,
+		message: "Bucket endpoint is not a valid http or https URL",
+	}
+	BRegionInvalid = EventResource{
+		reason:  BProtocolInvalid,
+		message: "Bucket region is not a valid region name",
+	}
+	BSignatureVersionInvalid = EventResource{
+		reason:  BProtocolInvalid,
+		message: "Bucket signature version must be one of S3V2, S3V4",
+	}
+	BPresignUnsupported = EventResource{
+		reason:  BProtocolInvalid,
+		message: "Bucket protocol does not support presigned URLs",
+	}
+
+	BUnreachable = EventResource{
+		reason:  BCheckFailed,
+		message: "Bucket endpoint could not be reached",
+	}
+	BAccessDenied = EventResource{
+		reason:  BCheckFailed,
+		message: "Bucket denied access to the mounted credentials",
+	}
+	BNotFoundAtEndpoint = EventResource{
+		reason:  BCheckFailed,
+		message: "Bucket does not exist at its endpoint",
+	}
+	BCheckUnexpected = EventResource{
+		reason:  BCheckFailed,
+		message: "Bucket check returned an unexpected status",
+	}
+
+	VolumeLimitExceeded = EventResource{
+		reason:  VolumeLimitReached,
+		message: "Node has reached its maximum number of volumes, the volume is not published",
+	}
+
 	MintedSecretNotFound = EventResource{
 		reason:  BANotReady,
 		message: "Minted credentials secret not found",
 	}
+	MintedSecretKeyUnset = EventResource{
+		reason:  BANotReady,
+		message: "Minted credentials secret lacks a key the bucket protocol needs",
+	}
+)
+
+var (
+	BAAccessRevoked = EventResource{
+		reason:  CredentialsRevoked,
+		message: "Bucket Access no longer grants access, mounted credentials are no longer valid",
+	}
+	BADeleting = EventResource{
+		reason:  CredentialsRevoked,
+		message: "Bucket Access is being deleted, mounted credentials will stop working",
+	}
+	BADeleted = EventResource{
+		reason:  CredentialsRevoked,
+		message: "Bucket Access was deleted, mounted credentials are no longer valid",
+	}
+	CredentialsExpiring = EventResource{
+		reason:  CredentialsExpiry,
+		message: "Mounted credentials are about to expire",
+	}
+	CredentialsExpired = EventResource{
+		reason:  CredentialsExpiry,
+		message: "Mounted credentials have expired",
+	}
+	CredentialsWiped = EventResource{
+		reason:  CredentialsRevoked,
+		message: "Mounted credentials were removed following Bucket Access revocation",
+	}
+	PresignedURLsRefreshFailed = EventResource{
+		reason:  PresignedURLs,
+		message: "Presigned URLs could not be regenerated, they stop working once they expire",
+	}
+	PresignedURLsExpired = EventResource{
+		reason:  PresignedURLs,
+		message: "Presigned URLs cannot be regenerated, the credentials signing them have expired",
+	}
+	StaleFinalizerRemoved = EventResource{
+		reason:  FinalizerCollected,
+		message: "Removed the finalizer of a volume that will never be unpublished",
+	}
 )
 
 var (
@@ -77,7 +198,7 @@ var (
 	}
 
 	SuccessfullyUnpublishedVolume = EventResource{
-		reason:  SuccessfulPublish,
+		reason:  SuccessfulUnpublish,
 		message: "Volume successfully unpublished from pod",
 	}
 )
@@ -87,10 +208,52 @@ type EventResource struct {
 	message string
 }
 
-func EmitWarningEvent(recorder record.EventRecorder, object runtime.Object, resource EventResource) {
-	recorder.Event(object, corev1.EventTypeWarning, resource.reason, resource.message)
+// Message returns the message of events emitted for the resource.
+func (e EventResource) Message() string {
+	return e.message
+}
+
+type volumeIDKey struct{}
+
+// WithVolumeID returns a context which attributes events emitted with it to the given volume.
+func WithVolumeID(ctx context.Context, volID string) context.Context {
+	return context.WithValue(ctx, volumeIDKey{}, volID)
+}
+
+// VolumeIDFrom returns the volume ID stored in the context, if any.
+func VolumeIDFrom(ctx context.Context) string {
+	volID, _ := ctx.Value(volumeIDKey{}).(string)
+	return volID
+}
+
+func EmitWarningEvent(ctx context.Context, recorder record.EventRecorder, object runtime.Object, resource EventResource) {
+	emitEvent(ctx, recorder, object, corev1.EventTypeWarning, resource)
+}
+
+func EmitNormalEvent(ctx context.Context, recorder record.EventRecorder, object runtime.Object, resource EventResource) {
+	emitEvent(ctx, recorder, object, corev1.EventTypeNormal, resource)
+}
+
+// EmitNormalEventf emits a normal event whose message is the one of the resource followed by details
+// formatted according to format.
+func EmitNormalEventf(ctx context.Context, recorder record.EventRecorder, object runtime.Object, resource EventResource, format string, args ...interface{}) {
+	resource.message = resource.message + ": " + fmt.Sprintf(format, args...)
+	emitEvent(ctx, recorder, object, corev1.EventTypeNormal, resource)
+}
+
+func emitEvent(ctx context.Context, recorder record.EventRecorder, object runtime.Object, eventType string, resource EventResource) {
+	volID := VolumeIDFrom(ctx)
+	if volID == "" {
+		recorder.Event(object, eventType, resource.reason, resource.message)
+		return
+	}
+	recorder.AnnotatedEventf(object, map[string]string{VolumeIDAnnotation: volID}, eventType, resource.reason,
+		"Volume %s: %s", volID, resource.message)
 }
 
-func EmitNormalEvent(recorder record.EventRecorder, object runtime.Object, resource EventResource) {
-	recorder.Event(object, corev1.EventTypeNormal, resource.reason, resource.message)
+// EventAggregatorByVolume groups similar events by the volume they were emitted for, in addition to
+// the fields used by record.EventAggregatorByReasonFunc, so that repeats are aggregated per volume.
+func EventAggregatorByVolume(event *corev1.Event) (string, string) {
+	aggregateKey, localKey := record.EventAggregatorByReasonFunc(event)
+	return strings.Join([]string{aggregateKey, event.Annotations[VolumeIDAnnotation]}, ""), localKey
 }
@@ -114,7 +114,7 @@ func GetB(mod ...BktModifier) *v1alpha1.Bucket {
 					Endpoint:         "endpoint",
 					BucketName:       "bucketName",
 					Region:           "region",
-					SignatureVersion: "signatureVersion",
+					SignatureVersion: v1alpha1.S3SignatureVersionV4,
 				},
 			},
 		},
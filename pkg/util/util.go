@@ -1,11 +1,12 @@
 package util
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 
 	v1 "k8s.io/api/core/v1"
-	"k8s.io/klog/v2"
 )
 
 func ParseData(s *v1.Secret) ([]byte, error) {
@@ -20,6 +21,12 @@ func ParseData(s *v1.Secret) ([]byte, error) {
 	return data, nil
 }
 
+// Checksum returns the hex encoded sha256 digest of data.
+func Checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
 func ParseValue(key string, volCtx map[string]string) (string, error) {
 	value, ok := volCtx[key]
 	if !ok {
@@ -27,12 +34,3 @@ func ParseValue(key string, volCtx map[string]string) (string, error) {
 	}
 	return value, nil
 }
-
-// logErr should be called at the interface method scope, prior to returning errors to the gRPC client.
-func LogErr(e error) error {
-	if e == nil {
-		return nil
-	}
-	klog.Error(e)
-	return e
-}
@@ -0,0 +1,302 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+
+	"github.com/pkg/errors"
+	admissionv1 "k8s.io/api/admission/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+	cs "sigs.k8s.io/container-object-storage-interface-api/clientset/typed/objectstorage.k8s.io/v1alpha1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/logging"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/node"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/protocol"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+// Environment variables read by the cloud SDKs.
+const (
+	EnvAWSEndpointURL           = "AWS_ENDPOINT_URL"
+	EnvAWSRegion                = "AWS_REGION"
+	EnvAWSSharedCredentialsFile = "AWS_SHARED_CREDENTIALS_FILE"
+	EnvAWSRoleARN               = "AWS_ROLE_ARN"
+	EnvAWSWebIdentityTokenFile  = "AWS_WEB_IDENTITY_TOKEN_FILE"
+	EnvGoogleCloudProject       = "GOOGLE_CLOUD_PROJECT"
+	EnvGoogleCredentials        = "GOOGLE_APPLICATION_CREDENTIALS"
+	EnvAzureStorageAccount      = "AZURE_STORAGE_ACCOUNT"
+)
+
+// Mutator injects the environment variables the cloud SDKs read into the containers mounting COSI
+// volumes, so that they find the bucket and the files of the volume without any wiring.
+type Mutator struct {
+	driverName string
+	cosiClient cs.ObjectstorageV1alpha1Interface
+	kubeClient kubernetes.Interface
+}
+
+func NewMutator(driverName string, cosiClient cs.ObjectstorageV1alpha1Interface, kubeClient kubernetes.Interface) *Mutator {
+	return &Mutator{
+		driverName: driverName,
+		cosiClient: cosiClient,
+		kubeClient: kubeClient,
+	}
+}
+
+// patchOperation is a single operation of a JSON patch.
+type patchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// mountedBucket is the bucket a COSI volume mounts, the format its files are rendered in, and the
+// AWS role its service account token is exchanged for, if any.
+type mountedBucket struct {
+	bucket  *v1alpha1.Bucket
+	format  string
+	roleARN string
+}
+
+// Env returns the environment variables for a container mounting the files of the bucket, rendered
+// in the format, at mountPath. Connection information is always set, the credentials files only
+// exist in the sdk format. No secret is ever put in the environment.
+func Env(bkt *v1alpha1.Bucket, format, mountPath string) []v1.EnvVar {
+	var env []v1.EnvVar
+	add := func(name, value string) {
+		if value != "" {
+			env = append(env, v1.EnvVar{Name: name, Value: value})
+		}
+	}
+
+	switch p := bkt.Spec.Protocol; {
+	case p.S3 != nil:
+		add(EnvAWSEndpointURL, p.S3.Endpoint)
+		add(EnvAWSRegion, p.S3.Region)
+		if format == protocol.FormatSDK {
+			add(EnvAWSSharedCredentialsFile, path.Join(mountPath, protocol.AWSCredentialsFileName))
+		}
+	case p.GCS != nil:
+		add(EnvGoogleCloudProject, p.GCS.ProjectID)
+		if format == protocol.FormatSDK {
+			add(EnvGoogleCredentials, path.Join(mountPath, protocol.GCSCredentialsFileName))
+		}
+	case p.AzureBlob != nil:
+		add(EnvAzureStorageAccount, p.AzureBlob.StorageAccount)
+	}
+	return env
+}
+
+// WebIdentityEnv returns the environment variables making the AWS SDKs exchange the service account
+// token of a volume mounted at mountPath for credentials of roleARN. The other SDKs are configured
+// for workload identity outside the pod.
+func WebIdentityEnv(bkt *v1alpha1.Bucket, roleARN, mountPath string) []v1.EnvVar {
+	if roleARN == "" || bkt.Spec.Protocol.S3 == nil {
+		return nil
+	}
+	return []v1.EnvVar{
+		{Name: EnvAWSRoleARN, Value: roleARN},
+		{Name: EnvAWSWebIdentityTokenFile, Value: path.Join(mountPath, node.ServiceAccountTokenFileName)},
+	}
+}
+
+// Mutate returns the JSON patch adding the environment variables of the COSI volumes to the
+// containers mounting them, and a warning for every volume whose bucket could not be resolved.
+// Variables the container sets itself are left alone, as are mounts of a subPath, which do not
+// hold the files at the mount path.
+func (m *Mutator) Mutate(ctx context.Context, pod *v1.Pod, namespace string) ([]patchOperation, []string) {
+	var warnings []string
+	mounted := map[string]mountedBucket{}
+	for _, vol := range pod.Spec.Volumes {
+		if vol.CSI == nil || vol.CSI.Driver != m.driverName {
+			continue
+		}
+
+		if brName, ok := vol.CSI.VolumeAttributes[client.BrNameKey]; ok {
+			// volumes of a bucketRequest only hold the connection information, invalid ones are left
+			// to the validating webhook
+			if _, ok := vol.CSI.VolumeAttributes[client.BarNameKey]; ok {
+				continue
+			}
+			_, roleARN, err := client.ParseWebIdentity(vol.CSI.VolumeAttributes)
+			if err != nil {
+				continue
+			}
+			bkt, err := m.requestedBucket(ctx, brName, namespace)
+			if err != nil {
+				warnings = append(warnings, fmt.Sprintf("could not inject environment for volume %s: %s", vol.Name, err.Error()))
+				continue
+			}
+			mounted[vol.Name] = mountedBucket{bucket: bkt, format: protocol.FormatJSON, roleARN: roleARN}
+			continue
+		}
+
+		// invalid volumes are left to the validating webhook
+		barName := vol.CSI.VolumeAttributes[client.BarNameKey]
+		format, err := node.ParseFormat(vol.CSI.VolumeAttributes)
+		if barName == "" || err != nil {
+			continue
+		}
+		_, roleARN, err := client.ParseWebIdentity(vol.CSI.VolumeAttributes)
+		if err != nil {
+			continue
+		}
+
+		bkt, err := m.bucket(ctx, barName, client.ParseBARNamespace(vol.CSI.VolumeAttributes, namespace), namespace)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("could not inject environment for volume %s: %s", vol.Name, err.Error()))
+			continue
+		}
+		mounted[vol.Name] = mountedBucket{bucket: bkt, format: format, roleARN: roleARN}
+	}
+	if len(mounted) == 0 {
+		return nil, warnings
+	}
+
+	patch := patchContainers("/spec/initContainers", pod.Spec.InitContainers, mounted)
+	patch = append(patch, patchContainers("/spec/containers", pod.Spec.Containers, mounted)...)
+	return patch, warnings
+}
+
+// patchContainers appends the environment variables to the containers at path. When a container
+// mounts several COSI volumes, the first mount sets a variable.
+func patchContainers(path string, containers []v1.Container, mounted map[string]mountedBucket) []patchOperation {
+	var patch []patchOperation
+	for i, c := range containers {
+		set := map[string]bool{}
+		for _, env := range c.Env {
+			set[env.Name] = true
+		}
+
+		var add []v1.EnvVar
+		for _, vm := range c.VolumeMounts {
+			mb, ok := mounted[vm.Name]
+			if !ok || vm.SubPath != "" || vm.SubPathExpr != "" {
+				continue
+			}
+			vars := append(Env(mb.bucket, mb.format, vm.MountPath), WebIdentityEnv(mb.bucket, mb.roleARN, vm.MountPath)...)
+			for _, env := range vars {
+				if !set[env.Name] {
+					set[env.Name] = true
+					add = append(add, env)
+				}
+			}
+		}
+		if len(add) == 0 {
+			continue
+		}
+
+		envPath := fmt.Sprintf("%s/%d/env", path, i)
+		if len(c.Env) == 0 {
+			patch = append(patch, patchOperation{Op: "add", Path: envPath, Value: add})
+			continue
+		}
+		for _, env := range add {
+			patch = append(patch, patchOperation{Op: "add", Path: envPath + "/-", Value: env})
+		}
+	}
+	return patch
+}
+
+// bucket resolves the bucket a volume mounts, following the bucketAccessRequest to its
+// bucketAccess, the way the node does.
+func (m *Mutator) bucket(ctx context.Context, barName, barNs, podNs string) (*v1alpha1.Bucket, error) {
+	if err := client.CheckBARGrant(ctx, m.kubeClient, barName, barNs, podNs); err != nil {
+		return nil, err
+	}
+
+	bar, err := m.cosiClient.BucketAccessRequests(barNs).Get(ctx, barName, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, util.WrapErrorGetBARFailed)
+	}
+	if err := client.ValidateBAR(bar); err != nil {
+		return nil, err
+	}
+
+	ba, err := m.cosiClient.BucketAccesses().Get(ctx, bar.Status.BucketAccessName, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, util.WrapErrorGetBAFailed)
+	}
+
+	bkt, err := m.cosiClient.Buckets().Get(ctx, ba.Spec.BucketName, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, util.WrapErrorGetBFailed)
+	}
+	return bkt, nil
+}
+
+// requestedBucket resolves the bucket of a bucketRequest in the namespace of the pod.
+func (m *Mutator) requestedBucket(ctx context.Context, brName, podNs string) (*v1alpha1.Bucket, error) {
+	br, err := getBR(ctx, m.cosiClient, brName, podNs)
+	if err != nil {
+		return nil, err
+	}
+
+	bkt, err := m.cosiClient.Buckets().Get(ctx, br.Status.BucketName, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, util.WrapErrorGetBFailed)
+	}
+	return bkt, nil
+}
+
+// Review answers an admission request for a pod. Pods are always admitted, rejecting them is up to
+// the validating webhook.
+func (m *Mutator) Review(ctx context.Context, req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	resp := &admissionv1.AdmissionResponse{UID: req.UID, Allowed: true}
+
+	pod := &v1.Pod{}
+	if err := json.Unmarshal(req.Object.Raw, pod); err != nil {
+		resp.Allowed = false
+		resp.Result = &metav1.Status{Code: http.StatusBadRequest, Message: err.Error()}
+		return resp
+	}
+	ctx = logging.NewContext(ctx, "pod", klog.KRef(req.Namespace, podName(pod)))
+
+	patch, warnings := m.Mutate(ctx, pod, req.Namespace)
+	resp.Warnings = warnings
+	if len(patch) == 0 {
+		return resp
+	}
+
+	data, err := json.Marshal(patch)
+	if err != nil {
+		logging.FromContext(ctx).Error(err, "failed to marshal patch")
+		resp.Warnings = append(resp.Warnings, "could not inject environment: "+err.Error())
+		return resp
+	}
+	logging.FromContext(ctx).Info("injecting environment", "operations", len(patch))
+	patchType := admissionv1.PatchTypeJSONPatch
+	resp.Patch = data
+	resp.PatchType = &patchType
+	return resp
+}
+
+// ServeHTTP handles AdmissionReview requests.
+func (m *Mutator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	serve(w, r, m.Review)
+}
@@ -0,0 +1,280 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	admissionv1 "k8s.io/api/admission/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+	cosifake "sigs.k8s.io/container-object-storage-interface-api/clientset/fake"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/protocol"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+	testutils "sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util/test"
+)
+
+func sdkVolume(name, barName string) v1.Volume {
+	vol := cosiVolume(name, barName)
+	vol.CSI.VolumeAttributes[client.FormatKey] = protocol.FormatSDK
+	return vol
+}
+
+func container(env []v1.EnvVar, mounts ...v1.VolumeMount) v1.Container {
+	return v1.Container{Name: "app", Env: env, VolumeMounts: mounts}
+}
+
+func TestEnv(t *testing.T) {
+	type args struct {
+		protocol v1alpha1.Protocol
+		format   string
+	}
+	cases := map[string]struct {
+		args args
+		want []v1.EnvVar
+	}{
+		"S3": {
+			args: args{
+				protocol: v1alpha1.Protocol{S3: &v1alpha1.S3Protocol{Endpoint: "https://s3.example.com", Region: "us-east-1"}},
+				format:   protocol.FormatJSON,
+			},
+			want: []v1.EnvVar{
+				{Name: EnvAWSEndpointURL, Value: "https://s3.example.com"},
+				{Name: EnvAWSRegion, Value: "us-east-1"},
+			},
+		},
+		"S3SDK": {
+			args: args{
+				protocol: v1alpha1.Protocol{S3: &v1alpha1.S3Protocol{Endpoint: "https://s3.example.com"}},
+				format:   protocol.FormatSDK,
+			},
+			want: []v1.EnvVar{
+				{Name: EnvAWSEndpointURL, Value: "https://s3.example.com"},
+				{Name: EnvAWSSharedCredentialsFile, Value: "/cosi/aws-credentials"},
+			},
+		},
+		"GCSSDK": {
+			args: args{
+				protocol: v1alpha1.Protocol{GCS: &v1alpha1.GCSProtocol{ProjectID: "my-project"}},
+				format:   protocol.FormatSDK,
+			},
+			want: []v1.EnvVar{
+				{Name: EnvGoogleCloudProject, Value: "my-project"},
+				{Name: EnvGoogleCredentials, Value: "/cosi/service-account.json"},
+			},
+		},
+		"Azure": {
+			args: args{
+				protocol: v1alpha1.Protocol{AzureBlob: &v1alpha1.AzureProtocol{StorageAccount: "myaccount"}},
+				format:   protocol.FormatSDK,
+			},
+			want: []v1.EnvVar{
+				{Name: EnvAzureStorageAccount, Value: "myaccount"},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := Env(testutils.GetB(testutils.WithProtocol(tc.args.protocol)), tc.args.format, "/cosi")
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMutate(t *testing.T) {
+	ungranted := testutils.GetBAR()
+	ungranted.Name = "ungranted"
+	ungranted.Status.AccessGranted = false
+
+	type want struct {
+		patch    []patchOperation
+		warnings []string
+	}
+	cases := map[string]struct {
+		pod  *v1.Pod
+		want want
+	}{
+		"NoEnv": {
+			pod: func() *v1.Pod {
+				pod := getPod(sdkVolume("cosi", testutils.GetBAR().Name))
+				pod.Spec.Containers = []v1.Container{container(nil, v1.VolumeMount{Name: "cosi", MountPath: "/cosi"})}
+				return pod
+			}(),
+			want: want{
+				patch: []patchOperation{{
+					Op:   "add",
+					Path: "/spec/containers/0/env",
+					Value: []v1.EnvVar{
+						{Name: EnvAWSEndpointURL, Value: "endpoint"},
+						{Name: EnvAWSRegion, Value: "region"},
+						{Name: EnvAWSSharedCredentialsFile, Value: "/cosi/aws-credentials"},
+					},
+				}},
+			},
+		},
+		"ExistingEnv": {
+			pod: func() *v1.Pod {
+				pod := getPod(cosiVolume("cosi", testutils.GetBAR().Name))
+				pod.Spec.InitContainers = []v1.Container{
+					container([]v1.EnvVar{{Name: EnvAWSRegion, Value: "eu-west-1"}}, v1.VolumeMount{Name: "cosi", MountPath: "/cosi"}),
+				}
+				return pod
+			}(),
+			want: want{
+				patch: []patchOperation{{
+					Op:    "add",
+					Path:  "/spec/initContainers/0/env/-",
+					Value: v1.EnvVar{Name: EnvAWSEndpointURL, Value: "endpoint"},
+				}},
+			},
+		},
+		"SubPathMount": {
+			pod: func() *v1.Pod {
+				pod := getPod(cosiVolume("cosi", testutils.GetBAR().Name))
+				pod.Spec.Containers = []v1.Container{container(nil, v1.VolumeMount{Name: "cosi", MountPath: "/cosi", SubPath: "credentials"})}
+				return pod
+			}(),
+			want: want{},
+		},
+		"BucketInfo": {
+			pod: func() *v1.Pod {
+				pod := getPod(infoVolume("cosi", testutils.GetBR().Name))
+				pod.Spec.Containers = []v1.Container{container(nil, v1.VolumeMount{Name: "cosi", MountPath: "/cosi"})}
+				return pod
+			}(),
+			want: want{
+				patch: []patchOperation{{
+					Op:   "add",
+					Path: "/spec/containers/0/env",
+					Value: []v1.EnvVar{
+						{Name: EnvAWSEndpointURL, Value: "endpoint"},
+						{Name: EnvAWSRegion, Value: "region"},
+					},
+				}},
+			},
+		},
+		"WebIdentity": {
+			pod: func() *v1.Pod {
+				vol := infoVolume("cosi", testutils.GetBR().Name)
+				vol.CSI.VolumeAttributes[client.TokenAudienceKey] = "sts.amazonaws.com"
+				vol.CSI.VolumeAttributes[client.RoleARNKey] = "arn:aws:iam::123456789012:role/reader"
+				pod := getPod(vol)
+				pod.Spec.Containers = []v1.Container{container(nil, v1.VolumeMount{Name: "cosi", MountPath: "/cosi"})}
+				return pod
+			}(),
+			want: want{
+				patch: []patchOperation{{
+					Op:   "add",
+					Path: "/spec/containers/0/env",
+					Value: []v1.EnvVar{
+						{Name: EnvAWSEndpointURL, Value: "endpoint"},
+						{Name: EnvAWSRegion, Value: "region"},
+						{Name: EnvAWSRoleARN, Value: "arn:aws:iam::123456789012:role/reader"},
+						{Name: EnvAWSWebIdentityTokenFile, Value: "/cosi/token"},
+					},
+				}},
+			},
+		},
+		"BucketAccessWebIdentity": {
+			pod: func() *v1.Pod {
+				vol := cosiVolume("cosi", testutils.GetBAR().Name)
+				vol.CSI.VolumeAttributes[client.TokenAudienceKey] = "sts.amazonaws.com"
+				vol.CSI.VolumeAttributes[client.RoleARNKey] = "arn:aws:iam::123456789012:role/reader"
+				pod := getPod(vol)
+				pod.Spec.Containers = []v1.Container{container(nil, v1.VolumeMount{Name: "cosi", MountPath: "/cosi"})}
+				return pod
+			}(),
+			want: want{
+				patch: []patchOperation{{
+					Op:   "add",
+					Path: "/spec/containers/0/env",
+					Value: []v1.EnvVar{
+						{Name: EnvAWSEndpointURL, Value: "endpoint"},
+						{Name: EnvAWSRegion, Value: "region"},
+						{Name: EnvAWSRoleARN, Value: "arn:aws:iam::123456789012:role/reader"},
+						{Name: EnvAWSWebIdentityTokenFile, Value: "/cosi/token"},
+					},
+				}},
+			},
+		},
+		"NotGranted": {
+			pod: func() *v1.Pod {
+				pod := getPod(cosiVolume("cosi", ungranted.Name))
+				pod.Spec.Containers = []v1.Container{container(nil, v1.VolumeMount{Name: "cosi", MountPath: "/cosi"})}
+				return pod
+			}(),
+			want: want{
+				warnings: []string{"could not inject environment for volume cosi: " + util.ErrorBARNoAccess.Error()},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cs := cosifake.NewSimpleClientset(testutils.GetBAR(), ungranted, testutils.GetBA(), testutils.GetBR(), testutils.GetB())
+			m := NewMutator(driverName, cs.ObjectstorageV1alpha1(), k8sfake.NewSimpleClientset())
+
+			patch, warnings := m.Mutate(context.Background(), tc.pod, testutils.Namespace)
+			if diff := cmp.Diff(tc.want.patch, patch); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.warnings, warnings); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMutatorReview(t *testing.T) {
+	cs := cosifake.NewSimpleClientset(testutils.GetBAR(), testutils.GetBA(), testutils.GetB())
+	m := NewMutator(driverName, cs.ObjectstorageV1alpha1(), k8sfake.NewSimpleClientset())
+
+	pod := getPod(cosiVolume("cosi", testutils.GetBAR().Name))
+	pod.Spec.Containers = []v1.Container{container(nil, v1.VolumeMount{Name: "cosi", MountPath: "/cosi"})}
+	raw, err := json.Marshal(pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp := m.Review(context.Background(), &admissionv1.AdmissionRequest{
+		UID:       "uid",
+		Namespace: testutils.Namespace,
+		Object:    runtime.RawExtension{Raw: raw},
+	})
+
+	if !resp.Allowed {
+		t.Errorf("expected the pod to be admitted, got %+v", resp.Result)
+	}
+	if resp.PatchType == nil || *resp.PatchType != admissionv1.PatchTypeJSONPatch {
+		t.Errorf("expected a JSON patch, got %v", resp.PatchType)
+	}
+	want := `[{"op":"add","path":"/spec/containers/0/env","value":[{"name":"AWS_ENDPOINT_URL","value":"endpoint"},{"name":"AWS_REGION","value":"region"}]}]`
+	if diff := cmp.Diff(want, string(resp.Patch)); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+}
@@ -0,0 +1,264 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	admissionv1 "k8s.io/api/admission/v1"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+	cs "sigs.k8s.io/container-object-storage-interface-api/clientset/typed/objectstorage.k8s.io/v1alpha1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/logging"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/node"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+// Mode decides what happens to pods failing validation.
+type Mode string
+
+const (
+	// ModeWarn admits the pod, returning the problems as admission warnings.
+	ModeWarn Mode = "warn"
+	// ModeDeny rejects the pod.
+	ModeDeny Mode = "deny"
+)
+
+func (m Mode) Validate() error {
+	switch m {
+	case ModeWarn, ModeDeny:
+		return nil
+	default:
+		return util.ErrorInvalidWebhookMode
+	}
+}
+
+// Validator checks the COSI volumes of pods on creation, with the same checks the node runs at mount
+// time.
+type Validator struct {
+	driverName string
+	mode       Mode
+	cosiClient cs.ObjectstorageV1alpha1Interface
+	kubeClient kubernetes.Interface
+}
+
+func NewValidator(driverName string, mode Mode, cosiClient cs.ObjectstorageV1alpha1Interface, kubeClient kubernetes.Interface) *Validator {
+	return &Validator{
+		driverName: driverName,
+		mode:       mode,
+		cosiClient: cosiClient,
+		kubeClient: kubeClient,
+	}
+}
+
+// ValidatePod returns the problems found with the COSI volumes of the pod, and the checks which
+// could not be run. Only the former fail validation, the API server being unreachable says nothing
+// about the pod.
+func (v *Validator) ValidatePod(ctx context.Context, pod *v1.Pod, namespace string) (problems, unchecked []string) {
+	for _, vol := range pod.Spec.Volumes {
+		if vol.CSI == nil || vol.CSI.Driver != v.driverName {
+			continue
+		}
+
+		if brName, ok := vol.CSI.VolumeAttributes[client.BrNameKey]; ok {
+			err := v.validateBR(ctx, brName, namespace, vol.CSI.VolumeAttributes)
+			switch {
+			case err == nil:
+			case unverifiable(err):
+				unchecked = append(unchecked, fmt.Sprintf("volume %s: %s", vol.Name, err.Error()))
+			default:
+				problems = append(problems, fmt.Sprintf("volume %s: %s", vol.Name, err.Error()))
+			}
+			continue
+		}
+
+		barName := vol.CSI.VolumeAttributes[client.BarNameKey]
+		if barName == "" {
+			problems = append(problems, fmt.Sprintf("volume %s: %s", vol.Name, fmt.Sprintf(util.ErrorTemplateVolCtxUnset, client.BarNameKey)))
+			continue
+		}
+		if _, err := node.ParseFormat(vol.CSI.VolumeAttributes); err != nil {
+			problems = append(problems, fmt.Sprintf("volume %s: %s", vol.Name, err.Error()))
+			continue
+		}
+		if _, err := node.ParsePresign(vol.CSI.VolumeAttributes); err != nil {
+			problems = append(problems, fmt.Sprintf("volume %s: %s", vol.Name, err.Error()))
+			continue
+		}
+		if _, _, err := client.ParseWebIdentity(vol.CSI.VolumeAttributes); err != nil {
+			problems = append(problems, fmt.Sprintf("volume %s: %s", vol.Name, err.Error()))
+			continue
+		}
+
+		err := v.validateBAR(ctx, barName, client.ParseBARNamespace(vol.CSI.VolumeAttributes, namespace), namespace)
+		switch {
+		case err == nil:
+		case unverifiable(err):
+			unchecked = append(unchecked, fmt.Sprintf("volume %s: %s", vol.Name, err.Error()))
+		default:
+			problems = append(problems, fmt.Sprintf("volume %s: %s", vol.Name, err.Error()))
+		}
+	}
+	return problems, unchecked
+}
+
+func (v *Validator) validateBAR(ctx context.Context, barName, barNs, podNs string) error {
+	if err := client.CheckBARGrant(ctx, v.kubeClient, barName, barNs, podNs); err != nil {
+		return err
+	}
+
+	bar, err := v.cosiClient.BucketAccessRequests(barNs).Get(ctx, barName, metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		return fmt.Errorf(util.ErrorTemplateBARNotFoundInNamespace, barName, barNs)
+	}
+	if err != nil {
+		return errors.Wrap(err, util.WrapErrorGetBARFailed)
+	}
+	if err := client.ValidateBAR(bar); err != nil {
+		return err
+	}
+
+	ba, err := v.cosiClient.BucketAccesses().Get(ctx, bar.Status.BucketAccessName, metav1.GetOptions{})
+	if err != nil {
+		return errors.Wrap(err, util.WrapErrorGetBAFailed)
+	}
+	return client.ValidateBA(ba)
+}
+
+// validateBR validates a volume holding only the information of the bucket of a bucketRequest.
+func (v *Validator) validateBR(ctx context.Context, brName, podNs string, volCtx map[string]string) error {
+	if _, ok := volCtx[client.BarNameKey]; ok {
+		return util.ErrorBARAndBRSet
+	}
+	if _, _, err := client.ParseWebIdentity(volCtx); err != nil {
+		return err
+	}
+	_, err := getBR(ctx, v.cosiClient, brName, podNs)
+	return err
+}
+
+// getBR gets a bucketRequest, failing unless its bucket is available.
+func getBR(ctx context.Context, cosiClient cs.ObjectstorageV1alpha1Interface, brName, brNs string) (*v1alpha1.BucketRequest, error) {
+	br, err := cosiClient.BucketRequests(brNs).Get(ctx, brName, metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		return nil, fmt.Errorf(util.ErrorTemplateBRNotFoundInNamespace, brName, brNs)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, util.WrapErrorGetBRFailed)
+	}
+	if !br.Status.BucketAvailable {
+		return nil, util.ErrorBRNotAvailable
+	}
+	if br.Status.BucketName == "" {
+		return nil, util.ErrorBRUnsetBucketName
+	}
+	return br, nil
+}
+
+// unverifiable reports whether err is a failure to look up an object, rather than a problem with
+// the pod.
+func unverifiable(err error) bool {
+	cause := errors.Cause(err)
+	if _, ok := cause.(kerrors.APIStatus); !ok {
+		return false
+	}
+	return !kerrors.IsNotFound(cause)
+}
+
+// Review answers an admission request for a pod.
+func (v *Validator) Review(ctx context.Context, req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	resp := &admissionv1.AdmissionResponse{UID: req.UID, Allowed: true}
+
+	pod := &v1.Pod{}
+	if err := json.Unmarshal(req.Object.Raw, pod); err != nil {
+		resp.Allowed = false
+		resp.Result = &metav1.Status{Code: http.StatusBadRequest, Message: err.Error()}
+		return resp
+	}
+	ctx = logging.NewContext(ctx, "pod", klog.KRef(req.Namespace, podName(pod)))
+
+	problems, unchecked := v.ValidatePod(ctx, pod, req.Namespace)
+	for _, msg := range unchecked {
+		resp.Warnings = append(resp.Warnings, "could not validate "+msg)
+	}
+	if len(problems) == 0 {
+		return resp
+	}
+
+	logging.FromContext(ctx).Info("pod failed validation", "problems", problems, "mode", v.mode)
+	if v.mode == ModeWarn {
+		resp.Warnings = append(resp.Warnings, problems...)
+		return resp
+	}
+	resp.Allowed = false
+	resp.Result = &metav1.Status{
+		Code:    http.StatusForbidden,
+		Reason:  metav1.StatusReasonForbidden,
+		Message: strings.Join(problems, "; "),
+	}
+	return resp
+}
+
+// ServeHTTP handles AdmissionReview requests.
+func (v *Validator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	serve(w, r, v.Review)
+}
+
+// serve decodes the AdmissionReview of the request and answers it with review.
+func serve(w http.ResponseWriter, r *http.Request, review func(context.Context, *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ar := &admissionv1.AdmissionReview{}
+	if err := json.Unmarshal(body, ar); err != nil || ar.Request == nil {
+		http.Error(w, "expected an AdmissionReview request", http.StatusBadRequest)
+		return
+	}
+
+	ctx := logging.NewContext(r.Context(), "uid", ar.Request.UID)
+	ar.Response = review(ctx, ar.Request)
+	ar.Request = nil
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(ar); err != nil {
+		logging.FromContext(ctx).Error(err, "failed to write admission response")
+	}
+}
+
+// podName returns the name of the pod, which is not set yet for pods created from a generateName.
+func podName(pod *v1.Pod) string {
+	if pod.Name != "" {
+		return pod.Name
+	}
+	return pod.GenerateName
+}
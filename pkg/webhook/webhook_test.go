@@ -0,0 +1,417 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	admissionv1 "k8s.io/api/admission/v1"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	cosifake "sigs.k8s.io/container-object-storage-interface-api/clientset/fake"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+	testutils "sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util/test"
+)
+
+const driverName = "objectstorage.k8s.io"
+
+func getPod(volumes ...v1.Volume) *v1.Pod {
+	pod := testutils.GetPod()
+	pod.Spec.Volumes = volumes
+	return pod
+}
+
+func cosiVolume(name, barName string) v1.Volume {
+	return v1.Volume{
+		Name: name,
+		VolumeSource: v1.VolumeSource{
+			CSI: &v1.CSIVolumeSource{
+				Driver:           driverName,
+				VolumeAttributes: map[string]string{client.BarNameKey: barName},
+			},
+		},
+	}
+}
+
+// infoVolume holds only the information of the bucket of a bucketRequest.
+func infoVolume(name, brName string) v1.Volume {
+	return v1.Volume{
+		Name: name,
+		VolumeSource: v1.VolumeSource{
+			CSI: &v1.CSIVolumeSource{
+				Driver:           driverName,
+				VolumeAttributes: map[string]string{client.BrNameKey: brName},
+			},
+		},
+	}
+}
+
+// sharedVolume references a bucketAccessRequest in the shared namespace.
+func sharedVolume(name, barName string) v1.Volume {
+	vol := cosiVolume(name, barName)
+	vol.CSI.VolumeAttributes[client.BarNamespaceKey] = "shared"
+	return vol
+}
+
+func grants(data map[string]string) *v1.ConfigMap {
+	return &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: client.BARGrantsConfigMapName, Namespace: "shared"},
+		Data:       data,
+	}
+}
+
+func TestValidatePod(t *testing.T) {
+	ungranted := testutils.GetBAR()
+	ungranted.Name = "ungranted"
+	ungranted.Status.AccessGranted = false
+
+	sharedBAR := testutils.GetBAR()
+	sharedBAR.Namespace = "shared"
+
+	deleting := testutils.GetBA()
+	deleting.DeletionTimestamp = &metav1.Time{}
+
+	type args struct {
+		pod         *v1.Pod
+		objects     []runtime.Object
+		kubeObjects []runtime.Object
+		// getErr is returned for every get of a bucketAccessRequest
+		getErr error
+	}
+	type want struct {
+		problems  []string
+		unchecked []string
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"Valid": {
+			args: args{
+				pod:     getPod(cosiVolume("cosi", testutils.GetBAR().Name)),
+				objects: []runtime.Object{testutils.GetBAR(), testutils.GetBA()},
+			},
+			want: want{},
+		},
+		"OtherVolumesIgnored": {
+			args: args{
+				pod: getPod(
+					v1.Volume{Name: "scratch", VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}}},
+					v1.Volume{Name: "other", VolumeSource: v1.VolumeSource{CSI: &v1.CSIVolumeSource{Driver: "other.csi.k8s.io"}}},
+				),
+			},
+			want: want{},
+		},
+		"BARNameUnset": {
+			args: args{
+				pod: getPod(cosiVolume("cosi", "")),
+			},
+			want: want{
+				problems: []string{"volume cosi: " + fmt.Sprintf(util.ErrorTemplateVolCtxUnset, client.BarNameKey)},
+			},
+		},
+		"InvalidFormat": {
+			args: args{
+				pod: getPod(v1.Volume{
+					Name: "cosi",
+					VolumeSource: v1.VolumeSource{
+						CSI: &v1.CSIVolumeSource{
+							Driver: driverName,
+							VolumeAttributes: map[string]string{
+								client.BarNameKey: testutils.GetBAR().Name,
+								client.FormatKey:  "yaml",
+							},
+						},
+					},
+				}),
+				objects: []runtime.Object{testutils.GetBAR(), testutils.GetBA()},
+			},
+			want: want{
+				problems: []string{"volume cosi: " + fmt.Sprintf(util.ErrorTemplateInvalidFormat, "yaml", "json, sdk")},
+			},
+		},
+		"InvalidPresignTTL": {
+			args: args{
+				pod: getPod(v1.Volume{
+					Name: "cosi",
+					VolumeSource: v1.VolumeSource{
+						CSI: &v1.CSIVolumeSource{
+							Driver: driverName,
+							VolumeAttributes: map[string]string{
+								client.BarNameKey:        testutils.GetBAR().Name,
+								client.PresignObjectsKey: "reports/daily.csv",
+								client.PresignTTLKey:     "1m",
+							},
+						},
+					},
+				}),
+				objects: []runtime.Object{testutils.GetBAR(), testutils.GetBA()},
+			},
+			want: want{
+				problems: []string{"volume cosi: " + fmt.Sprintf(util.ErrorTemplatePresignTTLInvalid, "1m", "5m0s", "168h0m0s")},
+			},
+		},
+		"BARNotFound": {
+			args: args{
+				pod: getPod(cosiVolume("cosi", "missing")),
+			},
+			want: want{
+				problems: []string{"volume cosi: " + fmt.Sprintf(util.ErrorTemplateBARNotFoundInNamespace, "missing", testutils.Namespace)},
+			},
+		},
+		"CrossNamespaceGranted": {
+			args: args{
+				pod:         getPod(sharedVolume("cosi", testutils.GetBAR().Name)),
+				objects:     []runtime.Object{sharedBAR, testutils.GetBA()},
+				kubeObjects: []runtime.Object{grants(map[string]string{testutils.Namespace: client.GrantAll})},
+			},
+			want: want{},
+		},
+		"CrossNamespaceNotGranted": {
+			args: args{
+				pod:         getPod(sharedVolume("cosi", testutils.GetBAR().Name)),
+				objects:     []runtime.Object{sharedBAR, testutils.GetBA()},
+				kubeObjects: []runtime.Object{grants(map[string]string{testutils.Namespace: "otherBAR"})},
+			},
+			want: want{
+				problems: []string{"volume cosi: " + fmt.Sprintf(util.ErrorTemplateBARNotGranted, "shared", testutils.GetBAR().Name, testutils.Namespace)},
+			},
+		},
+		"BARNotGranted": {
+			args: args{
+				pod:     getPod(cosiVolume("cosi", ungranted.Name)),
+				objects: []runtime.Object{ungranted},
+			},
+			want: want{
+				problems: []string{"volume cosi: " + util.ErrorBARNoAccess.Error()},
+			},
+		},
+		"BADeleting": {
+			args: args{
+				pod:     getPod(cosiVolume("cosi", testutils.GetBAR().Name)),
+				objects: []runtime.Object{testutils.GetBAR(), deleting},
+			},
+			want: want{
+				problems: []string{"volume cosi: " + util.ErrorBADeleting.Error()},
+			},
+		},
+		"BucketInfo": {
+			args: args{
+				pod:     getPod(infoVolume("cosi", testutils.GetBR().Name)),
+				objects: []runtime.Object{testutils.GetBR()},
+			},
+			want: want{},
+		},
+		"BucketInfoBRNotFound": {
+			args: args{
+				pod: getPod(infoVolume("cosi", "missing")),
+			},
+			want: want{
+				problems: []string{"volume cosi: " + fmt.Sprintf(util.ErrorTemplateBRNotFoundInNamespace, "missing", testutils.Namespace)},
+			},
+		},
+		"RoleARNWithoutAudience": {
+			args: args{
+				pod: func() *v1.Pod {
+					vol := infoVolume("cosi", testutils.GetBR().Name)
+					vol.CSI.VolumeAttributes[client.RoleARNKey] = "arn:aws:iam::123456789012:role/reader"
+					return getPod(vol)
+				}(),
+				objects: []runtime.Object{testutils.GetBR()},
+			},
+			want: want{
+				problems: []string{"volume cosi: " + util.ErrorRoleARNWithoutAudience.Error()},
+			},
+		},
+		"BucketAccessRoleARNWithoutAudience": {
+			args: args{
+				pod: func() *v1.Pod {
+					vol := cosiVolume("cosi", testutils.GetBAR().Name)
+					vol.CSI.VolumeAttributes[client.RoleARNKey] = "arn:aws:iam::123456789012:role/reader"
+					return getPod(vol)
+				}(),
+				objects: []runtime.Object{testutils.GetBAR(), testutils.GetBA()},
+			},
+			want: want{
+				problems: []string{"volume cosi: " + util.ErrorRoleARNWithoutAudience.Error()},
+			},
+		},
+		"BARAndBRSet": {
+			args: args{
+				pod: func() *v1.Pod {
+					vol := infoVolume("cosi", testutils.GetBR().Name)
+					vol.CSI.VolumeAttributes[client.BarNameKey] = testutils.GetBAR().Name
+					return getPod(vol)
+				}(),
+				objects: []runtime.Object{testutils.GetBR(), testutils.GetBAR(), testutils.GetBA()},
+			},
+			want: want{
+				problems: []string{"volume cosi: " + util.ErrorBARAndBRSet.Error()},
+			},
+		},
+		"APIUnavailable": {
+			args: args{
+				pod:    getPod(cosiVolume("cosi", testutils.GetBAR().Name)),
+				getErr: kerrors.NewServiceUnavailable("unavailable"),
+			},
+			want: want{
+				unchecked: []string{fmt.Sprintf("volume cosi: %s: unavailable", util.WrapErrorGetBARFailed)},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cs := cosifake.NewSimpleClientset(tc.args.objects...)
+			if tc.args.getErr != nil {
+				cs.PrependReactor("get", "bucketaccessrequests", func(action k8stesting.Action) (bool, runtime.Object, error) {
+					return true, nil, tc.args.getErr
+				})
+			}
+			v := NewValidator(driverName, ModeDeny, cs.ObjectstorageV1alpha1(), k8sfake.NewSimpleClientset(tc.args.kubeObjects...))
+
+			problems, unchecked := v.ValidatePod(context.Background(), tc.args.pod, testutils.Namespace)
+			if diff := cmp.Diff(tc.want.problems, problems); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.unchecked, unchecked); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestReview(t *testing.T) {
+	problem := "volume cosi: " + fmt.Sprintf(util.ErrorTemplateBARNotFoundInNamespace, "missing", testutils.Namespace)
+
+	type args struct {
+		mode Mode
+		pod  *v1.Pod
+	}
+	cases := map[string]struct {
+		args args
+		want *admissionv1.AdmissionResponse
+	}{
+		"Valid": {
+			args: args{
+				mode: ModeDeny,
+				pod:  getPod(cosiVolume("cosi", testutils.GetBAR().Name)),
+			},
+			want: &admissionv1.AdmissionResponse{UID: "uid", Allowed: true},
+		},
+		"Warn": {
+			args: args{
+				mode: ModeWarn,
+				pod:  getPod(cosiVolume("cosi", "missing")),
+			},
+			want: &admissionv1.AdmissionResponse{UID: "uid", Allowed: true, Warnings: []string{problem}},
+		},
+		"Deny": {
+			args: args{
+				mode: ModeDeny,
+				pod:  getPod(cosiVolume("cosi", "missing")),
+			},
+			want: &admissionv1.AdmissionResponse{
+				UID:     "uid",
+				Allowed: false,
+				Result: &metav1.Status{
+					Code:    http.StatusForbidden,
+					Reason:  metav1.StatusReasonForbidden,
+					Message: problem,
+				},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cs := cosifake.NewSimpleClientset(testutils.GetBAR(), testutils.GetBA())
+			v := NewValidator(driverName, tc.args.mode, cs.ObjectstorageV1alpha1(), k8sfake.NewSimpleClientset())
+
+			raw, err := json.Marshal(tc.args.pod)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := v.Review(context.Background(), &admissionv1.AdmissionRequest{
+				UID:       "uid",
+				Namespace: testutils.Namespace,
+				Object:    runtime.RawExtension{Raw: raw},
+			})
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	cs := cosifake.NewSimpleClientset(testutils.GetBAR(), testutils.GetBA())
+	v := NewValidator(driverName, ModeDeny, cs.ObjectstorageV1alpha1(), k8sfake.NewSimpleClientset())
+
+	raw, err := json.Marshal(getPod(cosiVolume("cosi", "missing")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := json.Marshal(&admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+		Request: &admissionv1.AdmissionRequest{
+			UID:       "uid",
+			Namespace: testutils.Namespace,
+			Object:    runtime.RawExtension{Raw: raw},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	v.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(body)))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	review := &admissionv1.AdmissionReview{}
+	if err := json.Unmarshal(rec.Body.Bytes(), review); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff("admission.k8s.io/v1", review.APIVersion); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+	if review.Response == nil || review.Response.Allowed {
+		t.Errorf("expected the pod to be denied, got %+v", review.Response)
+	}
+
+	rec = httptest.NewRecorder()
+	v.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader([]byte("{}"))))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d for a review without request, got %d", http.StatusBadRequest, rec.Code)
+	}
+}